@@ -3,9 +3,12 @@ module github.com/liamg/dismember
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/owenrumney/squealer v1.1.0
 	github.com/spf13/cobra v1.5.0
 	github.com/stretchr/testify v1.7.4
+	golang.org/x/sys v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -14,5 +17,4 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
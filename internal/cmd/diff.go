@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/liamg/dismember/pkg/diff"
+	"github.com/liamg/dismember/pkg/dump"
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/spf13/cobra"
+)
+
+var flagInterval time.Duration
+var flagMaxChanges int
+var flagMaxLines int
+var flagSnapshotSize string
+
+func init() {
+
+	diffCmd := &cobra.Command{
+		Use:   "diff [pid] | diff [old image] [new image]",
+		Short: "Show how the memory of a process changes over time",
+		Long:  `Takes a snapshot of the memory of a process and compares it with the process after an interval, or compares two saved images, reporting maps which were added, removed or resized, and the byte ranges which changed in the maps found in both. Images may be dump directories, manifests, core files or raw blobs.`,
+		RunE:  diffHandler,
+		Args:  cobra.RangeArgs(1, 2),
+	}
+
+	diffCmd.Flags().DurationVarP(&flagInterval, "interval", "i", 5*time.Second, "The time to wait between the two snapshots of a process.")
+	diffCmd.Flags().IntVar(&flagMaxChanges, "max-changes", diff.DefaultMaxChanges, "The maximum number of changed byte ranges to report. 0 means no limit.")
+	diffCmd.Flags().IntVar(&flagMaxLines, "max-lines", diff.DefaultMaxBytes/16, "The maximum number of lines of memory to dump for each change.")
+	diffCmd.Flags().StringVar(&flagSnapshotSize, "max-total-size", "1G", "The maximum amount of memory to hold in the snapshot of a process, which is kept in memory rather than written to disk. Maps which would exceed it are not compared.")
+	diffCmd.Flags().StringVar(&flagMaxBuffer, "max-buffer", "64M", "The maximum amount of process memory to hold in a buffer at once. Larger memory maps are read in windows of this size.")
+	addRegionFlags(diffCmd)
+	rootCmd.AddCommand(diffCmd)
+}
+
+func diffHandler(cmd *cobra.Command, args []string) error {
+
+	maxBuffer, err := parseSize(flagMaxBuffer)
+	if err != nil {
+		return fmt.Errorf("invalid max buffer: %w", err)
+	}
+	filters, err := regionFilters()
+	if err != nil {
+		return err
+	}
+	if flagMaxLines < 1 {
+		return fmt.Errorf("max lines must be at least 1")
+	}
+	options := []diff.Option{
+		diff.WithWindow(maxBuffer),
+		diff.WithMaxChanges(flagMaxChanges),
+		diff.WithMaxBytes(uint64(flagMaxLines) * 16),
+		diff.WithLogger(logger),
+	}
+	for _, filter := range filters {
+		options = append(options, diff.WithRegionFilter(filter))
+	}
+
+	var old, new proc.Memory
+	if len(args) == 2 {
+		for i, path := range args {
+			image, err := dump.Open(path, 0)
+			if err != nil {
+				return fmt.Errorf("failed to open image: %w", err)
+			}
+			defer func() { _ = image.Close() }()
+			if i == 0 {
+				old = image
+			} else {
+				new = image
+			}
+		}
+	} else {
+		pid, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid pid specified: '%s': %w", args[0], err)
+		}
+		maxSize, err := parseSize(flagSnapshotSize)
+		if err != nil {
+			return fmt.Errorf("invalid max total size: %w", err)
+		}
+		process := proc.Process(pid)
+		snapshot, skipped, err := takeSnapshot(cmd, process, filters, maxBuffer, maxSize)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = snapshot.Close() }()
+		old, new = snapshot, proc.Live(process)
+		options = append(options, diff.WithRegionFilter(func(m proc.Map) (bool, string) {
+			for _, s := range skipped {
+				if s.Address == m.Address {
+					return false, "memory was left out of the snapshot"
+				}
+			}
+			return true, ""
+		}))
+	}
+
+	result, err := diff.New(options...).Compare(cmd.Context(), old, new)
+	if err != nil && cmd.Context().Err() == nil {
+		return fmt.Errorf("failed to compare memory: %w", err)
+	}
+	interrupted := cmd.Context().Err() != nil
+
+	w := cmd.OutOrStdout()
+	if result != nil {
+		if len(result.Maps) > 0 {
+			_, _ = fmt.Fprintf(w, " %sMaps%s\n\n", ansiUnderline, ansiReset)
+			for _, change := range result.Maps {
+				_, _ = fmt.Fprint(w, describeMapChange(change))
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		for i, change := range result.Changes {
+			_, _ = fmt.Fprint(w, summariseChange(i+1, change))
+		}
+	} else {
+		result = &diff.Result{}
+	}
+	if err := writeSummary(w, summary{
+		total:       len(result.Maps) + len(result.Changes),
+		noun:        "changes",
+		interrupted: interrupted,
+		limited:     result.Truncated,
+	}); err != nil {
+		return err
+	}
+	if interrupted {
+		return fmt.Errorf("interrupted")
+	}
+	return nil
+}
+
+// takeSnapshot reads the selected memory of a process into memory, and waits for the interval before returning it.
+// Nothing is written to disk. Maps which were left out to stay within the size cap are returned, so that they are
+// not reported as added when the snapshot is compared with the process.
+func takeSnapshot(cmd *cobra.Command, process proc.Process, filters []scan.RegionFilter, window uint64, maxSize uint64) (*proc.Image, proc.Maps, error) {
+	maps, err := process.Maps()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read memory maps for process %d: %w", process.PID(), err)
+	}
+	mem, err := process.OpenMemory(proc.MemoryBackendAuto)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open memory for process %d: %w", process.PID(), err)
+	}
+	defer func() { _ = mem.Close() }()
+
+	options := []dump.Option{dump.WithWindow(window), dump.WithMaxSize(maxSize), dump.WithLogger(logger)}
+	for _, filter := range filters {
+		options = append(options, dump.WithRegionFilter(filter))
+	}
+	image, manifest, err := dump.New(mem, maps, dump.Describe(process), options...).Snapshot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to take snapshot of process %d: %w", process.PID(), err)
+	}
+
+	snapshotted := make(map[uint64]bool, len(manifest.Regions))
+	for _, region := range manifest.Regions {
+		snapshotted[uint64(region.Address)] = true
+	}
+	var skipped proc.Maps
+	var skippedSize uint64
+maps:
+	for _, m := range maps {
+		if !m.Permissions.Readable || snapshotted[m.Address] {
+			continue
+		}
+		for _, filter := range filters {
+			if ok, _ := filter(m); !ok {
+				continue maps
+			}
+		}
+		skipped = append(skipped, m)
+		skippedSize += m.Size
+	}
+
+	stderr := cmd.ErrOrStderr()
+	if len(skipped) > 0 {
+		_, _ = fmt.Fprintf(stderr, "Skipped %d regions (%d bytes) which would have exceeded the maximum snapshot size of %d bytes. Use --max-total-size to compare them.\n", len(skipped), skippedSize, maxSize)
+	}
+	_, _ = fmt.Fprintf(stderr, "Took a snapshot of %d regions (%d bytes) of process %s. Waiting %s...\n", len(manifest.Regions), manifest.Size(), process.String(), flagInterval)
+	select {
+	case <-time.After(flagInterval):
+	case <-cmd.Context().Done():
+		return nil, nil, fmt.Errorf("interrupted")
+	}
+	return image, skipped, nil
+}
+
+func describeMapChange(change diff.MapChange) string {
+	switch change.Kind {
+	case diff.MapAdded:
+		return fmt.Sprintf("  %s%-8s%s %s\n", ansiGreen, change.Kind, ansiReset, describeMap(change.New))
+	case diff.MapRemoved:
+		return fmt.Sprintf("  %s%-8s%s %s\n", ansiRed, change.Kind, ansiReset, describeMap(change.Old))
+	default:
+		return fmt.Sprintf("  %s%-8s%s %s (was %d bytes)\n", ansiBold, change.Kind, ansiReset, describeMap(change.New), change.Old.Size)
+	}
+}
+
+func describeMap(m proc.Map) string {
+	return fmt.Sprintf("%016x-%016x %s %10d %s", m.Address, m.Address+m.Size, m.Permissions, m.Size, m.Path)
+}
+
+func summariseChange(number int, change diff.Change) string {
+
+	buffer := bytes.NewBuffer(nil)
+
+	_, _ = fmt.Fprintf(buffer, " %sChange #%d%s\n\n", ansiUnderline, number, ansiReset)
+	_, _ = fmt.Fprintf(buffer, "  %sAddress%s   0x%x %s\n", ansiBold, ansiReset, change.Address, change.Map.Path)
+	_, _ = fmt.Fprintf(buffer, "  %sSize%s      %d bytes\n\n", ansiBold, ansiReset, change.Size)
+	_, _ = fmt.Fprintf(buffer, "  %sMemory Dump%s\n\n%s\n\n", ansiBold, ansiReset, diffDump(change))
+
+	return buffer.String()
+}
+
+// diffDump writes a hex dump of a change, showing each line as it was in the old snapshot followed by the line in
+// the new snapshot. Bytes which changed are highlighted.
+func diffDump(change diff.Change) string {
+
+	buffer := bytes.NewBuffer(nil)
+
+	_, _ = fmt.Fprintf(buffer, "                      %s", ansiDim)
+	for i := 0; i < 0x10; i++ {
+		_, _ = fmt.Fprintf(buffer, "%02X ", i)
+	}
+	_, _ = fmt.Fprintln(buffer, ansiReset)
+
+	for start := 0; start < len(change.Old); start += 16 {
+		end := start + 16
+		if end > len(change.Old) {
+			end = len(change.Old)
+		}
+		address := change.DumpAddress + uint64(start)
+		writeDiffLine(buffer, "-", ansiRed, address, change.Old[start:end], change.New[start:end])
+		writeDiffLine(buffer, "+", ansiGreen, address, change.New[start:end], change.Old[start:end])
+	}
+	if uint64(len(change.Old)) < change.Address-change.DumpAddress+change.Size {
+		_, _ = fmt.Fprintf(buffer, "  %s...%s\n", ansiDim, ansiReset)
+	}
+
+	return buffer.String()
+}
+
+func writeDiffLine(w io.Writer, sign string, colour string, address uint64, line []byte, other []byte) {
+	var ascii string
+	_, _ = fmt.Fprintf(w, "  %s%s%s %s%016x%s  ", colour, sign, ansiReset, ansiDim, address, ansiReset)
+	for i, b := range line {
+		if b != other[i] {
+			_, _ = fmt.Fprintf(w, "%s%s%02x%s ", ansiBold, colour, b, ansiReset)
+			ascii += highlightByte(b, colour)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%02x ", b)
+		ascii += asciify(b, false)
+	}
+	_, _ = fmt.Fprintf(w, "%*s  %s\n", (16-len(line))*3, "", ascii)
+}
+
+func highlightByte(b byte, colour string) string {
+	if b < ' ' || b > '~' {
+		b = '.'
+	}
+	return fmt.Sprintf("%s%s%c%s", ansiBold, colour, b, ansiReset)
+}
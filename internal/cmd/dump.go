@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/liamg/dismember/pkg/dump"
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/spf13/cobra"
+)
+
+const (
+	dumpFormatRaw  = "raw"
+	dumpFormatCore = "core"
+)
+
+var flagDumpFormat string
+var flagDumpOutput string
+var flagMaxTotalSize string
+
+func init() {
+
+	dumpCmd := &cobra.Command{
+		Use:   "dump [pid]",
+		Short: "Save the memory of a process for offline analysis",
+		Long:  `Writes the readable memory of a process either as raw region files described by a JSON manifest, or as an ELF core file which can be loaded by gdb. Pages which cannot be read are zero-filled, and listed in the output.`,
+		RunE:  dumpHandler,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	dumpCmd.Flags().StringVar(&flagDumpFormat, "format", dumpFormatRaw, "The dump format: raw (a directory of region files and a manifest) or core (an ELF core file).")
+	dumpCmd.Flags().StringVarP(&flagDumpOutput, "output", "o", "", "The directory (raw) or file (core) to write. Defaults to dismember-<pid> or core.<pid>.")
+	dumpCmd.Flags().StringVar(&flagMaxTotalSize, "max-total-size", "", "The maximum amount of memory to dump. Regions which would exceed this are skipped.")
+	dumpCmd.Flags().StringVar(&flagMaxBuffer, "max-buffer", "64M", "The maximum amount of process memory to hold in a buffer at once. Larger memory maps are read in windows of this size.")
+	addRegionFlags(dumpCmd)
+	rootCmd.AddCommand(dumpCmd)
+}
+
+func dumpHandler(cmd *cobra.Command, args []string) error {
+
+	pid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid pid specified: '%s': %w", args[0], err)
+	}
+	process := proc.Process(pid)
+
+	maxBuffer, err := parseSize(flagMaxBuffer)
+	if err != nil {
+		return err
+	}
+	if maxBuffer == 0 {
+		return fmt.Errorf("max buffer size must be greater than zero")
+	}
+	options := []dump.Option{
+		dump.WithWindow(maxBuffer),
+		dump.WithLogger(logger),
+	}
+	if flagMaxTotalSize != "" {
+		maxSize, err := parseSize(flagMaxTotalSize)
+		if err != nil {
+			return err
+		}
+		options = append(options, dump.WithMaxSize(maxSize))
+	}
+	filters, err := regionFilters()
+	if err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		options = append(options, dump.WithRegionFilter(filter))
+	}
+
+	maps, err := process.Maps()
+	if err != nil {
+		return fmt.Errorf("failed to read memory maps for process %d: %w", process.PID(), err)
+	}
+	mem, err := process.OpenMemory(proc.MemoryBackendAuto)
+	if err != nil {
+		return fmt.Errorf("failed to open memory for process %d: %w", process.PID(), err)
+	}
+	defer func() { _ = mem.Close() }()
+
+	dumper := dump.New(mem, maps, dump.Describe(process), options...)
+
+	output := flagDumpOutput
+	var manifest *dump.Manifest
+	switch flagDumpFormat {
+	case dumpFormatRaw:
+		if output == "" {
+			output = fmt.Sprintf("dismember-%d", process.PID())
+		}
+		manifest, err = dumper.WriteRaw(output)
+	case dumpFormatCore:
+		if output == "" {
+			output = fmt.Sprintf("core.%d", process.PID())
+		}
+		manifest, err = writeCore(dumper, output)
+	default:
+		return fmt.Errorf("unsupported format '%s': must be raw or core", flagDumpFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to dump process %d: %w", process.PID(), err)
+	}
+
+	w := cmd.OutOrStdout()
+	for _, region := range manifest.Regions {
+		if err := writeDumpedRegion(w, region); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "\n%sOperation Complete. %s%d%s%s regions (%d bytes) written to %s.%s\n",
+		ansiGreen, ansiBold, len(manifest.Regions), ansiReset, ansiGreen, manifest.Size(), output, ansiReset)
+	if err != nil {
+		return err
+	}
+	if unreadable := manifest.Unreadable(); unreadable > 0 {
+		_, err = fmt.Fprintf(w, "%s%d bytes could not be read, and were zero-filled.%s\n", ansiRed, unreadable, ansiReset)
+	}
+	if err == nil {
+		_, err = fmt.Fprintln(w)
+	}
+	return err
+}
+
+func writeCore(dumper *dump.Dumper, path string) (*dump.Manifest, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := dumper.WriteCore(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return manifest, err
+}
+
+// writeDumpedRegion writes a line describing a dumped region, followed by any ranges which could not be read.
+func writeDumpedRegion(w io.Writer, region dump.Region) error {
+	if _, err := fmt.Fprintf(w, "%s%016x-%016x%s %s %10d %s\n", ansiBold, uint64(region.Address), uint64(region.Address)+region.Size, ansiReset, region.Permissions, region.Size, region.Path); err != nil {
+		return err
+	}
+	for _, r := range region.Unreadable {
+		if _, err := fmt.Fprintf(w, "  %sunreadable: %016x-%016x (%d bytes, zero-filled)%s\n", ansiRed, uint64(r.Address), uint64(r.Address)+r.Size, r.Size, ansiReset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
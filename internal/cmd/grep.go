@@ -2,13 +2,20 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 
-	"github.com/liamg/dismember/pkg/secrets"
-
+	"github.com/liamg/dismember/pkg/dump"
+	"github.com/liamg/dismember/pkg/hexpattern"
 	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/liamg/dismember/pkg/secrets"
 	"github.com/spf13/cobra"
 )
 
@@ -17,11 +24,19 @@ var flagProcessName string
 var flagIncludeSelf bool
 var flagDumpRadius int
 var flagFast bool
+var flagMaxBuffer string
+var flagOverlap string
+var flagWorkers int
+var flagValidOnly bool
+var flagHex bool
+var flagEncodings string
+var flagMaxResults int
+var flagMaxResultsPerProcess int
 
 func init() {
 
 	grepCmd := &cobra.Command{
-		Use:   "grep [keyword]",
+		Use:   "grep [keyword|hex pattern]",
 		Short: "Search process memory for a given string or regex",
 		Long:  ``,
 		RunE:  grepHandler,
@@ -33,76 +48,219 @@ func init() {
 	grepCmd.Flags().IntVarP(&flagDumpRadius, "dump-radius", "r", 2, "The number of lines of memory to dump both above and below each match.")
 	grepCmd.Flags().BoolVarP(&flagIncludeSelf, "self", "s", false, "Include results that are matched against the current process, or an ancestor of that process.")
 	grepCmd.Flags().BoolVarP(&flagFast, "fast", "f", false, "Skip memory-mapped files in order to run faster.")
+	grepCmd.Flags().StringVar(&flagMaxBuffer, "max-buffer", "64M", "The maximum amount of process memory to hold in a buffer at once. Larger memory maps are read in windows of this size.")
+	grepCmd.Flags().StringVar(&flagOverlap, "overlap", "4K", "The number of bytes shared by consecutive windows of memory. Matches longer than this may be missed, or cut short and flagged as truncated, if they cross a window boundary.")
+	grepCmd.Flags().IntVarP(&flagWorkers, "workers", "w", runtime.NumCPU(), "The number of memory regions to search concurrently. The max buffer is shared between all workers.")
+	grepCmd.Flags().BoolVar(&flagHex, "hex", false, "Treat the pattern as a sequence of hex bytes in the style of YARA hex strings, e.g. 'DE AD ?? EF', with ? wildcards, jumps such as [2-4] and alternatives such as (00 | FF). Unbounded jumps such as [4-] skip at most 4096 bytes.")
+	grepCmd.Flags().StringVar(&flagEncodings, "encoding", string(scan.EncodingUTF8), "Comma-separated text encodings to search: utf8, utf16le and/or utf16be. UTF-16 is common in .NET, Java and Windows programs.")
+	grepCmd.Flags().IntVar(&flagMaxResults, "max-results", 0, "Stop once this many results have been found. 0 means no limit.")
+	grepCmd.Flags().IntVar(&flagMaxResultsPerProcess, "max-results-per-process", 0, "Report at most this many results for each process, skipping the rest of its memory. 0 means no limit.")
+	grepCmd.Flags().BoolVar(&flagValidOnly, "valid-only", false, "Only report matches which pass offline validation, such as a GitHub token with a correct checksum or an unexpired JWT.")
+	grepCmd.Flags().StringVar(&flagRedact, "redact", string(redactNone), "Hide matched secrets in the output: none, full, partial (keep the first and last 4 characters) or hash.")
+	grepCmd.Flags().StringVar(&flagFormat, "format", formatText, "The output format: text, json, ndjson, csv or sarif.")
+	addRegionFlags(grepCmd)
+	addImageFlags(grepCmd)
 	rootCmd.AddCommand(grepCmd)
 }
 
 func grepHandler(cmd *cobra.Command, args []string) error {
 
-	var processes []proc.Process
-
-	if flagPID == 0 {
-		var err error
-		processes, err = proc.List(false)
+	var pattern secrets.Pattern
+	if flagHex {
+		finder, err := hexpattern.Compile(args[0])
 		if err != nil {
 			return err
 		}
+		pattern.Finder = finder
 	} else {
-		processes = []proc.Process{proc.Process(flagPID)}
+		regex, err := regexp.Compile(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid regex pattern: %w", err)
+		}
+		pattern.Regex = regex
 	}
 
-	regex, err := regexp.Compile(args[0])
+	image, err := openImage()
 	if err != nil {
-		return fmt.Errorf("invalid regex pattern: %w", err)
+		return err
+	}
+	if image != nil {
+		defer func() { _ = image.Close() }()
 	}
 
-	stdErr := cmd.ErrOrStderr()
-	_ = stdErr
-	stdOut := cmd.OutOrStdout()
+	options, err := scanOptions(image)
+	if err != nil {
+		return err
+	}
+
+	return writeResults(cmd, options, []secrets.Pattern{pattern})
+}
+
+// writeResults scans for the patterns, writing each result as it is found in the format chosen by the --format
+// flag. If a baseline is being recorded, it is saved once the scan is complete. The output is finished even if the
+// scan fails, so that machine-readable output remains valid.
+func writeResults(cmd *cobra.Command, options []scan.Option, patterns []secrets.Pattern) (err error) {
 
-	pattern := secrets.Pattern{
-		Regex: regex,
+	redact, err := parseRedaction(flagRedact)
+	if err != nil {
+		return err
 	}
+	baseline, record, err := openBaseline()
+	if err != nil {
+		return err
+	}
+	writer, err := newResultWriter(flagFormat, cmd.OutOrStdout(), patterns, redact)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if baseline != nil {
+		options = append(options, scan.WithResultFilter(baseline.Filter()))
+	}
+	scanner := scan.New(append(options, scan.WithPatterns(patterns...))...)
 
+	// new findings are added to the baseline after the scan, so they can't affect the filtering of later results
+	var found []scan.Result
+	var writeErr error
 	var total int
-	for _, process := range processes {
-		if flagProcessName != "" {
-			status, err := process.Status()
-			if err != nil {
-				continue
-			}
-			if !strings.Contains(status.Name, flagProcessName) {
-				continue
-			}
+	scanErr := scanner.Scan(cmd.Context(), func(result scan.Result) {
+		total++
+		if record {
+			found = append(found, result)
 		}
-		if !flagIncludeSelf && process.IsAncestor(proc.Self()) {
-			continue
+		if writeErr == nil {
+			writeErr = writer.Write(result)
 		}
-		results, err := grepProcessMemory(process, pattern)
-		if err != nil {
-			// TODO: add to debug log
-			//_, _ = fmt.Fprintf(stdErr, "failed to access memory for process %d: %s\n", process.PID(), err)
-			continue
+	})
+	interrupted := errors.Is(scanErr, context.Canceled)
+	if scanErr != nil && !interrupted {
+		return scanErr
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to write results: %w", writeErr)
+	}
+
+	if interrupted && record {
+		logger.Log("not saving baseline %s, as the scan was interrupted", flagBaseline)
+	} else if record {
+		for _, result := range found {
+			baseline.Add(result)
 		}
-		for i, result := range results {
-			_, _ = fmt.Fprint(stdOut, summariseResult(total+i+1, result))
+		if err := baseline.Save(flagBaseline); err != nil {
+			return fmt.Errorf("failed to save baseline: %w", err)
 		}
-		total += len(results)
-	}
-	if total == 0 {
-		_, _ = fmt.Fprintf(stdOut, "%sOperation Complete. No results found.%s\n\n", ansiRed, ansiReset)
-	} else {
-		_, _ = fmt.Fprintf(stdOut, "%sOperation Complete. %s%d%s%s results found.%s\n\n", ansiGreen, ansiBold, total, ansiReset, ansiGreen, ansiReset)
+		logger.Log("saved %d finding(s) to baseline %s", baseline.Len(), flagBaseline)
 	}
 
+	if err := writeSummary(summaryWriter(cmd), summary{
+		total:       total,
+		noun:        "results",
+		interrupted: interrupted,
+		limited:     flagMaxResults > 0 && total >= flagMaxResults,
+	}); err != nil {
+		return err
+	}
+	if interrupted {
+		return fmt.Errorf("interrupted")
+	}
 	return nil
 }
 
-type GrepResult struct {
-	Pattern secrets.Pattern
-	Process proc.Process
-	Map     proc.Map
-	Address uint64
-	Match   []byte
+// openBaseline loads the baseline chosen by the --baseline flag, if any. A new, empty baseline is created if the
+// file does not exist yet. If record is true, new results should be added to the baseline and saved.
+func openBaseline() (baseline *scan.Baseline, record bool, err error) {
+	if flagBaseline == "" {
+		return nil, false, nil
+	}
+	baseline, err = scan.LoadBaseline(flagBaseline)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Log("baseline %s does not exist, recording all findings", flagBaseline)
+		return scan.NewBaseline(), true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load baseline: %w", err)
+	}
+	logger.Log("loaded %d finding(s) from baseline %s", baseline.Len(), flagBaseline)
+	return baseline, flagUpdateBaseline, nil
+}
+
+// scanOptions returns the scan.Options configured by the command-line flags shared by grep and scan. If an image
+// is given, it is scanned instead of running processes.
+func scanOptions(image *dump.Image) ([]scan.Option, error) {
+	maxBuffer, overlap, err := parseBufferFlags()
+	if err != nil {
+		return nil, err
+	}
+	encodings, err := scan.ParseEncodings(flagEncodings)
+	if err != nil {
+		return nil, err
+	}
+	options := []scan.Option{
+		scan.WithEncodings(encodings...),
+		scan.WithWorkers(flagWorkers),
+		scan.WithMaxBuffer(maxBuffer),
+		scan.WithOverlap(overlap),
+		scan.WithContextRadius(flagDumpRadius),
+		scan.WithMaxResults(flagMaxResults),
+		scan.WithMaxResultsPerProcess(flagMaxResultsPerProcess),
+		scan.WithLogger(logger),
+	}
+	if image != nil {
+		options = append(options, scan.WithProcessSelector(scan.Targets(imageTarget(image))))
+	}
+	options = append(options, processOptions()...)
+	if flagValidOnly {
+		options = append(options, scan.WithResultFilter(scan.ValidOnly()))
+	}
+	for _, path := range flagAllowlists {
+		allowlist, err := scan.LoadAllowlist(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load allowlist: %w", err)
+		}
+		options = append(options, scan.WithResultFilter(allowlist.Filter()))
+	}
+	filters, err := regionFilters()
+	if err != nil {
+		return nil, err
+	}
+	for _, filter := range filters {
+		options = append(options, scan.WithRegionFilter(filter))
+	}
+	return options, nil
+}
+
+// processOptions returns the scan.Options which select processes according to the --pid, --process-name and --self
+// flags.
+func processOptions() []scan.Option {
+	options := []scan.Option{
+		scan.WithSelf(flagIncludeSelf),
+	}
+	if flagPID != 0 {
+		options = append(options, scan.WithProcessSelector(scan.Processes(proc.Process(flagPID))))
+	}
+	if flagProcessName != "" {
+		options = append(options, scan.WithProcessFilter(scan.NameContains(flagProcessName)))
+	}
+	return options
+}
+
+func parseBufferFlags() (uint64, uint64, error) {
+	maxBuffer, err := parseSize(flagMaxBuffer)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid max buffer: %w", err)
+	}
+	overlap, err := parseSize(flagOverlap)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid overlap: %w", err)
+	}
+	if overlap >= maxBuffer {
+		return 0, 0, fmt.Errorf("overlap (%d bytes) must be smaller than max buffer (%d bytes)", overlap, maxBuffer)
+	}
+	return maxBuffer, overlap, nil
 }
 
 const (
@@ -115,72 +273,100 @@ const (
 	ansiGreen     = "\x1b[32m"
 )
 
-func summariseResult(number int, g GrepResult) string {
+func summariseResult(number int, g scan.Result, redact redaction) string {
 
 	buffer := bytes.NewBuffer(nil)
 
 	_, _ = fmt.Fprintf(buffer, " %sMatch #%d%s\n\n", ansiUnderline, number, ansiReset)
-	_, _ = fmt.Fprintf(buffer, "  %sMatched%s   %s\n", ansiBold, ansiReset, string(g.Match))
+	_, _ = fmt.Fprintf(buffer, "  %sMatched%s   %s\n", ansiBold, ansiReset, displayMatch(g, redact))
+	if g.Truncated {
+		_, _ = fmt.Fprintf(buffer, "  %sTruncated%s yes, the match reached the end of a window of memory and may continue past it; try a larger --overlap\n", ansiBold, ansiReset)
+	}
 	_, _ = fmt.Fprintf(buffer, "  %sPattern%s   %s\n", ansiBold, ansiReset, g.Pattern.String())
-	_, _ = fmt.Fprintf(buffer, "  %sProcess%s   %s\n", ansiBold, ansiReset, g.Process.String())
+	if g.Pattern.Severity != secrets.SeverityNone {
+		_, _ = fmt.Fprintf(buffer, "  %sSeverity%s  %s\n", ansiBold, ansiReset, g.Pattern.Severity)
+	}
+	if g.Validation.Validity != "" {
+		_, _ = fmt.Fprintf(buffer, "  %sValidity%s  %s\n", ansiBold, ansiReset, describeValidation(g.Validation, redact))
+	}
+	if g.Entropy > 0 {
+		_, _ = fmt.Fprintf(buffer, "  %sEntropy%s   %s bits/byte\n", ansiBold, ansiReset, formatEntropy(g.Entropy))
+	}
+	_, _ = fmt.Fprintf(buffer, "  %sProcess%s   %s\n", ansiBold, ansiReset, fmt.Sprintf("%d (%s)", g.Process.PID(), g.ProcessName))
+	if g.Source != "" && g.Source != scan.SourceMemory {
+		source := string(g.Source)
+		if g.Key != "" {
+			source += " (" + g.Key + ")"
+		}
+		_, _ = fmt.Fprintf(buffer, "  %sSource%s    %s\n", ansiBold, ansiReset, source)
+	}
+	if g.Encoding != "" && g.Encoding != scan.EncodingUTF8 {
+		_, _ = fmt.Fprintf(buffer, "  %sEncoding%s  %s\n", ansiBold, ansiReset, g.Encoding)
+	}
 	_, _ = fmt.Fprintf(buffer, "  %sAddress%s   0x%x %s\n\n", ansiBold, ansiReset, g.Address, g.Map.Path)
-	_, _ = fmt.Fprintf(buffer, "  %sMemory Dump%s\n\n%s\n\n", ansiBold, ansiReset, hexDump(g))
+	_, _ = fmt.Fprintf(buffer, "  %sMemory Dump%s\n\n%s\n\n", ansiBold, ansiReset, hexDump(g, redact))
 
 	return buffer.String()
 }
 
-func hexDump(g GrepResult) string {
-
-	buffer := bytes.NewBuffer(nil)
-
-	offset := g.Address - g.Map.Address
-
-	linesEitherSide := uint64(flagDumpRadius)
-	if linesEitherSide < 0 {
-		linesEitherSide = 0
+// displayMatch returns the match as it should be shown in the summary. Matches of hex patterns are shown as hex.
+func displayMatch(g scan.Result, redact redaction) string {
+	if g.Pattern.Finder == nil || redact == redactHash {
+		return string(redact.match(g.Text()))
 	}
-
-	start := ((offset / 16) * 16) - (16 * linesEitherSide)
-	size := (((uint64(len(g.Match)) + (16 * (2 * linesEitherSide))) / 16) + 1) * 16
-
-	data, err := g.Process.ReadMemory(g.Map, start, size)
-	if err != nil {
-		return fmt.Sprintf("    dump not available: %s", err)
+	parts := make([]string, len(g.Match))
+	for i, b := range g.Match {
+		if redact.masks(i, len(g.Match)) {
+			parts[i] = "**"
+		} else {
+			parts[i] = fmt.Sprintf("%02x", b)
+		}
 	}
+	return strings.Join(parts, " ")
+}
 
-	literalStartAddr := g.Map.Address + start
-
-	_, _ = fmt.Fprintf(buffer, "                    %s", ansiDim)
-	for i := 0; i < 0x10; i++ {
-		_, _ = fmt.Fprintf(buffer, "%02X ", i)
+// describeValidation summarises a validation on a single line, e.g. "invalid (jwt: token expired at ...)", followed
+// by its details, which are redacted like the match.
+func describeValidation(v secrets.Validation, redact redaction) string {
+	colour := ansiDim
+	switch v.Validity {
+	case secrets.ValidityValid:
+		colour = ansiGreen
+	case secrets.ValidityInvalid:
+		colour = ansiRed
 	}
-	_, _ = fmt.Fprintln(buffer, ansiReset)
-
-	var ascii string
-	for index, b := range data {
+	summary := fmt.Sprintf("%s%s%s", colour, v.Validity, ansiReset)
+	if v.Kind != "" {
+		summary += fmt.Sprintf(" (%s: %s)", v.Kind, v.Reason)
+	} else if v.Reason != "" {
+		summary += fmt.Sprintf(" (%s)", v.Reason)
+	}
+	details := redact.details(v.Details)
+	keys := make([]string, 0, len(details))
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		summary += fmt.Sprintf("\n            %s%s:%s %s", ansiDim, key, ansiReset, details[key])
+	}
+	return summary
+}
 
-		localIndex := uint64(index) + start
-		inSecret := localIndex >= offset && localIndex < offset+uint64(len(g.Match))
+func hexDump(g scan.Result, redact redaction) string {
 
-		if index%16 == 0 && index > 0 {
-			_, _ = fmt.Fprintf(buffer, "  %s\n", ascii)
-			ascii = ""
-		}
-		if index%16 == 0 {
-			_, _ = fmt.Fprintf(buffer, "  %s%016x%s  ", ansiDim, literalStartAddr+uint64(index/16), ansiReset)
-		}
-
-		if inSecret {
-			_, _ = fmt.Fprintf(buffer, "%s%s", ansiBold, ansiRed)
-		}
-		_, _ = fmt.Fprintf(buffer, "%02x%s ", b, ansiReset)
-		ascii += asciify(b, inSecret)
-	}
-	if ascii != "" {
-		_, _ = fmt.Fprintf(buffer, "  %s\n", ascii)
+	if g.ContextErr != nil {
+		return fmt.Sprintf("    dump not available: %s", g.ContextErr)
 	}
 
-	return buffer.String()
+	return renderHexDump(g.ContextAddress, g.Context, func(address uint64) dumpStyle {
+		var style dumpStyle
+		if address >= g.Address && address < g.Address+uint64(len(g.Match)) {
+			style.colour = ansiRed
+		}
+		style.masked = address >= g.Address && redact.hidesContext(g, int(address-g.Address))
+		return style
+	})
 }
 
 func asciify(b byte, hl bool) string {
@@ -192,40 +378,3 @@ func asciify(b byte, hl bool) string {
 	}
 	return fmt.Sprintf("%s%s%c%s", ansiBold, ansiRed, b, ansiReset)
 }
-
-func grepProcessMemory(p proc.Process, pattern secrets.Pattern) ([]GrepResult, error) {
-	var results []GrepResult
-	maps, err := p.Maps()
-	if err != nil {
-		return nil, err
-	}
-	for _, map_ := range maps {
-		if !map_.Permissions.Readable {
-			logger.Log("skipping memory at %X for process %s: memory is not readable", map_.Address, p.String())
-			continue
-		}
-		if flagFast && (map_.Path != "" && map_.Path[0] != '[') {
-			logger.Log("skipping memory at %X for process %s: location is memory-mapped", map_.Address, p.String())
-			continue
-		}
-		memory, err := p.ReadMemory(map_, 0, 0)
-		if err != nil {
-			logger.Log("failed to read memory at %X for process %s: %s", map_.Address, p.String(), err)
-			continue
-		}
-		for _, matches := range pattern.Regex.FindAllIndex(memory, -1) {
-			results = append(results, GrepResult{
-				Process: p,
-				Map:     map_,
-				Address: map_.Address + uint64(matches[0]),
-				Match:   shrinkMatch(memory[matches[0]:matches[1]]),
-				Pattern: pattern,
-			})
-		}
-	}
-	return results, nil
-}
-
-func shrinkMatch(match []byte) []byte {
-	return bytes.Split(match, []byte{0x00})[0]
-}
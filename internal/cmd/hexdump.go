@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// dumpStyle is how a byte is shown in a hex dump.
+type dumpStyle struct {
+	// colour highlights the byte, if set.
+	colour string
+	// masked shows the byte as asterisks instead of its value.
+	masked bool
+}
+
+// renderHexDump writes a hex dump of data starting at the given address, 16 bytes to a line, with the style of
+// each byte given by its address.
+func renderHexDump(start uint64, data []byte, style func(address uint64) dumpStyle) string {
+
+	buffer := bytes.NewBuffer(nil)
+
+	_, _ = fmt.Fprintf(buffer, "                    %s", ansiDim)
+	for i := 0; i < 0x10; i++ {
+		_, _ = fmt.Fprintf(buffer, "%02X ", i)
+	}
+	_, _ = fmt.Fprintln(buffer, ansiReset)
+
+	for offset := 0; offset < len(data); offset += 16 {
+		line := data[offset:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+		var ascii string
+		_, _ = fmt.Fprintf(buffer, "  %s%016x%s  ", ansiDim, start+uint64(offset), ansiReset)
+		for i, b := range line {
+			s := style(start + uint64(offset+i))
+			value := fmt.Sprintf("%02x", b)
+			if s.masked {
+				b, value = '*', "**"
+			}
+			if s.colour != "" {
+				_, _ = fmt.Fprintf(buffer, "%s%s%s%s ", ansiBold, s.colour, value, ansiReset)
+				ascii += highlightByte(b, s.colour)
+				continue
+			}
+			_, _ = fmt.Fprintf(buffer, "%s ", value)
+			ascii += asciify(b, false)
+		}
+		_, _ = fmt.Fprintf(buffer, "%*s  %s\n", (16-len(line))*3, "", ascii)
+	}
+
+	return buffer.String()
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/liamg/dismember/pkg/dump"
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/spf13/cobra"
+)
+
+var flagImage string
+var flagImageBase string
+
+// addImageFlags registers the flags which read memory from a saved image instead of running processes.
+func addImageFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&flagImage, "image", "", "Read memory from a saved image instead of running processes: a dismember dump directory or manifest, an ELF core file, or a raw blob of memory.")
+	cmd.Flags().StringVar(&flagImageBase, "image-base", "0", "The address at which a raw image starts, e.g. 0x7f0000000000.")
+}
+
+// openImage opens the image chosen by the --image flag, or returns nil if no image was chosen. The caller must
+// Close the image.
+func openImage() (*dump.Image, error) {
+	if flagImage == "" {
+		return nil, nil
+	}
+	if flagPID != 0 || flagProcessName != "" {
+		return nil, fmt.Errorf("--image cannot be combined with --pid or --process-name")
+	}
+	base, err := strconv.ParseUint(flagImageBase, 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image base '%s': %w", flagImageBase, err)
+	}
+	image, err := dump.Open(flagImage, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	logger.Log("opened image %s of process %d (%s)", flagImage, image.PID, image.Process)
+	return image, nil
+}
+
+// imageTarget returns the scan.Target for an image.
+func imageTarget(image *dump.Image) scan.Target {
+	return scan.ImageTarget(image, proc.Process(image.PID), image.Process)
+}
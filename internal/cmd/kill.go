@@ -48,7 +48,7 @@ func killHandler(cmd *cobra.Command, args []string) error {
 	for _, candidate := range processes {
 		status, err := candidate.Status()
 		if err != nil {
-			logger.Log("failed to determine status for process %s: %s", candidate, err)
+			logger.Log("failed to determine status for process %s: %s", candidate.String(), err)
 			continue
 		}
 		if status.Parent == process {
@@ -29,7 +29,7 @@ func listHandler(cmd *cobra.Command, _ []string) error {
 	for _, process := range processes {
 		status, err := process.Status()
 		if err != nil {
-			logger.Log("failed to determine status for process %s: %s", process, err)
+			logger.Log("failed to determine status for process %s: %s", process.String(), err)
 			continue
 		}
 		_, _ = fmt.Fprintf(stdOut, "% -10d %s\n", process.PID(), status.Name)
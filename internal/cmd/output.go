@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/liamg/dismember/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+	formatSARIF  = "sarif"
+)
+
+var flagFormat string
+
+// resultWriter renders results as they are found.
+type resultWriter interface {
+	Write(result scan.Result) error
+	// Close finishes the output once all results have been written.
+	Close() error
+}
+
+func newResultWriter(format string, w io.Writer, patterns []secrets.Pattern, redact redaction) (resultWriter, error) {
+	switch format {
+	case formatText:
+		return &textWriter{w: w, redact: redact}, nil
+	case formatJSON:
+		return &jsonWriter{array: jsonArray{w: w}, redact: redact}, nil
+	case formatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w), redact: redact}, nil
+	case formatCSV:
+		return &csvWriter{w: csv.NewWriter(w), redact: redact}, nil
+	case formatSARIF:
+		return newSARIFWriter(w, patterns, redact), nil
+	default:
+		return nil, fmt.Errorf("unsupported format '%s'", format)
+	}
+}
+
+// finding is the machine-readable representation of a result.
+type finding struct {
+	PID               uint64            `json:"pid"`
+	Process           string            `json:"process"`
+	Pattern           string            `json:"pattern"`
+	PatternSource     string            `json:"pattern_source,omitempty"`
+	Severity          string            `json:"severity,omitempty"`
+	Source            string            `json:"source"`
+	Key               string            `json:"key,omitempty"`
+	Encoding          string            `json:"encoding,omitempty"`
+	Address           string            `json:"address"`
+	Region            string            `json:"region"`
+	RegionAddress     string            `json:"region_address"`
+	Permissions       string            `json:"permissions"`
+	Match             string            `json:"match"`
+	MatchBase64       string            `json:"match_base64"`
+	Truncated         bool              `json:"truncated,omitempty"`
+	Entropy           float64           `json:"entropy,omitempty"`
+	Validity          string            `json:"validity,omitempty"`
+	ValidationKind    string            `json:"validation_kind,omitempty"`
+	ValidationReason  string            `json:"validation_reason,omitempty"`
+	ValidationDetails map[string]string `json:"validation_details,omitempty"`
+	ContextAddress    string            `json:"context_address,omitempty"`
+	ContextBase64     string            `json:"context_base64,omitempty"`
+}
+
+func newFinding(result scan.Result, redact redaction) finding {
+	pattern := result.Pattern.Name
+	if pattern == "" {
+		pattern = result.Pattern.Expression()
+	}
+	f := finding{
+		PID:               result.Process.PID(),
+		Process:           result.ProcessName,
+		Pattern:           pattern,
+		PatternSource:     result.Pattern.Source,
+		Severity:          string(result.Pattern.Severity),
+		Source:            string(result.Source),
+		Key:               result.Key,
+		Encoding:          string(result.Encoding),
+		Address:           formatAddress(result.Address),
+		Region:            result.Map.Path,
+		RegionAddress:     formatAddress(result.Map.Address),
+		Permissions:       result.Map.Permissions.String(),
+		Match:             printable(redact.match(result.Text())),
+		MatchBase64:       base64.StdEncoding.EncodeToString(redact.original(result)),
+		Truncated:         result.Truncated,
+		Entropy:           result.Entropy,
+		Validity:          string(result.Validation.Validity),
+		ValidationKind:    result.Validation.Kind,
+		ValidationReason:  result.Validation.Reason,
+		ValidationDetails: redact.details(result.Validation.Details),
+	}
+	if result.ContextErr == nil && len(result.Context) > 0 {
+		f.ContextAddress = formatAddress(result.ContextAddress)
+		f.ContextBase64 = base64.StdEncoding.EncodeToString(redact.context(result))
+	}
+	return f
+}
+
+// formatEntropy formats an entropy measurement, or returns an empty string if none was taken.
+func formatEntropy(entropy float64) string {
+	if entropy == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(entropy, 'f', 2, 64)
+}
+
+func formatAddress(address uint64) string {
+	return "0x" + strconv.FormatUint(address, 16)
+}
+
+// printable replaces any non-printable ASCII bytes with '.'.
+func printable(data []byte) string {
+	output := make([]byte, len(data))
+	for i, b := range data {
+		if b < ' ' || b > '~' {
+			b = '.'
+		}
+		output[i] = b
+	}
+	return string(output)
+}
+
+// textWriter writes human-readable, coloured output, including a hex dump of each result.
+type textWriter struct {
+	w      io.Writer
+	redact redaction
+	total  int
+}
+
+func (t *textWriter) Write(result scan.Result) error {
+	t.total++
+	_, err := fmt.Fprint(t.w, summariseResult(t.total, result, t.redact))
+	return err
+}
+
+func (t *textWriter) Close() error {
+	return nil
+}
+
+// summary describes how a run ended, for the final line of output.
+type summary struct {
+	total       int
+	noun        string // noun describes what was found, e.g. "results"
+	interrupted bool
+	limited     bool // limited is true if the run stopped because it reached the maximum number of results
+}
+
+// writeSummary writes the final line of output. It is written even if the run was interrupted.
+func writeSummary(w io.Writer, s summary) error {
+	var err error
+	switch {
+	case s.interrupted:
+		_, err = fmt.Fprintf(w, "%sOperation Interrupted. %s%d%s%s %s found before the run was stopped.%s\n\n", ansiRed, ansiBold, s.total, ansiReset, ansiRed, s.noun, ansiReset)
+	case s.total == 0:
+		_, err = fmt.Fprintf(w, "%sOperation Complete. No %s found.%s\n\n", ansiRed, s.noun, ansiReset)
+	case s.limited:
+		_, err = fmt.Fprintf(w, "%sOperation Complete. %s%d%s%s %s found (limit reached).%s\n\n", ansiGreen, ansiBold, s.total, ansiReset, ansiGreen, s.noun, ansiReset)
+	default:
+		_, err = fmt.Fprintf(w, "%sOperation Complete. %s%d%s%s %s found.%s\n\n", ansiGreen, ansiBold, s.total, ansiReset, ansiGreen, s.noun, ansiReset)
+	}
+	return err
+}
+
+// summaryWriter returns where the summary should be written: after the results in text output, or on stderr so
+// that machine-readable output remains valid.
+func summaryWriter(cmd *cobra.Command) io.Writer {
+	if flagFormat == formatText {
+		return cmd.OutOrStdout()
+	}
+	return cmd.ErrOrStderr()
+}
+
+// jsonArray writes a single JSON array, one element at a time. The document is only valid once the array has
+// been closed.
+type jsonArray struct {
+	w     io.Writer
+	total int
+}
+
+func (a *jsonArray) Write(v interface{}) error {
+	data, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	separator := ",\n  "
+	if a.total == 0 {
+		separator = "[\n  "
+	}
+	a.total++
+	_, err = fmt.Fprintf(a.w, "%s%s", separator, data)
+	return err
+}
+
+func (a *jsonArray) Close() error {
+	if a.total == 0 {
+		_, err := fmt.Fprintln(a.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprint(a.w, "\n]\n")
+	return err
+}
+
+// jsonWriter writes the findings as a single JSON array, as they are found.
+type jsonWriter struct {
+	array  jsonArray
+	redact redaction
+}
+
+func (j *jsonWriter) Write(result scan.Result) error {
+	return j.array.Write(newFinding(result, j.redact))
+}
+
+func (j *jsonWriter) Close() error {
+	return j.array.Close()
+}
+
+// ndjsonWriter writes one JSON object per line, so findings can be consumed as they are found.
+type ndjsonWriter struct {
+	encoder *json.Encoder
+	redact  redaction
+}
+
+func (n *ndjsonWriter) Write(result scan.Result) error {
+	return n.encoder.Encode(newFinding(result, n.redact))
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+var csvHeader = []string{
+	"pid", "process", "pattern", "pattern_source", "severity", "source", "key", "encoding", "address", "region", "region_address", "permissions",
+	"match", "match_base64", "entropy", "validity", "validation_reason", "context_address", "context_base64",
+	"truncated",
+}
+
+// csvWriter writes a CSV document with a header row.
+type csvWriter struct {
+	w       *csv.Writer
+	redact  redaction
+	started bool
+}
+
+func (c *csvWriter) Write(result scan.Result) error {
+	if !c.started {
+		c.started = true
+		if err := c.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	f := newFinding(result, c.redact)
+	if err := c.w.Write([]string{
+		strconv.FormatUint(f.PID, 10), f.Process, f.Pattern, f.PatternSource, f.Severity, f.Source, f.Key, f.Encoding, f.Address,
+		f.Region, f.RegionAddress, f.Permissions, f.Match, f.MatchBase64, formatEntropy(f.Entropy), f.Validity, f.ValidationReason, f.ContextAddress, f.ContextBase64,
+		strconv.FormatBool(f.Truncated),
+	}); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if !c.started {
+		if err := c.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
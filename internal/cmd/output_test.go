@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/liamg/dismember/pkg/secrets"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPattern = secrets.Pattern{Name: "Acme Key", Regex: regexp.MustCompile(`acme_[a-z0-9]+`)}
+
+func testResults() []scan.Result {
+	heap := proc.Map{Address: 0x1000, Size: 0x1000, Permissions: proc.MemPerms{Readable: true, Writable: true}, Path: "[heap]"}
+	return []scan.Result{
+		{
+			Pattern:        testPattern,
+			Process:        proc.Process(42),
+			ProcessName:    "target",
+			Map:            heap,
+			Source:         scan.SourceMemory,
+			Encoding:       scan.EncodingUTF8,
+			Address:        0x1004,
+			Match:          []byte("acme_0123456789"),
+			Context:        []byte("key=acme_0123456789\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+			ContextAddress: 0x1000,
+		},
+		{
+			Pattern:     testPattern,
+			Process:     proc.Process(43),
+			ProcessName: `quote" comma,`,
+			Map:         proc.Map{Address: 0x2000, Size: 0x1000, Permissions: proc.MemPerms{Readable: true}, Path: "/tmp/a \"b\",c\nd"},
+			Source:      scan.SourceMemory,
+			Encoding:    scan.EncodingUTF8,
+			Address:     0x2000,
+			Match:       []byte(`acme_"x",\y`),
+			Truncated:   true,
+		},
+	}
+}
+
+// writeAll writes the results with a writer for the given format, returning the output.
+func writeAll(t *testing.T, format string, results []scan.Result, redact redaction) string {
+	buffer := bytes.NewBuffer(nil)
+	writer, err := newResultWriter(format, buffer, []secrets.Pattern{testPattern}, redact)
+	require.NoError(t, err)
+	for _, result := range results {
+		require.NoError(t, writer.Write(result))
+	}
+	require.NoError(t, writer.Close())
+	return buffer.String()
+}
+
+func Test_ResultWriters(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		results []scan.Result
+	}{
+		{name: "no results"},
+		{name: "multiple results", results: testResults()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			t.Run("text", func(t *testing.T) {
+				output := writeAll(t, formatText, test.results, redactNone)
+				if len(test.results) == 0 {
+					assert.Empty(t, output)
+				}
+				var truncated int
+				for _, result := range test.results {
+					assert.Contains(t, output, string(result.Match))
+					if result.Truncated {
+						truncated++
+					}
+				}
+				assert.Equal(t, truncated, strings.Count(output, "Truncated"))
+			})
+
+			t.Run("json", func(t *testing.T) {
+				var findings []finding
+				require.NoError(t, json.Unmarshal([]byte(writeAll(t, formatJSON, test.results, redactNone)), &findings))
+				require.Len(t, findings, len(test.results))
+				for i, result := range test.results {
+					assert.Equal(t, newFinding(result, redactNone), findings[i])
+				}
+			})
+
+			t.Run("ndjson", func(t *testing.T) {
+				output := writeAll(t, formatNDJSON, test.results, redactNone)
+				decoder := json.NewDecoder(strings.NewReader(output))
+				for _, result := range test.results {
+					var f finding
+					require.NoError(t, decoder.Decode(&f))
+					assert.Equal(t, newFinding(result, redactNone), f)
+				}
+				assert.ErrorIs(t, decoder.Decode(&finding{}), io.EOF)
+				assert.Equal(t, len(test.results), strings.Count(output, "\n"))
+			})
+
+			t.Run("csv", func(t *testing.T) {
+				records, err := csv.NewReader(strings.NewReader(writeAll(t, formatCSV, test.results, redactNone))).ReadAll()
+				require.NoError(t, err)
+				require.Len(t, records, len(test.results)+1)
+				assert.Equal(t, csvHeader, records[0])
+				for i, result := range test.results {
+					f := newFinding(result, redactNone)
+					assert.Equal(t, f.Process, records[i+1][1])
+					assert.Equal(t, f.Address, records[i+1][8])
+					assert.Equal(t, f.Region, records[i+1][9])
+					assert.Equal(t, f.Match, records[i+1][12])
+					assert.Equal(t, strconv.FormatBool(result.Truncated), records[i+1][19])
+				}
+			})
+		})
+	}
+}
+
+// failingWriter fails the given write, counting from 1, and accepts all others.
+type failingWriter struct {
+	bytes.Buffer
+	fail   int
+	writes int
+}
+
+func (f *failingWriter) Write(data []byte) (int, error) {
+	f.writes++
+	if f.writes == f.fail {
+		return 0, errors.New("disk full")
+	}
+	return f.Buffer.Write(data)
+}
+
+func Test_WriteResultsClosesOutputAfterError(t *testing.T) {
+
+	data := []byte("acme_first\x00acme_second\x00acme_third")
+	image := proc.NewImage([]proc.Segment{{
+		Map:  proc.Map{Address: 0x1000, Size: uint64(len(data)), Permissions: proc.MemPerms{Readable: true}},
+		Data: io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))),
+	}}, nil)
+	options := []scan.Option{
+		scan.WithProcessSelector(scan.Targets(scan.ImageTarget(image, proc.Process(42), "target"))),
+		scan.WithSources(scan.SourceMemory),
+		scan.WithWorkers(1),
+	}
+
+	for _, format := range []string{formatJSON, formatCSV} {
+		t.Run(format, func(t *testing.T) {
+			flagFormat, flagRedact, flagBaseline = format, string(redactNone), ""
+
+			output := &failingWriter{fail: 2}
+			cmd := &cobra.Command{}
+			cmd.SetContext(context.Background())
+			cmd.SetOut(output)
+			cmd.SetErr(io.Discard)
+
+			err := writeResults(cmd, options, []secrets.Pattern{testPattern})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "disk full")
+
+			switch format {
+			case formatJSON:
+				var findings []finding
+				require.NoError(t, json.Unmarshal(output.Bytes(), &findings))
+				assert.Len(t, findings, 1)
+			case formatCSV:
+				_, err := csv.NewReader(bytes.NewReader(output.Bytes())).ReadAll()
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/spf13/cobra"
+)
+
+var flagYes bool
+
+func init() {
+
+	pokeCmd := &cobra.Command{
+		Use:   "poke [pid] [address] [hex|string]",
+		Short: "Write to the memory of a process",
+		Long:  `Writes a string, or a sequence of hex bytes with --hex, to the memory of a process at the given hex address. The bytes must fall within a single writable map. The memory is shown before and after the write, which must be confirmed unless --yes is set. Dismember will not write to its own memory, or to that of its ancestors.`,
+		RunE:  pokeHandler,
+		Args:  cobra.ExactArgs(3),
+	}
+
+	pokeCmd.Flags().BoolVar(&flagHex, "hex", false, "Treat the data as a sequence of hex bytes, e.g. 'DE AD BE EF'.")
+	pokeCmd.Flags().BoolVarP(&flagYes, "yes", "y", false, "Write without asking for confirmation.")
+	rootCmd.AddCommand(pokeCmd)
+}
+
+func pokeHandler(cmd *cobra.Command, args []string) error {
+
+	pid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid pid specified: '%s': %w", args[0], err)
+	}
+	address, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(args[1]), "0x"), 16, 64)
+	if err != nil {
+		return fmt.Errorf("invalid address specified: '%s': %w", args[1], err)
+	}
+	data := []byte(args[2])
+	if flagHex {
+		if data, err = hex.DecodeString(strings.Join(strings.Fields(args[2]), "")); err != nil {
+			return fmt.Errorf("invalid hex data: %w", err)
+		}
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to write")
+	}
+
+	process := proc.Process(pid)
+	maps, err := process.Maps()
+	if err != nil {
+		return fmt.Errorf("failed to read memory maps for process %d: %w", pid, err)
+	}
+	m, err := pokeMap(process, maps, address, len(data))
+	if err != nil {
+		return err
+	}
+
+	// show whole lines of memory around the bytes being written, without leaving the map
+	start := address &^ 0xf
+	if start < m.Address {
+		start = m.Address
+	}
+	end := (address + uint64(len(data)) + 0xf) &^ 0xf
+	if end > m.Address+m.Size {
+		end = m.Address + m.Size
+	}
+
+	w := cmd.OutOrStdout()
+	before, err := process.ReadMemory(m, start-m.Address, end-start)
+	if err != nil {
+		return fmt.Errorf("failed to read memory at 0x%x: %w", address, err)
+	}
+	_, _ = fmt.Fprintf(w, "\n  %sProcess%s   %s\n", ansiBold, ansiReset, process.String())
+	_, _ = fmt.Fprintf(w, "  %sAddress%s   0x%x %s\n", ansiBold, ansiReset, address, m.Path)
+	_, _ = fmt.Fprintf(w, "  %sSize%s      %d bytes\n\n", ansiBold, ansiReset, len(data))
+	_, _ = fmt.Fprintf(w, "  %sBefore%s\n\n%s\n", ansiBold, ansiReset, pokeDump(start, before, address, len(data), ansiRed))
+
+	if !flagYes {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Write %d bytes to 0x%x in process %s? [y/N] ", len(data), address, process.String())
+		answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			return fmt.Errorf("write cancelled")
+		}
+	}
+
+	if err := process.WriteMemory(address, data, proc.MemoryBackendAuto); err != nil {
+		return fmt.Errorf("failed to write to process %d: %w", pid, err)
+	}
+
+	after, err := process.ReadMemory(m, start-m.Address, end-start)
+	if err != nil {
+		return fmt.Errorf("failed to read memory at 0x%x after writing: %w", address, err)
+	}
+	_, _ = fmt.Fprintf(w, "\n  %sAfter%s\n\n%s\n", ansiBold, ansiReset, pokeDump(start, after, address, len(data), ansiGreen))
+	_, _ = fmt.Fprintf(w, "%sOperation Complete. %s%d%s%s bytes written.%s\n\n", ansiGreen, ansiBold, len(data), ansiReset, ansiGreen, ansiReset)
+	return nil
+}
+
+// pokeMap returns the map which size bytes at the given address would be written to. Writes to dismember or its
+// ancestors are refused, as are writes which don't fall within a single writable map.
+func pokeMap(process proc.Process, maps proc.Maps, address uint64, size int) (proc.Map, error) {
+	if process.IsAncestor(proc.Self()) {
+		return proc.Map{}, fmt.Errorf("refusing to write to process %d: it is dismember or one of its ancestors", process.PID())
+	}
+	m, ok := maps.Find(address)
+	switch {
+	case !ok:
+		return proc.Map{}, fmt.Errorf("address 0x%x is not mapped in process %d", address, process.PID())
+	case !m.Permissions.Writable:
+		return proc.Map{}, fmt.Errorf("address 0x%x is in a map which is not writable: %s", address, describeMap(m))
+	case uint64(size) > m.Address+m.Size-address:
+		return proc.Map{}, fmt.Errorf("%d bytes at 0x%x would run past the end of the map: %s", size, address, describeMap(m))
+	}
+	return m, nil
+}
+
+// pokeDump writes a hex dump of memory starting at the given address, highlighting the bytes being written.
+func pokeDump(start uint64, data []byte, address uint64, size int, colour string) string {
+	return renderHexDump(start, data, func(current uint64) dumpStyle {
+		if current >= address && current < address+uint64(size) {
+			return dumpStyle{colour: colour}
+		}
+		return dumpStyle{}
+	})
+}
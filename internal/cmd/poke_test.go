@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PokeMap(t *testing.T) {
+
+	// a pid which can't be one of our ancestors
+	const other = proc.Process(1 << 30)
+
+	maps := proc.Maps{
+		{Address: 0x1000, Size: 0x1000, Permissions: proc.MemPerms{Readable: true, Executable: true}, Path: "/usr/bin/target"},
+		{Address: 0x2000, Size: 0x1000, Permissions: proc.MemPerms{Readable: true, Writable: true}, Path: "[heap]"},
+	}
+
+	tests := []struct {
+		name    string
+		process proc.Process
+		address uint64
+		size    int
+		err     string
+	}{
+		{name: "self", process: proc.Self(), address: 0x2000, size: 4, err: "it is dismember or one of its ancestors"},
+		{name: "parent", process: proc.Process(os.Getppid()), address: 0x2000, size: 4, err: "it is dismember or one of its ancestors"},
+		{name: "init", process: proc.Process(1), address: 0x2000, size: 4, err: "it is dismember or one of its ancestors"},
+		{name: "not mapped", process: other, address: 0x4000, size: 4, err: "is not mapped"},
+		{name: "not writable", process: other, address: 0x1010, size: 4, err: "not writable"},
+		{name: "past end of map", process: other, address: 0x2ffe, size: 4, err: "would run past the end of the map"},
+		{name: "end of map", process: other, address: 0x2ffc, size: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := pokeMap(test.process, maps, test.address, test.size)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, maps[1], m)
+		})
+	}
+}
+
+func Test_PokeDump(t *testing.T) {
+
+	data := []byte("0123456789abcdefghij")
+	dump := ansiCodes.ReplaceAllString(pokeDump(0x1004, data, 0x1012, 2, ansiGreen), "")
+
+	assert.Contains(t, dump, "0000000000001004  30 31 32 33 34 35 36 37 38 39 61 62 63 64 65 66   0123456789abcdef")
+	assert.Contains(t, dump, "0000000000001014  67 68 69 6a                                       ghij")
+
+	// only the bytes being written are highlighted
+	dump = pokeDump(0x1004, data, 0x1012, 2, ansiGreen)
+	assert.Contains(t, dump, ansiBold+ansiGreen+"65"+ansiReset)
+	assert.Contains(t, dump, ansiBold+ansiGreen+"66"+ansiReset)
+	assert.NotContains(t, dump, ansiGreen+"64")
+	assert.NotContains(t, dump, ansiGreen+"67")
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/liamg/dismember/pkg/scan"
+)
+
+// redaction controls how much of each secret is shown in the output.
+type redaction string
+
+const (
+	redactNone    redaction = "none"
+	redactFull    redaction = "full"
+	redactPartial redaction = "partial"
+	redactHash    redaction = "hash"
+)
+
+// partialKeep is the number of characters shown at either end of a secret by partial redaction.
+const partialKeep = 4
+
+var flagRedact string
+
+func parseRedaction(s string) (redaction, error) {
+	switch r := redaction(strings.ToLower(s)); r {
+	case redactNone, redactFull, redactPartial, redactHash:
+		return r, nil
+	default:
+		return "", fmt.Errorf("invalid redaction '%s': must be one of none, full, partial or hash", s)
+	}
+}
+
+// masks returns true if the byte at index within a match of the given length should be hidden.
+func (r redaction) masks(index int, length int) bool {
+	switch r {
+	case redactNone, "":
+		return false
+	case redactPartial:
+		if length > partialKeep*2 {
+			return index >= partialKeep && index < length-partialKeep
+		}
+	}
+	return true
+}
+
+// match returns the match as it should be displayed. Hashed matches are replaced entirely by their SHA-256 hash,
+// so that the same secret can be recognised across findings without revealing it.
+func (r redaction) match(match []byte) []byte {
+	if r == redactHash {
+		sum := sha256.Sum256(match)
+		return []byte("sha256:" + hex.EncodeToString(sum[:]))
+	}
+	return r.mask(match, 0, len(match), 1)
+}
+
+// original returns the original bytes of the match of a result as they should be output.
+func (r redaction) original(result scan.Result) []byte {
+	if r == redactHash {
+		return r.match(result.Text())
+	}
+	return r.mask(result.Match, 0, len(result.Match), result.Encoding.UnitSize())
+}
+
+// context returns a copy of the context of the result, with the match masked.
+func (r redaction) context(result scan.Result) []byte {
+	if r == redactNone || r == "" {
+		return result.Context
+	}
+	output := make([]byte, len(result.Context))
+	copy(output, result.Context)
+	start := int(result.Address - result.ContextAddress)
+	for i := range output {
+		if r.hidesContext(result, i-start) {
+			output[i] = '*'
+		}
+	}
+	return output
+}
+
+// hidesContext returns true if the byte of the context of a result at the given offset from the start of its match
+// should be hidden. A truncated match may continue past the bytes which were matched, so everything after the
+// start of a truncated match is hidden, including the end which partial redaction would otherwise show.
+func (r redaction) hidesContext(result scan.Result, offset int) bool {
+	if r == redactNone || r == "" || offset < 0 {
+		return false
+	}
+	size := result.Encoding.UnitSize()
+	length := len(result.Match)
+	if !result.Truncated {
+		return offset < length && r.masksByte(offset, length, size)
+	}
+	return r != redactPartial || length/size <= partialKeep*2 || offset/size >= partialKeep
+}
+
+// details returns a copy of the details of a validation, with each value redacted like a match. Details can
+// reveal the secret, such as the claims of a JWT.
+func (r redaction) details(details map[string]string) map[string]string {
+	if r == redactNone || r == "" || details == nil {
+		return details
+	}
+	output := make(map[string]string, len(details))
+	for key, value := range details {
+		output[key] = string(r.match([]byte(value)))
+	}
+	return output
+}
+
+// masksByte returns true if the byte at index within the original bytes of a match should be hidden. The bytes
+// of a character in a wide encoding such as UTF-16 are masked together.
+func (r redaction) masksByte(index int, length int, size int) bool {
+	return r.masks(index/size, length/size)
+}
+
+// mask returns a copy of data with any masked bytes of the match, which starts at offset, replaced by '*'.
+func (r redaction) mask(data []byte, offset int, length int, size int) []byte {
+	if r == redactNone || r == "" {
+		return data
+	}
+	output := make([]byte, len(data))
+	copy(output, data)
+	for i := 0; i < length; i++ {
+		if offset+i >= 0 && offset+i < len(output) && r.masksByte(i, length, size) {
+			output[offset+i] = '*'
+		}
+	}
+	return output
+}
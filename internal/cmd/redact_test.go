@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/liamg/dismember/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ansiCodes = regexp.MustCompile("\x1b\\[[0-9]+m")
+
+// redactedResults returns a result for the secret in each encoding, with the secret in the middle of its context,
+// and in the details of its validation.
+func redactedResults(secret string) []scan.Result {
+	wide := make([]byte, 0, len(secret)*2)
+	for _, c := range []byte(secret) {
+		wide = append(wide, c, 0)
+	}
+	var results []scan.Result
+	for _, r := range []struct {
+		encoding scan.Encoding
+		match    []byte
+	}{
+		{encoding: scan.EncodingUTF8, match: []byte(secret)},
+		{encoding: scan.EncodingUTF16LE, match: wide},
+	} {
+		context := append(append([]byte("before\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), r.match...), "\x00after"...)
+		results = append(results, scan.Result{
+			Pattern:        testPattern,
+			Process:        proc.Process(42),
+			ProcessName:    "target",
+			Map:            proc.Map{Address: 0x1000, Size: 0x1000, Permissions: proc.MemPerms{Readable: true}, Path: "[heap]"},
+			Source:         scan.SourceMemory,
+			Encoding:       r.encoding,
+			Address:        0x1010,
+			Match:          r.match,
+			Context:        context,
+			ContextAddress: 0x1000,
+			Validation: secrets.Validation{
+				Validity: secrets.ValidityValid,
+				Kind:     "jwt",
+				Details:  map[string]string{"subject": secret},
+			},
+		})
+	}
+	return results
+}
+
+// hidden returns the forms in which the hidden part of a secret could leak into the output: as text, and as the
+// hex bytes of a dump in each encoding.
+func hidden(part string) []string {
+	var narrow, wide []string
+	for _, c := range []byte(part) {
+		narrow = append(narrow, hex.EncodeToString([]byte{c}))
+		wide = append(wide, hex.EncodeToString([]byte{c}), "00")
+	}
+	return []string{part, strings.Join(narrow, " "), strings.Join(wide, " ")}
+}
+
+func Test_RedactedOutput(t *testing.T) {
+
+	const secret = "acme_0123456789"
+	sum := sha256.Sum256([]byte(secret))
+
+	tests := []struct {
+		redact  redaction
+		hidden  string // hidden is a part of the secret which must not appear in the output
+		display string // display is how the secret is shown in the output
+	}{
+		{redact: redactFull, hidden: secret, display: strings.Repeat("*", len(secret))},
+		{redact: redactPartial, hidden: "_012345", display: "acme*******6789"},
+		{redact: redactHash, hidden: secret, display: "sha256:" + hex.EncodeToString(sum[:])},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.redact), func(t *testing.T) {
+			results := redactedResults(secret)
+
+			for _, format := range []string{formatText, formatJSON, formatNDJSON, formatCSV, formatSARIF} {
+				t.Run(format, func(t *testing.T) {
+					output := ansiCodes.ReplaceAllString(writeAll(t, format, results, test.redact), "")
+					for _, leak := range hidden(test.hidden) {
+						assert.NotContains(t, output, leak)
+					}
+				})
+			}
+
+			t.Run("text", func(t *testing.T) {
+				output := ansiCodes.ReplaceAllString(writeAll(t, formatText, results, test.redact), "")
+				assert.Equal(t, len(results), strings.Count(output, "Matched   "+test.display+"\n"))
+			})
+
+			t.Run("json", func(t *testing.T) {
+				var findings []finding
+				require.NoError(t, json.Unmarshal([]byte(writeAll(t, formatJSON, results, test.redact)), &findings))
+				require.Len(t, findings, len(results))
+				for _, f := range findings {
+					assert.Equal(t, test.display, f.Match)
+					assert.Equal(t, map[string]string{"subject": test.display}, f.ValidationDetails)
+					for _, encoded := range []string{f.MatchBase64, f.ContextBase64} {
+						decoded, err := base64.StdEncoding.DecodeString(encoded)
+						require.NoError(t, err)
+						assert.NotContains(t, strings.ReplaceAll(string(decoded), "\x00", ""), test.hidden)
+					}
+				}
+			})
+
+			t.Run("csv", func(t *testing.T) {
+				records, err := csv.NewReader(strings.NewReader(writeAll(t, formatCSV, results, test.redact))).ReadAll()
+				require.NoError(t, err)
+				for _, record := range records[1:] {
+					assert.Equal(t, test.display, record[12])
+					for _, encoded := range []string{record[13], record[18]} {
+						decoded, err := base64.StdEncoding.DecodeString(encoded)
+						require.NoError(t, err)
+						assert.NotContains(t, strings.ReplaceAll(string(decoded), "\x00", ""), test.hidden)
+					}
+				}
+			})
+		})
+	}
+}
+
+func Test_RedactedTruncatedMatch(t *testing.T) {
+
+	// only the start of the secret was matched, but the rest of it is in the context
+	const secret = "acme_0123456789abcdefghij"
+	result := redactedResults(secret)[0]
+	result.Match = result.Match[:15]
+	result.Truncated = true
+
+	for _, redact := range []redaction{redactFull, redactPartial, redactHash} {
+		t.Run(string(redact), func(t *testing.T) {
+			dump := ansiCodes.ReplaceAllString(hexDump(result, redact), "")
+			context := string(redact.context(result))
+			for _, leak := range append(hidden("_0123"), append(hidden("6789"), hidden("ghij")...)...) {
+				assert.NotContains(t, dump, leak)
+				assert.NotContains(t, context, leak)
+			}
+			assert.Contains(t, dump, "before")
+			assert.Contains(t, context, "before")
+		})
+	}
+
+	// partial redaction still shows the start of the match
+	assert.Contains(t, ansiCodes.ReplaceAllString(hexDump(result, redactPartial), ""), "61 63 6d 65 ** **")
+}
+
+func Test_RedactedHexDump(t *testing.T) {
+
+	results := redactedResults("acme_0123456789")
+
+	// the first and last 4 characters are kept in both the hex bytes and the ASCII column
+	dump := ansiCodes.ReplaceAllString(hexDump(results[0], redactPartial), "")
+	assert.Contains(t, dump, "61 63 6d 65 ** ** ** ** ** ** ** 36 37 38 39")
+	assert.Contains(t, dump, "acme*******6789")
+
+	// the bytes of each UTF-16 character are masked together
+	dump = ansiCodes.ReplaceAllString(hexDump(results[1], redactPartial), "")
+	assert.Contains(t, dump, "61 00 63 00 6d 00 65 00 ** ** ** **")
+	assert.Contains(t, dump, "** ** 36 00 37 00 38 00 39 00")
+
+	dump = ansiCodes.ReplaceAllString(hexDump(results[0], redactNone), "")
+	assert.Contains(t, dump, "61 63 6d 65 5f 30 31 32 33 34 35 36 37 38 39")
+}
+
+func Test_RedactionShortSecrets(t *testing.T) {
+	// secrets too short to keep 4 characters at either end are hidden entirely
+	assert.Equal(t, "********", string(redactPartial.match([]byte("abcdefgh"))))
+	assert.Equal(t, "abcd*6789", string(redactPartial.match([]byte("abcde6789"))))
+	assert.Equal(t, "abc", string(redactNone.match([]byte("abc"))))
+
+	_, err := parseRedaction("some")
+	assert.Error(t, err)
+	r, err := parseRedaction("PARTIAL")
+	require.NoError(t, err)
+	assert.Equal(t, redactPartial, r)
+}
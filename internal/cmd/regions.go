@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/spf13/cobra"
+)
+
+var flagRegionKinds []string
+var flagRegionPerms string
+var flagRegionWritable bool
+var flagRegionNoExec bool
+var flagRegionPath string
+var flagRegionAddressRange string
+var flagRegionMinSize string
+var flagRegionMaxSize string
+
+// addRegionFlags registers the flags which select memory regions, shared by every command which reads memory.
+func addRegionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&flagRegionKinds, "kind", nil, "Only read regions of these kinds: heap, stack, anon, file and/or vdso.")
+	cmd.Flags().StringVar(&flagRegionPerms, "perms", "", "Only read regions with these permissions, e.g. rw-p. Use ? to match any permission in a position.")
+	cmd.Flags().BoolVar(&flagRegionWritable, "writable", false, "Only read writable regions.")
+	cmd.Flags().BoolVar(&flagRegionNoExec, "no-exec", false, "Skip executable regions.")
+	cmd.Flags().StringVar(&flagRegionPath, "path", "", "Only read regions whose path matches this glob, e.g. '/usr/lib/**' or '[heap]'. '*' does not match '/': use '**' to match any number of directories.")
+	cmd.Flags().StringVar(&flagRegionAddressRange, "address-range", "", "Only read regions which overlap this range of addresses, e.g. 0x7f0000000000-0x7fffffffffff.")
+	cmd.Flags().StringVar(&flagRegionMinSize, "min-size", "", "Skip regions smaller than this size, e.g. 4K.")
+	cmd.Flags().StringVar(&flagRegionMaxSize, "max-size", "", "Skip regions larger than this size, e.g. 1G.")
+}
+
+// regionFilters returns the scan.RegionFilters configured by the flags registered with addRegionFlags.
+func regionFilters() ([]scan.RegionFilter, error) {
+
+	var filters []scan.RegionFilter
+
+	if len(flagRegionKinds) > 0 {
+		var kinds []proc.MapKind
+		for _, name := range flagRegionKinds {
+			switch kind := proc.MapKind(strings.ToLower(strings.TrimSpace(name))); kind {
+			case proc.MapKindHeap, proc.MapKindStack, proc.MapKindAnon, proc.MapKindFile, proc.MapKindVDSO:
+				kinds = append(kinds, kind)
+			default:
+				return nil, fmt.Errorf("invalid region kind '%s': must be one of heap, stack, anon, file or vdso", name)
+			}
+		}
+		filters = append(filters, scan.Kinds(kinds...))
+	}
+	if flagRegionPerms != "" {
+		filter, err := scan.Permissions(flagRegionPerms)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if flagRegionWritable {
+		filters = append(filters, scan.WritableOnly())
+	}
+	if flagRegionNoExec {
+		filters = append(filters, scan.SkipExecutable())
+	}
+	if flagRegionPath != "" {
+		filter, err := scan.PathGlob(flagRegionPath)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if flagRegionAddressRange != "" {
+		start, end, err := parseAddressRange(flagRegionAddressRange)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, scan.AddressRange(start, end))
+	}
+	if flagRegionMinSize != "" {
+		size, err := parseSize(flagRegionMinSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min size: %w", err)
+		}
+		filters = append(filters, scan.MinSize(size))
+	}
+	if flagRegionMaxSize != "" {
+		size, err := parseSize(flagRegionMaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max size: %w", err)
+		}
+		filters = append(filters, scan.MaxSize(size))
+	}
+	if flagFast {
+		filters = append(filters, scan.SkipFileBacked())
+	}
+
+	return filters, nil
+}
+
+// parseAddressRange parses a range of hex addresses such as "0x1000-0x2000".
+func parseAddressRange(input string) (uint64, uint64, error) {
+	parts := strings.Split(input, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid address range '%s': must be in the form start-end", input)
+	}
+	start, err := parseAddress(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseAddress(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("invalid address range '%s': end must be after start", input)
+	}
+	return start, end, nil
+}
+
+// parseAddress parses a hex address, with or without a 0x prefix.
+func parseAddress(input string) (uint64, error) {
+	s := strings.TrimSpace(input)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	address, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address '%s'", input)
+	}
+	return address, nil
+}
@@ -1,6 +1,11 @@
 package cmd
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/liamg/dismember/internal/pkg/debug"
 	"github.com/spf13/cobra"
 )
@@ -24,5 +29,13 @@ var flagDebug bool
 
 func Execute() error {
 	rootCmd.PersistentFlags().BoolVarP(&flagDebug, "debug", "D", false, "Enable debug logging")
-	return rootCmd.Execute()
+
+	// the first interrupt stops the command gracefully, and a second terminates it immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return rootCmd.ExecuteContext(ctx)
 }
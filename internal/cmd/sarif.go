@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/liamg/dismember/pkg/secrets"
+)
+
+// see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+const (
+	sarifVersion     = "2.1.0"
+	sarifSchema      = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifFingerprint = "dismember/v1"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	ShortDescription sarifMessage      `json:"shortDescription"`
+	FullDescription  sarifMessage      `json:"fullDescription"`
+	HelpURI          string            `json:"helpUri,omitempty"`
+	Properties       map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Level               string                 `json:"level"`
+	Message             sarifMessage           `json:"message"`
+	Locations           []sarifLocation        `json:"locations"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	ByteOffset uint64 `json:"byteOffset"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// sarifWriter collects results and writes a single SARIF document on Close. Every pattern becomes a rule,
+// whether or not it produced any results.
+type sarifWriter struct {
+	w       io.Writer
+	rules   []sarifRule
+	ruleIDs map[string]int // ruleIDs maps a pattern key to the index of its rule
+	results []sarifResult
+	redact  redaction
+}
+
+func newSARIFWriter(w io.Writer, patterns []secrets.Pattern, redact redaction) *sarifWriter {
+	s := &sarifWriter{
+		w:       w,
+		redact:  redact,
+		ruleIDs: make(map[string]int),
+		results: []sarifResult{},
+	}
+	for _, pattern := range patterns {
+		s.rule(pattern)
+	}
+	return s
+}
+
+// rule returns the index of the rule for the given pattern, creating the rule if required.
+func (s *sarifWriter) rule(pattern secrets.Pattern) int {
+	key := pattern.Name + "\x00" + pattern.Expression()
+	if index, ok := s.ruleIDs[key]; ok {
+		return index
+	}
+
+	name := pattern.Name
+	if name == "" {
+		name = pattern.Expression()
+	}
+	id := strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if id == "" {
+		id = "pattern"
+	}
+	unique := id
+	for suffix := 2; s.hasRuleID(unique); suffix++ {
+		unique = fmt.Sprintf("%s-%d", id, suffix)
+	}
+
+	description := pattern.Description
+	if description == "" {
+		description = fmt.Sprintf("Memory matching the pattern %s", pattern.Expression())
+	}
+
+	rule := sarifRule{
+		ID:               unique,
+		Name:             name,
+		ShortDescription: sarifMessage{Text: name},
+		FullDescription:  sarifMessage{Text: description},
+		Properties:       map[string]string{"pattern": pattern.Expression()},
+	}
+	if pattern.Severity != secrets.SeverityNone {
+		rule.Properties["severity"] = string(pattern.Severity)
+	}
+	if strings.HasPrefix(pattern.Source, "https://") || strings.HasPrefix(pattern.Source, "http://") {
+		rule.HelpURI = pattern.Source
+	} else if pattern.Source != "" {
+		rule.Properties["source"] = pattern.Source
+	}
+
+	s.rules = append(s.rules, rule)
+	s.ruleIDs[key] = len(s.rules) - 1
+	return len(s.rules) - 1
+}
+
+func (s *sarifWriter) hasRuleID(id string) bool {
+	for _, rule := range s.rules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sarifWriter) Write(result scan.Result) error {
+	index := s.rule(result.Pattern)
+	rule := s.rules[index]
+
+	region := result.Map.Path
+	if region == "" {
+		region = "[anon]"
+	}
+	pid := result.Process.PID()
+	name := result.ProcessName
+
+	s.results = append(s.results, sarifResult{
+		RuleID:    rule.ID,
+		RuleIndex: index,
+		Level:     sarifLevel(result.Pattern.Severity),
+		Message: sarifMessage{
+			Text: fmt.Sprintf("%s found in the memory of process %d (%s) at 0x%x", rule.Name, pid, name, result.Address),
+		},
+		Locations: []sarifLocation{
+			{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{
+						URI: sarifURI(pid, region, result.Map.Address),
+					},
+					Region: sarifRegion{
+						ByteOffset: result.Address - result.Map.Address,
+						ByteLength: len(result.Match),
+					},
+				},
+				LogicalLocations: []sarifLogicalLocation{
+					{
+						Name:               name,
+						FullyQualifiedName: fmt.Sprintf("pid://%d", pid),
+						Kind:               "process",
+					},
+				},
+			},
+		},
+		PartialFingerprints: map[string]string{
+			sarifFingerprint: result.Fingerprint(),
+		},
+		Properties: map[string]interface{}{
+			"pid":         pid,
+			"process":     name,
+			"address":     formatAddress(result.Address),
+			"region":      result.Map.Path,
+			"permissions": result.Map.Permissions.String(),
+		},
+	})
+	properties := s.results[len(s.results)-1].Properties
+	if result.Source != "" {
+		properties["source"] = string(result.Source)
+	}
+	if result.Key != "" {
+		properties["key"] = result.Key
+	}
+	if result.Encoding != "" && result.Encoding != scan.EncodingUTF8 {
+		properties["encoding"] = string(result.Encoding)
+	}
+	if result.Entropy > 0 {
+		properties["entropy"] = result.Entropy
+	}
+	if result.Truncated {
+		properties["truncated"] = true
+	}
+	if result.Validation.Validity != "" {
+		properties["validity"] = string(result.Validation.Validity)
+		properties["validationReason"] = result.Validation.Reason
+		if len(result.Validation.Details) > 0 {
+			properties["validationDetails"] = s.redact.details(result.Validation.Details)
+		}
+	}
+	return nil
+}
+
+func (s *sarifWriter) Close() error {
+	encoder := json.NewEncoder(s.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{
+						Name:           "dismember",
+						InformationURI: "https://github.com/liamg/dismember",
+						Rules:          s.rules,
+					},
+				},
+				Results: s.results,
+			},
+		},
+	})
+}
+
+// sarifURI returns the artifact URI for a map in the memory of a process. Each segment of the map path is escaped,
+// so paths containing spaces or '#' still give a valid URI.
+func sarifURI(pid uint64, path string, address uint64) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return fmt.Sprintf("pid://%d/%s#0x%x", pid, strings.Join(segments, "/"), address)
+}
+
+// sarifLevel maps a pattern severity to a SARIF result level. Patterns without a severity are treated as errors.
+func sarifLevel(severity secrets.Severity) string {
+	switch severity {
+	case secrets.SeverityLow:
+		return "note"
+	case secrets.SeverityMedium:
+		return "warning"
+	default:
+		return "error"
+	}
+}
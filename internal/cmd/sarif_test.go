@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SARIFWriter(t *testing.T) {
+
+	patterns := []secrets.Pattern{
+		testPattern,
+		{Name: "Acme Key", Regex: regexp.MustCompile(`acme_[A-Z]+`), Severity: secrets.SeverityHigh, Source: "https://example.com/rules"},
+		{Regex: regexp.MustCompile(`tok_[a-z]+`)},
+	}
+
+	output := writeAll(t, formatSARIF, testResults(), redactNone)
+	var log sarifLog
+	require.NoError(t, json.Unmarshal([]byte(output), &log))
+
+	assert.Equal(t, "https://json.schemastore.org/sarif-2.1.0.json", log.Schema)
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, "dismember", run.Tool.Driver.Name)
+
+	// rules are created for the patterns passed to the writer, not just those with results
+	writer := newSARIFWriter(nil, patterns, redactNone)
+	require.Len(t, writer.rules, 3)
+	assert.Equal(t, "acme-key", writer.rules[0].ID)
+	assert.Equal(t, "acme-key-2", writer.rules[1].ID)
+	assert.Equal(t, "https://example.com/rules", writer.rules[1].HelpURI)
+	assert.Equal(t, "high", writer.rules[1].Properties["severity"])
+	assert.Equal(t, "tok-a-z", writer.rules[2].ID)
+
+	require.Len(t, run.Tool.Driver.Rules, 1)
+	assert.Equal(t, "acme-key", run.Tool.Driver.Rules[0].ID)
+	require.Len(t, run.Results, 2)
+	for i, result := range testResults() {
+		got := run.Results[i]
+		assert.Equal(t, "acme-key", got.RuleID)
+		assert.Equal(t, 0, got.RuleIndex)
+		require.Len(t, got.Locations, 1)
+		region := got.Locations[0].PhysicalLocation.Region
+		assert.Equal(t, result.Address-result.Map.Address, region.ByteOffset)
+		assert.Equal(t, len(result.Match), region.ByteLength)
+		assert.Equal(t, result.Fingerprint(), got.PartialFingerprints[sarifFingerprint])
+	}
+	assert.Equal(t, "pid://42/%5Bheap%5D#0x1000", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "pid://42", run.Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Equal(t, "Acme Key found in the memory of process 42 (target) at 0x1004", run.Results[0].Message.Text)
+}
+
+func Test_SARIFWriterNoResults(t *testing.T) {
+	var log sarifLog
+	require.NoError(t, json.Unmarshal([]byte(writeAll(t, formatSARIF, nil, redactNone)), &log))
+	require.Len(t, log.Runs, 1)
+	assert.NotNil(t, log.Runs[0].Results)
+	assert.Empty(t, log.Runs[0].Results)
+	require.Len(t, log.Runs[0].Tool.Driver.Rules, 1)
+}
+
+func Test_SARIFURI(t *testing.T) {
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "[anon]", want: "pid://42/%5Banon%5D#0x1000"},
+		{path: "/usr/lib/libc.so.6", want: "pid://42/usr/lib/libc.so.6#0x1000"},
+		{path: "/tmp/x (deleted)", want: "pid://42/tmp/x%20%28deleted%29#0x1000"},
+		{path: "/tmp/a#b/c?d", want: "pid://42/tmp/a%23b/c%3Fd#0x1000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			uri := sarifURI(42, test.path, 0x1000)
+			assert.Equal(t, test.want, uri)
+
+			// the path survives a round trip, and the fragment is only the map address
+			parsed, err := url.Parse(uri)
+			require.NoError(t, err)
+			assert.Equal(t, "42", parsed.Host)
+			assert.Equal(t, "/"+strings.TrimPrefix(test.path, "/"), parsed.Path)
+			assert.Equal(t, "0x1000", parsed.Fragment)
+		})
+	}
+}
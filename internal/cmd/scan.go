@@ -2,13 +2,27 @@ package cmd
 
 import (
 	"fmt"
-	"strings"
+	"runtime"
 
-	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
 	"github.com/liamg/dismember/pkg/secrets"
 	"github.com/spf13/cobra"
 )
 
+var flagPatternFiles []string
+var flagNoDefaultPatterns bool
+var flagGitleaksConfigs []string
+var flagTrufflehogConfigs []string
+var flagEntropy bool
+var flagSources string
+var flagMaxFileSize string
+var flagBaseline string
+var flagUpdateBaseline bool
+var flagAllowlists []string
+var flagEntropyMinLength int
+var flagEntropyBase64 float64
+var flagEntropyHex float64
+
 func init() {
 
 	scanCmd := &cobra.Command{
@@ -24,63 +38,108 @@ func init() {
 	scanCmd.Flags().IntVarP(&flagDumpRadius, "dump-radius", "r", 2, "The number of lines of memory to dump both above and below each match.")
 	scanCmd.Flags().BoolVarP(&flagIncludeSelf, "self", "s", false, "Include results that are matched against the current process, or an ancestor of that process.")
 	scanCmd.Flags().BoolVarP(&flagFast, "fast", "f", false, "Skip memory-mapped files in order to run faster.")
+	scanCmd.Flags().StringVar(&flagMaxBuffer, "max-buffer", "64M", "The maximum amount of process memory to hold in a buffer at once. Larger memory maps are read in windows of this size.")
+	scanCmd.Flags().StringVar(&flagOverlap, "overlap", "4K", "The number of bytes shared by consecutive windows of memory. Matches longer than this may be missed, or cut short and flagged as truncated, if they cross a window boundary.")
+	scanCmd.Flags().IntVarP(&flagWorkers, "workers", "w", runtime.NumCPU(), "The number of memory regions to search concurrently. The max buffer is shared between all workers.")
+	scanCmd.Flags().StringArrayVar(&flagPatternFiles, "patterns", nil, "Load additional secret patterns from a YAML file. Can be specified multiple times.")
+	scanCmd.Flags().StringArrayVar(&flagGitleaksConfigs, "gitleaks-config", nil, "Load additional secret patterns from the rules in a gitleaks TOML configuration file. Can be specified multiple times.")
+	scanCmd.Flags().StringArrayVar(&flagTrufflehogConfigs, "trufflehog-config", nil, "Load additional secret patterns from the custom detectors in a trufflehog YAML configuration file. Can be specified multiple times.")
+	scanCmd.Flags().BoolVar(&flagNoDefaultPatterns, "no-default-patterns", false, "Don't use the built-in secret patterns, only those loaded from files.")
+	scanCmd.Flags().IntVar(&flagMaxResults, "max-results", 0, "Stop once this many results have been found. 0 means no limit.")
+	scanCmd.Flags().IntVar(&flagMaxResultsPerProcess, "max-results-per-process", 0, "Report at most this many results for each process, skipping the rest of its memory. 0 means no limit.")
+	scanCmd.Flags().BoolVar(&flagValidOnly, "valid-only", false, "Only report secrets which pass offline validation, such as a GitHub token with a correct checksum or an unexpired JWT.")
+	scanCmd.Flags().StringVar(&flagBaseline, "baseline", "", "Only report findings which are not in this baseline file. If the file does not exist, it is created with every finding.")
+	scanCmd.Flags().BoolVar(&flagUpdateBaseline, "update-baseline", false, "Add any new findings to the baseline file.")
+	scanCmd.Flags().StringArrayVar(&flagAllowlists, "allowlist", nil, "Suppress findings which match an entry in this YAML allowlist file. Can be specified multiple times.")
+	scanCmd.Flags().StringVar(&flagSources, "sources", string(scan.SourceMemory), "Comma-separated sources of data to scan for each process: memory, env, cmdline and/or fds (the contents of open files, including deleted files and memfds).")
+	scanCmd.Flags().StringVar(&flagEncodings, "encoding", string(scan.EncodingUTF8), "Comma-separated text encodings to search: utf8, utf16le and/or utf16be. UTF-16 is common in .NET, Java and Windows programs.")
+	scanCmd.Flags().StringVar(&flagMaxFileSize, "max-file-size", "16M", "The size of the largest open file to scan when using the fds source. Larger files are skipped.")
+	scanCmd.Flags().StringVar(&flagRedact, "redact", string(redactNone), "Hide matched secrets in the output: none, full, partial (keep the first and last 4 characters) or hash.")
+	scanCmd.Flags().StringVar(&flagFormat, "format", formatText, "The output format: text, json, ndjson, csv or sarif.")
+	defaults := secrets.DefaultEntropyConfig()
+	scanCmd.Flags().BoolVar(&flagEntropy, "entropy", false, "Also search for generic high-entropy base64, alphanumeric and hex strings.")
+	scanCmd.Flags().IntVar(&flagEntropyMinLength, "entropy-min-length", defaults.MinLength, "The minimum length of strings considered by the entropy detector.")
+	scanCmd.Flags().Float64Var(&flagEntropyBase64, "entropy-threshold-base64", defaults.Base64Threshold, "The minimum entropy (bits per byte) of long base64 and alphanumeric strings reported by the entropy detector. Shorter strings are held to a proportionally lower threshold.")
+	scanCmd.Flags().Float64Var(&flagEntropyHex, "entropy-threshold-hex", defaults.HexThreshold, "The minimum entropy (bits per byte) of long hex strings reported by the entropy detector. Shorter strings are held to a proportionally lower threshold.")
+	addRegionFlags(scanCmd)
+	addImageFlags(scanCmd)
 	rootCmd.AddCommand(scanCmd)
 }
 
 func scanHandler(cmd *cobra.Command, _ []string) error {
 
-	var processes []proc.Process
-
-	if flagPID == 0 {
-		var err error
-		processes, err = proc.List(false)
-		if err != nil {
-			return err
-		}
-	} else {
-		processes = []proc.Process{proc.Process(flagPID)}
+	patterns, err := loadPatterns()
+	if err != nil {
+		return err
 	}
 
-	patterns := secrets.Patterns()
-
-	stdErr := cmd.ErrOrStderr()
-	_ = stdErr
-	stdOut := cmd.OutOrStdout()
-
-	var allResults []GrepResult
+	image, err := openImage()
+	if err != nil {
+		return err
+	}
+	if image != nil {
+		defer func() { _ = image.Close() }()
+	}
 
-	for _, process := range processes {
-		if flagProcessName != "" {
-			status, err := process.Status()
-			if err != nil {
-				logger.Log("failed to determine status for process %s: %s", process, err)
-				continue
-			}
-			if !strings.Contains(status.Name, flagProcessName) {
-				continue
+	options, err := scanOptions(image)
+	if err != nil {
+		return err
+	}
+	sources, err := scan.ParseSources(flagSources)
+	if err != nil {
+		return err
+	}
+	if image != nil {
+		for _, source := range sources {
+			if source != scan.SourceMemory {
+				return fmt.Errorf("the %s source is not available in an image: only memory can be scanned", source)
 			}
 		}
-		if !flagIncludeSelf && process.IsAncestor(proc.Self()) {
-			continue
-		}
-		for _, pattern := range patterns {
-			results, err := grepProcessMemory(process, pattern)
+	}
+	maxFileSize, err := parseSize(flagMaxFileSize)
+	if err != nil {
+		return fmt.Errorf("invalid max file size: %w", err)
+	}
+	options = append(options, scan.WithSources(sources...), scan.WithMaxFileSize(maxFileSize))
+
+	return writeResults(cmd, options, patterns)
+}
+
+// loadPatterns returns the built-in patterns and/or those loaded from pattern files, according to the flags.
+func loadPatterns() ([]secrets.Pattern, error) {
+	var patterns []secrets.Pattern
+	if !flagNoDefaultPatterns {
+		patterns = secrets.Patterns()
+	}
+	for _, source := range []struct {
+		paths  []string
+		loader func(string) ([]secrets.Pattern, error)
+	}{
+		{paths: flagPatternFiles, loader: secrets.LoadPatterns},
+		{paths: flagGitleaksConfigs, loader: secrets.LoadGitleaksConfig},
+		{paths: flagTrufflehogConfigs, loader: secrets.LoadTrufflehogDetectors},
+	} {
+		for _, path := range source.paths {
+			loaded, err := source.loader(path)
 			if err != nil {
-				logger.Log("failed to search memory process %s: %s", process, err)
-				continue
+				return nil, fmt.Errorf("failed to load patterns: %w", err)
 			}
-			allResults = append(allResults, results...)
+			logger.Log("loaded %d pattern(s) from %s", len(loaded), path)
+			patterns = append(patterns, loaded...)
 		}
 	}
-
-	if len(allResults) == 0 {
-		_, _ = fmt.Fprintf(stdOut, "%sOperation Complete. No results found.%s\n\n", ansiRed, ansiReset)
-	} else {
-		for i, result := range allResults {
-			_, _ = fmt.Fprint(stdOut, summariseResult(i+1, result))
+	if flagEntropy {
+		if flagEntropyMinLength < 1 {
+			return nil, fmt.Errorf("entropy minimum length must be at least 1")
 		}
-		_, _ = fmt.Fprintf(stdOut, "%sOperation Complete. %s%d%s%s results found.%s\n\n", ansiGreen, ansiBold, len(allResults), ansiReset, ansiGreen, ansiReset)
+		config := secrets.DefaultEntropyConfig()
+		config.MinLength = flagEntropyMinLength
+		config.Base64Threshold = flagEntropyBase64
+		config.HexThreshold = flagEntropyHex
+		patterns = append(patterns, secrets.EntropyPatterns(config)...)
 	}
-
-	return nil
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no patterns to scan for")
+	}
+	return patterns, nil
 }
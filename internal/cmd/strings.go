@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/liamg/dismember/pkg/extract"
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/spf13/cobra"
+)
+
+var flagMinLength int
+var flagStringEncodings string
+var flagStringRegex string
+
+func init() {
+
+	stringsCmd := &cobra.Command{
+		Use:   "strings [pid]",
+		Short: "List the printable strings in the memory of a process",
+		Long:  `Finds runs of printable ASCII characters in each readable memory map of a process, in the manner of the strings utility, printing each with its virtual address and the path of the map which owns it. UTF-16 strings are found when the characters are within the ASCII range.`,
+		RunE:  stringsHandler,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	stringsCmd.Flags().IntVarP(&flagMinLength, "min-length", "m", extract.DefaultMinLength, "The minimum number of characters in a string.")
+	stringsCmd.Flags().StringVar(&flagStringEncodings, "encoding", fmt.Sprintf("%s,%s", scan.EncodingUTF8, scan.EncodingUTF16LE), "Comma-separated text encodings to find: utf8, utf16le and/or utf16be.")
+	stringsCmd.Flags().StringVarP(&flagStringRegex, "regex", "e", "", "Only list strings which match this regex.")
+	stringsCmd.Flags().StringVar(&flagMaxBuffer, "max-buffer", "64M", "The maximum amount of process memory to hold in a buffer at once. Larger memory maps are read in windows of this size.")
+	stringsCmd.Flags().IntVar(&flagMaxResults, "max-results", 0, "Stop once this many strings have been found. 0 means no limit.")
+	stringsCmd.Flags().StringVar(&flagFormat, "format", formatText, "The output format: text or ndjson.")
+	addRegionFlags(stringsCmd)
+	rootCmd.AddCommand(stringsCmd)
+}
+
+func stringsHandler(cmd *cobra.Command, args []string) error {
+
+	pid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid pid specified: '%s': %w", args[0], err)
+	}
+	maxBuffer, err := parseSize(flagMaxBuffer)
+	if err != nil {
+		return fmt.Errorf("invalid max buffer: %w", err)
+	}
+	encodings, err := scan.ParseEncodings(flagStringEncodings)
+	if err != nil {
+		return err
+	}
+	options := []extract.Option{
+		extract.WithMinLength(flagMinLength),
+		extract.WithEncodings(encodings...),
+		extract.WithWindow(maxBuffer),
+		extract.WithLogger(logger),
+	}
+	if flagStringRegex != "" {
+		regex, err := regexp.Compile(flagStringRegex)
+		if err != nil {
+			return fmt.Errorf("invalid regex pattern: %w", err)
+		}
+		options = append(options, extract.WithRegex(regex))
+	}
+	filters, err := regionFilters()
+	if err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		options = append(options, extract.WithRegionFilter(filter))
+	}
+
+	w := cmd.OutOrStdout()
+	var write func(s extract.String) error
+	switch flagFormat {
+	case formatText:
+		write = func(s extract.String) error {
+			_, err := fmt.Fprintf(w, "%s%016x%s %-7s %s%s%s %s\n", ansiDim, s.Address, ansiReset, s.Encoding, ansiItalic, describePath(s.Map), ansiReset, s.Text)
+			return err
+		}
+	case formatNDJSON:
+		encoder := json.NewEncoder(w)
+		write = func(s extract.String) error {
+			return encoder.Encode(foundString{
+				PID:      uint64(pid),
+				Address:  fmt.Sprintf("0x%x", s.Address),
+				Path:     s.Map.Path,
+				Encoding: s.Encoding,
+				Text:     s.Text,
+			})
+		}
+	default:
+		return fmt.Errorf("unsupported format '%s'", flagFormat)
+	}
+
+	var total int
+	var writeErr error
+	extractErr := extract.New(options...).Extract(cmd.Context(), proc.Live(proc.Process(pid)), func(s extract.String) bool {
+		if writeErr = write(s); writeErr != nil {
+			return false
+		}
+		total++
+		return flagMaxResults <= 0 || total < flagMaxResults
+	})
+	interrupted := cmd.Context().Err() != nil
+	if extractErr != nil && !interrupted {
+		return fmt.Errorf("failed to read strings from process %d: %w", pid, extractErr)
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to write results: %w", writeErr)
+	}
+
+	if err := writeSummary(summaryWriter(cmd), summary{
+		total:       total,
+		noun:        "strings",
+		interrupted: interrupted,
+		limited:     flagMaxResults > 0 && total >= flagMaxResults,
+	}); err != nil {
+		return err
+	}
+	if interrupted {
+		return fmt.Errorf("interrupted")
+	}
+	return nil
+}
+
+// describePath returns the path of a map, or a placeholder for anonymous memory.
+func describePath(m proc.Map) string {
+	if m.Path == "" {
+		return "[anon]"
+	}
+	return m.Path
+}
+
+// foundString is the machine-readable representation of a string found in memory.
+type foundString struct {
+	PID      uint64        `json:"pid"`
+	Address  string        `json:"address"`
+	Path     string        `json:"path,omitempty"`
+	Encoding scan.Encoding `json:"encoding"`
+	Text     string        `json:"text"`
+}
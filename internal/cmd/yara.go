@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/liamg/dismember/pkg/yara"
+	"github.com/spf13/cobra"
+)
+
+var flagMaxMatches int
+
+func init() {
+
+	yaraCmd := &cobra.Command{
+		Use:   "yara [rules file]",
+		Short: "Search process memory using YARA rules",
+		Long:  `Evaluates a practical subset of YARA against the memory of each process: text, hex and regex strings with the nocase, ascii, wide, fullword and private modifiers, and conditions using and/or/not, any/all/none/N of, #count, @offset, !length, at and in. Offsets are virtual addresses.`,
+		RunE:  yaraHandler,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	yaraCmd.Flags().IntVarP(&flagPID, "pid", "p", 0, "PID of the process whose memory should be scanned. Omitting this option will scan the memory of all available processes on the system.")
+	yaraCmd.Flags().StringVarP(&flagProcessName, "process-name", "n", "", "Scan memory of all processes whose name contains this string.")
+	yaraCmd.Flags().BoolVarP(&flagIncludeSelf, "self", "s", false, "Include the current process, and its ancestors, in the scan.")
+	yaraCmd.Flags().BoolVarP(&flagFast, "fast", "f", false, "Skip memory-mapped files in order to run faster.")
+	yaraCmd.Flags().StringVar(&flagMaxBuffer, "max-buffer", "64M", "The maximum amount of process memory to hold in a buffer at once. Larger memory maps are read in windows of this size.")
+	yaraCmd.Flags().StringVar(&flagOverlap, "overlap", "4K", "The number of bytes shared by consecutive windows of memory. Matches longer than this may be missed if they cross a window boundary.")
+	yaraCmd.Flags().IntVarP(&flagWorkers, "workers", "w", runtime.NumCPU(), "The number of processes to scan concurrently. The max buffer is shared between all workers.")
+	yaraCmd.Flags().IntVar(&flagMaxMatches, "max-matches", yara.DefaultMaxMatches, "The maximum number of matches recorded for each string in each process.")
+	yaraCmd.Flags().StringVar(&flagFormat, "format", formatText, "The output format: text, json or ndjson.")
+	addRegionFlags(yaraCmd)
+	rootCmd.AddCommand(yaraCmd)
+}
+
+func yaraHandler(cmd *cobra.Command, args []string) (err error) {
+
+	rules, err := yara.Load(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load rules: %w", err)
+	}
+	logger.Log("loaded %d rule(s) from %s", len(rules.Rules), args[0])
+
+	maxBuffer, overlap, err := parseBufferFlags()
+	if err != nil {
+		return err
+	}
+	// share the memory budget between the workers, reducing the number of workers if necessary
+	workers := flagWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	window := maxBuffer / uint64(workers)
+	for workers > 1 && window <= overlap*2 {
+		workers--
+		window = maxBuffer / uint64(workers)
+	}
+	options := []yara.Option{
+		yara.WithWindow(window, overlap),
+		yara.WithMaxMatches(flagMaxMatches),
+		yara.WithLogger(logger),
+	}
+	filters, err := regionFilters()
+	if err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		options = append(options, yara.WithRegionFilter(filter))
+	}
+	scanner := yara.NewScanner(rules, options...)
+
+	targets, err := scan.New(processOptions()...).Targets()
+	if err != nil {
+		return err
+	}
+
+	var write func(process proc.Process, match yara.Match) error
+	var total int
+	w := cmd.OutOrStdout()
+	switch flagFormat {
+	case formatText:
+		write = func(process proc.Process, match yara.Match) error {
+			_, err := fmt.Fprint(w, summariseRuleMatch(total, process, match))
+			return err
+		}
+	case formatJSON:
+		// the array is closed even if writing fails, so that the output remains valid
+		array := &jsonArray{w: w}
+		defer func() {
+			if closeErr := array.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		write = func(process proc.Process, match yara.Match) error {
+			return array.Write(newRuleFinding(process, match))
+		}
+	case formatNDJSON:
+		encoder := json.NewEncoder(w)
+		write = func(process proc.Process, match yara.Match) error {
+			return encoder.Encode(newRuleFinding(process, match))
+		}
+	default:
+		return fmt.Errorf("unsupported format '%s'", flagFormat)
+	}
+
+	if err := scanTargets(cmd.Context(), scanner, targets, workers, func(target scan.Target, matches []yara.Match) error {
+		for _, match := range matches {
+			total++
+			if err := write(target.Process(), match); err != nil {
+				return fmt.Errorf("failed to write results: %w", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	interrupted := cmd.Context().Err() != nil
+	if err := writeSummary(summaryWriter(cmd), summary{total: total, noun: "rule matches", interrupted: interrupted}); err != nil {
+		return err
+	}
+	if interrupted {
+		return fmt.Errorf("interrupted")
+	}
+	return nil
+}
+
+// scanTargets scans the targets with the rules, running up to the given number of scans at once. fn is called with
+// the matches for each target in turn, in the order of the targets, and never concurrently. Targets which can't
+// be scanned are logged and skipped.
+func scanTargets(ctx context.Context, scanner *yara.Scanner, targets []scan.Target, workers int, fn func(scan.Target, []yara.Match) error) error {
+
+	type outcome struct {
+		matches []yara.Match
+		err     error
+	}
+	outcomes := make([]chan outcome, len(targets))
+	for i := range outcomes {
+		outcomes[i] = make(chan outcome, 1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// a target holds a slot from when it is queued until its matches are delivered, so a slow scan can only hold
+	// back a bounded number of completed ones
+	slots := make(chan struct{}, workers*2)
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range targets {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for i := range jobs {
+				matches, err := scanner.Scan(ctx, targets[i])
+				outcomes[i] <- outcome{matches: matches, err: err}
+			}
+		}()
+	}
+
+	for i, target := range targets {
+		var o outcome
+		select {
+		case o = <-outcomes[i]:
+		case <-ctx.Done():
+			return nil
+		}
+		<-slots
+		if o.err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			logger.Log("failed to scan process %d: %s", target.Process(), o.err)
+			continue
+		}
+		if err := fn(target, o.matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summariseRuleMatch describes a rule which matched a process, including the address of each string match.
+func summariseRuleMatch(number int, process proc.Process, match yara.Match) string {
+	buffer := &strings.Builder{}
+	_, _ = fmt.Fprintf(buffer, "\n %sMatch #%d%s\n\n", ansiUnderline, number, ansiReset)
+	_, _ = fmt.Fprintf(buffer, "  %sRule%s      %s\n", ansiBold, ansiReset, match.Rule.Name)
+	if len(match.Rule.Tags) > 0 {
+		_, _ = fmt.Fprintf(buffer, "  %sTags%s      %s\n", ansiBold, ansiReset, strings.Join(match.Rule.Tags, ", "))
+	}
+	keys := make([]string, 0, len(match.Rule.Meta))
+	for key := range match.Rule.Meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		label := ""
+		if i == 0 {
+			label = "Meta"
+		}
+		_, _ = fmt.Fprintf(buffer, "  %s%-9s%s %s%s:%s %s\n", ansiBold, label, ansiReset, ansiDim, key, ansiReset, match.Rule.Meta[key])
+	}
+	_, _ = fmt.Fprintf(buffer, "  %sProcess%s   %s\n", ansiBold, ansiReset, process.String())
+	if len(match.Strings) > 0 {
+		_, _ = fmt.Fprintf(buffer, "  %sStrings%s\n\n", ansiBold, ansiReset)
+	}
+	for _, s := range match.Strings {
+		_, _ = fmt.Fprintf(buffer, "    %s%-10s%s 0x%x %s%s%s\n", ansiBold, s.String.Identifier, ansiReset, s.Address, ansiDim, s.Map.Path, ansiReset)
+		_, _ = fmt.Fprintf(buffer, "               %s%s%s\n", ansiRed, printable(s.Data), ansiReset)
+	}
+	_, _ = fmt.Fprintln(buffer)
+	return buffer.String()
+}
+
+// ruleFinding is the machine-readable representation of a rule which matched a process.
+type ruleFinding struct {
+	PID     uint64            `json:"pid"`
+	Process string            `json:"process"`
+	Rule    string            `json:"rule"`
+	Tags    []string          `json:"tags,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"`
+	Strings []stringFinding   `json:"strings"`
+}
+
+type stringFinding struct {
+	Identifier string `json:"identifier"`
+	Address    string `json:"address"`
+	Region     string `json:"region"`
+	Length     int    `json:"length"`
+	Data       string `json:"data"`
+	DataBase64 string `json:"data_base64"`
+}
+
+func newRuleFinding(process proc.Process, match yara.Match) ruleFinding {
+	f := ruleFinding{
+		PID:     process.PID(),
+		Process: process.Name(),
+		Rule:    match.Rule.Name,
+		Tags:    match.Rule.Tags,
+		Meta:    match.Rule.Meta,
+		Strings: []stringFinding{},
+	}
+	for _, s := range match.Strings {
+		f.Strings = append(f.Strings, stringFinding{
+			Identifier: s.String.Identifier,
+			Address:    formatAddress(s.Address),
+			Region:     s.Map.Path,
+			Length:     s.Length,
+			Data:       printable(s.Data),
+			DataBase64: base64.StdEncoding.EncodeToString(s.Data),
+		})
+	}
+	return f
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/liamg/dismember/pkg/yara"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenMemory is memory whose maps can't be read.
+type brokenMemory struct {
+	proc.Memory
+}
+
+func (brokenMemory) Maps() (proc.Maps, error) {
+	return nil, errors.New("maps gone")
+}
+
+func imageWith(data string) *proc.Image {
+	return proc.NewImage([]proc.Segment{{
+		Map:  proc.Map{Address: 0x1000, Size: uint64(len(data)), Permissions: proc.MemPerms{Readable: true}},
+		Data: io.NewSectionReader(bytes.NewReader([]byte(data)), 0, int64(len(data))),
+	}}, nil)
+}
+
+func Test_ScanTargets(t *testing.T) {
+
+	rules, err := yara.Compile(`rule acme { strings: $a = "acme_" condition: $a }`)
+	require.NoError(t, err)
+	scanner := yara.NewScanner(rules)
+
+	var targets []scan.Target
+	for pid := 1; pid <= 20; pid++ {
+		data := "nothing here"
+		if pid%2 == 0 {
+			data = "key=acme_0123456789"
+		}
+		targets = append(targets, scan.ImageTarget(imageWith(data), proc.Process(pid), "target"))
+	}
+	targets = append(targets, scan.ImageTarget(brokenMemory{}, proc.Process(21), "broken"))
+
+	t.Run("ordered", func(t *testing.T) {
+		var scanned, matched []proc.Process
+		require.NoError(t, scanTargets(context.Background(), scanner, targets, 4, func(target scan.Target, matches []yara.Match) error {
+			scanned = append(scanned, target.Process())
+			if len(matches) > 0 {
+				matched = append(matched, target.Process())
+			}
+			return nil
+		}))
+
+		// the broken target is skipped, and the rest are delivered in order
+		require.Len(t, scanned, 20)
+		for i, process := range scanned {
+			assert.Equal(t, proc.Process(i+1), process)
+		}
+		assert.Equal(t, []proc.Process{2, 4, 6, 8, 10, 12, 14, 16, 18, 20}, matched)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		failed := errors.New("disk full")
+		var calls int
+		err := scanTargets(context.Background(), scanner, targets, 4, func(scan.Target, []yara.Match) error {
+			calls++
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.NoError(t, scanTargets(ctx, scanner, targets, 4, func(scan.Target, []yara.Match) error {
+			t.Error("unexpected matches")
+			return nil
+		}))
+	})
+}
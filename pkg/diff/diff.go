@@ -0,0 +1,373 @@
+// Package diff compares two snapshots of the memory of a process, reporting the maps which were added, removed or
+// resized, and the byte ranges which changed in the maps found in both.
+package diff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/liamg/dismember/pkg/proc"
+)
+
+const (
+	// DefaultWindow is the default amount of memory read from each snapshot at once.
+	DefaultWindow = 64 * 1024 * 1024
+	// DefaultMaxChanges is the default maximum number of changed ranges reported.
+	DefaultMaxChanges = 1000
+	// DefaultGap is the default number of unchanged bytes which may separate changed bytes in a single range.
+	DefaultGap = 16
+	// DefaultMaxBytes is the default number of bytes kept from each snapshot for each change.
+	DefaultMaxBytes = 256
+	// lineSize is the alignment of the bytes kept for each change, so that they can be shown in a hex dump.
+	lineSize = 16
+)
+
+// MapChangeKind describes how a map differs between snapshots.
+type MapChangeKind string
+
+const (
+	MapAdded   MapChangeKind = "added"
+	MapRemoved MapChangeKind = "removed"
+	MapResized MapChangeKind = "resized"
+)
+
+// MapChange is a map which was added, removed or resized. Old is empty for an added map, and New is empty for a
+// removed map.
+type MapChange struct {
+	Kind MapChangeKind
+	Old  proc.Map
+	New  proc.Map
+}
+
+// Change is a range of bytes which changed in a map found in both snapshots. Bytes which could not be read in
+// either snapshot are not compared.
+type Change struct {
+	Map     proc.Map // Map is the map in the new snapshot
+	Address uint64   // Address is the address of the first changed byte
+	Size    uint64   // Size is the distance from the first changed byte to the last, inclusive
+	// DumpAddress is the address of Old[0] and New[0]. It is aligned to 16 bytes, so that the bytes can be shown in
+	// a hex dump.
+	DumpAddress uint64
+	Old         []byte // Old holds the bytes in the old snapshot, from DumpAddress, limited to the max bytes
+	New         []byte // New holds the bytes in the new snapshot, from DumpAddress, limited to the max bytes
+}
+
+// Result is the difference between two snapshots.
+type Result struct {
+	Maps      []MapChange
+	Changes   []Change
+	Truncated bool // Truncated is true if the maximum number of changes was reached
+}
+
+// Logger receives debug messages from the Differ.
+type Logger interface {
+	Log(format string, args ...interface{})
+}
+
+// Differ compares snapshots of memory.
+type Differ struct {
+	regionFilters []func(proc.Map) (bool, string)
+	window        uint64
+	maxChanges    int
+	gap           uint64
+	maxBytes      uint64
+	logger        Logger
+}
+
+// Option configures a Differ.
+type Option func(d *Differ)
+
+// New creates a Differ.
+func New(options ...Option) *Differ {
+	d := &Differ{
+		window:     DefaultWindow,
+		maxChanges: DefaultMaxChanges,
+		gap:        DefaultGap,
+		maxBytes:   DefaultMaxBytes,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// WithRegionFilter adds a filter which each map must pass to be compared. The filter may be a scan.RegionFilter.
+func WithRegionFilter(filter func(proc.Map) (bool, string)) Option {
+	return func(d *Differ) {
+		d.regionFilters = append(d.regionFilters, filter)
+	}
+}
+
+// WithWindow sets the amount of memory read from each snapshot at once. It is rounded down to a multiple of 16.
+func WithWindow(window uint64) Option {
+	return func(d *Differ) {
+		d.window = window
+	}
+}
+
+// WithMaxChanges sets the maximum number of changed ranges reported. 0 means no limit.
+func WithMaxChanges(n int) Option {
+	return func(d *Differ) {
+		d.maxChanges = n
+	}
+}
+
+// WithGap sets the number of unchanged bytes which may separate changed bytes in a single range.
+func WithGap(gap uint64) Option {
+	return func(d *Differ) {
+		d.gap = gap
+	}
+}
+
+// WithMaxBytes sets the number of bytes kept from each snapshot for each change.
+func WithMaxBytes(n uint64) Option {
+	return func(d *Differ) {
+		d.maxBytes = n
+	}
+}
+
+// WithLogger sets a Logger for debug messages.
+func WithLogger(logger Logger) Option {
+	return func(d *Differ) {
+		d.logger = logger
+	}
+}
+
+// selected returns the maps which are readable and pass every region filter. Other maps are ignored, so that a
+// snapshot saved by dump.Dumper, which only holds such maps, can be compared with a running process.
+func (d *Differ) selected(maps proc.Maps) proc.Maps {
+	var selected proc.Maps
+	for _, m := range maps {
+		if !m.Permissions.Readable {
+			continue
+		}
+		included := true
+		for _, filter := range d.regionFilters {
+			if ok, reason := filter(m); !ok {
+				d.log("skipping memory at %X: %s", m.Address, reason)
+				included = false
+				break
+			}
+		}
+		if included {
+			selected = append(selected, m)
+		}
+	}
+	return selected
+}
+
+// CompareMaps returns the maps which were added, removed or resized, ordered by address. Maps are matched by
+// their start address.
+func CompareMaps(old proc.Maps, new proc.Maps) []MapChange {
+	before := make(map[uint64]proc.Map, len(old))
+	for _, m := range old {
+		before[m.Address] = m
+	}
+	after := make(map[uint64]proc.Map, len(new))
+	for _, m := range new {
+		after[m.Address] = m
+	}
+	var changes []MapChange
+	for _, m := range old {
+		if _, ok := after[m.Address]; !ok {
+			changes = append(changes, MapChange{Kind: MapRemoved, Old: m})
+		}
+	}
+	for _, m := range new {
+		previous, ok := before[m.Address]
+		switch {
+		case !ok:
+			changes = append(changes, MapChange{Kind: MapAdded, New: m})
+		case previous.Size != m.Size:
+			changes = append(changes, MapChange{Kind: MapResized, Old: previous, New: m})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].current().Address < changes[j].current().Address
+	})
+	return changes
+}
+
+// current returns the map as it is in the latest snapshot which contains it.
+func (c MapChange) current() proc.Map {
+	if c.Kind == MapRemoved {
+		return c.Old
+	}
+	return c.New
+}
+
+// Compare compares two snapshots. Only readable maps which pass the region filters are compared, and maps which
+// were resized are compared up to the smaller of their sizes.
+func (d *Differ) Compare(ctx context.Context, old proc.Memory, new proc.Memory) (*Result, error) {
+	window := d.window &^ (lineSize - 1)
+	if window == 0 {
+		return nil, fmt.Errorf("window must be at least %d bytes", lineSize)
+	}
+
+	oldMaps, err := old.Maps()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read old maps: %w", err)
+	}
+	newMaps, err := new.Maps()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read new maps: %w", err)
+	}
+
+	oldMaps, newMaps = d.selected(oldMaps), d.selected(newMaps)
+	result := &Result{Maps: CompareMaps(oldMaps, newMaps)}
+
+	oldReader, err := old.OpenMemory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open old memory: %w", err)
+	}
+	defer func() { _ = oldReader.Close() }()
+	newReader, err := new.OpenMemory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open new memory: %w", err)
+	}
+	defer func() { _ = newReader.Close() }()
+
+	before := make(map[uint64]proc.Map, len(oldMaps))
+	for _, m := range oldMaps {
+		before[m.Address] = m
+	}
+	for _, m := range newMaps {
+		previous, ok := before[m.Address]
+		if !ok {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if err := d.compareRegion(ctx, oldReader, newReader, previous, m, window, result); err != nil {
+			return result, err
+		}
+		if result.Truncated {
+			d.log("reached the maximum of %d change(s), stopping comparison", d.maxChanges)
+			break
+		}
+	}
+	return result, nil
+}
+
+// pending is a change which has been found, but whose end is not yet known.
+type pending struct {
+	start    uint64 // start is the offset of the first changed byte
+	last     uint64 // last is the offset of the last changed byte found so far
+	dump     uint64 // dump is the offset of old[0] and new[0]
+	old, new []byte
+}
+
+// compareRegion compares the part of a map present in both snapshots, adding its changes to the result.
+func (d *Differ) compareRegion(ctx context.Context, oldReader proc.MemoryReader, newReader proc.MemoryReader, previous proc.Map, m proc.Map, window uint64, result *Result) error {
+
+	common := m
+	if previous.Size < common.Size {
+		common.Size = previous.Size
+	}
+	oldRegion, err := proc.NewRegionReader(oldReader, common, window, 0)
+	if err != nil {
+		return err
+	}
+	newRegion, err := proc.NewRegionReader(newReader, common, window, 0)
+	if err != nil {
+		return err
+	}
+
+	var p *pending
+	flush := func() {
+		if d.maxChanges > 0 && len(result.Changes) >= d.maxChanges {
+			result.Truncated = true
+			return
+		}
+		end := (p.last/lineSize + 1) * lineSize
+		if end-p.dump < uint64(len(p.old)) {
+			p.old, p.new = p.old[:end-p.dump], p.new[:end-p.dump]
+		}
+		result.Changes = append(result.Changes, Change{
+			Map:         m,
+			Address:     m.Address + p.start,
+			Size:        p.last - p.start + 1,
+			DumpAddress: m.Address + p.dump,
+			Old:         p.old,
+			New:         p.new,
+		})
+		p = nil
+	}
+	// collect keeps the bytes of the pending change found in the current chunk, up to the given offset
+	collect := func(chunk proc.Chunk, newData []byte, upto uint64) {
+		from := p.dump + uint64(len(p.old))
+		if limit := p.dump + d.maxBytes; upto > limit {
+			upto = limit
+		}
+		if chunkEnd := chunk.Offset + uint64(len(chunk.Data)); upto > chunkEnd {
+			upto = chunkEnd
+		}
+		if from < chunk.Offset || from >= upto {
+			return
+		}
+		p.old = append(p.old, chunk.Data[from-chunk.Offset:upto-chunk.Offset]...)
+		p.new = append(p.new, newData[from-chunk.Offset:upto-chunk.Offset]...)
+	}
+
+	for ctx.Err() == nil && !result.Truncated && oldRegion.Next() {
+		if !newRegion.Next() {
+			break
+		}
+		oldChunk, newChunk := oldRegion.Chunk(), newRegion.Chunk()
+		if len(oldChunk.Data) != len(newChunk.Data) {
+			return fmt.Errorf("snapshots of memory at %X were read in different windows", m.Address)
+		}
+		faults := append(append(proc.Faults(nil), oldChunk.Faults...), newChunk.Faults...)
+		for i := range oldChunk.Data {
+			if oldChunk.Data[i] == newChunk.Data[i] {
+				continue
+			}
+			offset := oldChunk.Offset + uint64(i)
+			if faulted(faults, m.Address+offset) {
+				continue
+			}
+			if p != nil && offset-p.last <= d.gap {
+				p.last = offset
+				continue
+			}
+			if p != nil {
+				collect(oldChunk, newChunk.Data, (p.last/lineSize+1)*lineSize)
+				flush()
+				if result.Truncated {
+					return nil
+				}
+			}
+			p = &pending{start: offset, last: offset, dump: offset &^ (lineSize - 1)}
+		}
+		if p != nil {
+			collect(oldChunk, newChunk.Data, oldChunk.Offset+uint64(len(oldChunk.Data)))
+		}
+	}
+	if p != nil && !result.Truncated {
+		flush()
+	}
+	for _, reader := range []*proc.RegionReader{oldRegion, newRegion} {
+		if err := reader.Err(); err != nil {
+			return fmt.Errorf("failed to read memory at %X: %w", m.Address, err)
+		}
+	}
+	return ctx.Err()
+}
+
+// faulted returns true if the address is within one of the faults.
+func faulted(faults proc.Faults, address uint64) bool {
+	for _, fault := range faults {
+		if address >= fault.Address && address < fault.Address+fault.Size {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Differ) log(format string, args ...interface{}) {
+	if d.logger != nil {
+		d.logger.Log(format, args...)
+	}
+}
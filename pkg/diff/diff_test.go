@@ -0,0 +1,189 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// region is a readable map and its contents.
+type region struct {
+	address    uint64
+	data       []byte
+	path       string
+	unreadable proc.Faults
+}
+
+func snapshot(regions ...region) *proc.Image {
+	var segments []proc.Segment
+	for _, r := range regions {
+		segments = append(segments, proc.Segment{
+			Map:        proc.Map{Address: r.address, Size: uint64(len(r.data)), Permissions: proc.MemPerms{Readable: true, Writable: true}, Path: r.path},
+			Data:       io.NewSectionReader(bytes.NewReader(r.data), 0, int64(len(r.data))),
+			Unreadable: r.unreadable,
+		})
+	}
+	return proc.NewImage(segments, nil)
+}
+
+func modify(data []byte, offset int, replacement string) []byte {
+	modified := append([]byte(nil), data...)
+	copy(modified[offset:], replacement)
+	return modified
+}
+
+func Test_CompareMaps(t *testing.T) {
+	data := make([]byte, 0x100)
+	old := snapshot(
+		region{address: 0x1000, data: data, path: "[heap]"},
+		region{address: 0x2000, data: data, path: "removed"},
+		region{address: 0x4000, data: data},
+	)
+	new := snapshot(
+		region{address: 0x1000, data: make([]byte, 0x200), path: "[heap]"},
+		region{address: 0x3000, data: data, path: "added"},
+		region{address: 0x4000, data: data},
+	)
+
+	result, err := New().Compare(context.Background(), old, new)
+	require.NoError(t, err)
+	require.Len(t, result.Maps, 3)
+	assert.Equal(t, MapResized, result.Maps[0].Kind)
+	assert.Equal(t, uint64(0x100), result.Maps[0].Old.Size)
+	assert.Equal(t, uint64(0x200), result.Maps[0].New.Size)
+	assert.Equal(t, MapRemoved, result.Maps[1].Kind)
+	assert.Equal(t, "removed", result.Maps[1].Old.Path)
+	assert.Equal(t, MapAdded, result.Maps[2].Kind)
+	assert.Equal(t, "added", result.Maps[2].New.Path)
+	assert.Empty(t, result.Changes)
+}
+
+func Test_CompareChanges(t *testing.T) {
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 16)
+
+	tests := []struct {
+		name       string
+		new        []byte
+		unreadable proc.Faults
+		options    []Option
+		changes    []Change
+		truncated  bool
+	}{
+		{
+			name: "unchanged",
+			new:  data,
+		},
+		{
+			name: "single change",
+			new:  modify(data, 0x13, "XY"),
+			changes: []Change{{
+				Address:     0x1013,
+				Size:        2,
+				DumpAddress: 0x1010,
+				Old:         data[0x10:0x20],
+				New:         modify(data, 0x13, "XY")[0x10:0x20],
+			}},
+		},
+		{
+			name: "nearby changes are merged",
+			new:  modify(modify(data, 0x13, "X"), 0x22, "Y"),
+			changes: []Change{{
+				Address:     0x1013,
+				Size:        0x10,
+				DumpAddress: 0x1010,
+				Old:         data[0x10:0x30],
+				New:         modify(modify(data, 0x13, "X"), 0x22, "Y")[0x10:0x30],
+			}},
+		},
+		{
+			name: "distant changes are separate",
+			new:  modify(modify(data, 0x13, "X"), 0x80, "Y"),
+			changes: []Change{
+				{Address: 0x1013, Size: 1, DumpAddress: 0x1010, Old: data[0x10:0x20], New: modify(data, 0x13, "X")[0x10:0x20]},
+				{Address: 0x1080, Size: 1, DumpAddress: 0x1080, Old: data[0x80:0x90], New: modify(data, 0x80, "Y")[0x80:0x90]},
+			},
+		},
+		{
+			name:    "change across windows",
+			new:     modify(data, 0x1e, "XXXX"),
+			options: []Option{WithWindow(0x20)},
+			changes: []Change{{
+				Address:     0x101e,
+				Size:        4,
+				DumpAddress: 0x1010,
+				Old:         data[0x10:0x30],
+				New:         modify(data, 0x1e, "XXXX")[0x10:0x30],
+			}},
+		},
+		{
+			name:    "bytes are limited",
+			new:     modify(data, 0x10, string(bytes.Repeat([]byte("X"), 0x40))),
+			options: []Option{WithMaxBytes(0x20)},
+			changes: []Change{{
+				Address:     0x1010,
+				Size:        0x40,
+				DumpAddress: 0x1010,
+				Old:         data[0x10:0x30],
+				New:         bytes.Repeat([]byte("X"), 0x20),
+			}},
+		},
+		{
+			name:       "unreadable bytes are ignored",
+			new:        modify(modify(data, 0x13, "X"), 0x80, "Y"),
+			unreadable: proc.Faults{{Address: 0x1080, Size: 0x10}},
+			changes: []Change{
+				{Address: 0x1013, Size: 1, DumpAddress: 0x1010, Old: data[0x10:0x20], New: modify(data, 0x13, "X")[0x10:0x20]},
+			},
+		},
+		{
+			name:      "changes are limited",
+			new:       modify(modify(data, 0x13, "X"), 0x80, "Y"),
+			options:   []Option{WithMaxChanges(1)},
+			truncated: true,
+			changes: []Change{
+				{Address: 0x1013, Size: 1, DumpAddress: 0x1010, Old: data[0x10:0x20], New: modify(data, 0x13, "X")[0x10:0x20]},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			old := snapshot(region{address: 0x1000, data: data, path: "[heap]"})
+			new := snapshot(region{address: 0x1000, data: test.new, path: "[heap]", unreadable: test.unreadable})
+
+			result, err := New(test.options...).Compare(context.Background(), old, new)
+			require.NoError(t, err)
+			assert.Empty(t, result.Maps)
+			assert.Equal(t, test.truncated, result.Truncated)
+			require.Len(t, result.Changes, len(test.changes))
+			for i, expected := range test.changes {
+				actual := result.Changes[i]
+				assert.Equal(t, "[heap]", actual.Map.Path)
+				assert.Equal(t, expected.Address, actual.Address)
+				assert.Equal(t, expected.Size, actual.Size)
+				assert.Equal(t, expected.DumpAddress, actual.DumpAddress)
+				assert.Equal(t, expected.Old, actual.Old)
+				assert.Equal(t, expected.New, actual.New)
+			}
+		})
+	}
+}
+
+func Test_CompareRegionFilter(t *testing.T) {
+	data := make([]byte, 0x100)
+	old := snapshot(region{address: 0x1000, data: data, path: "[heap]"}, region{address: 0x2000, data: data, path: "[stack]"})
+	new := snapshot(region{address: 0x1000, data: modify(data, 0, "X"), path: "[heap]"}, region{address: 0x2000, data: modify(data, 0, "X"), path: "[stack]"})
+
+	result, err := New(WithRegionFilter(func(m proc.Map) (bool, string) {
+		return m.Path == "[stack]", "not the stack"
+	})).Compare(context.Background(), old, new)
+	require.NoError(t, err)
+	require.Len(t, result.Changes, 1)
+	assert.Equal(t, uint64(0x2000), result.Changes[0].Address)
+}
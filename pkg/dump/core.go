@@ -0,0 +1,225 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+const (
+	pageSize = 0x1000
+
+	// pnXnum in e_phnum means that the number of program headers is too large for it, and is instead held in the
+	// sh_info field of the first section header
+	pnXnum = 0xffff
+
+	// note types which are not defined by debug/elf
+	ntPRStatus = 1
+	ntPRPSInfo = 3
+	ntAuxv     = 6
+	ntFile     = 0x46494c45
+
+	// offsets within struct elf_prstatus and struct elf_prpsinfo, which are the same on amd64 and arm64
+	prstatusPID    = 32
+	prstatusRegs   = 112
+	prpsinfoSize   = 136
+	prpsinfoUID    = 16
+	prpsinfoPID    = 24
+	prpsinfoFName  = 40
+	prpsinfoArgs   = 56
+	prpsinfoArgLen = 80
+)
+
+// machine describes the registers of an architecture, as stored in NT_PRSTATUS.
+type machine struct {
+	elf       elf.Machine
+	registers int
+	sp        int // sp is the index of the stack pointer register
+	pc        int // pc is the index of the program counter register
+}
+
+var machines = map[string]machine{
+	"amd64": {elf: elf.EM_X86_64, registers: 27, sp: 19, pc: 16},
+	"arm64": {elf: elf.EM_AARCH64, registers: 34, sp: 31, pc: 32},
+}
+
+// WriteCore writes an ELF core file with a PT_LOAD segment for each region, and NT_PRSTATUS, NT_PRPSINFO,
+// NT_AUXV and NT_FILE notes. Registers other than the stack pointer and program counter are not available, and
+// are zero. As in cores written by the kernel, processes with too many maps for e_phnum are described by an
+// extra section header. The returned manifest describes the regions which were written.
+func (d *Dumper) WriteCore(w io.Writer) (*Manifest, error) {
+	arch, ok := machines[runtime.GOARCH]
+	if !ok {
+		return nil, fmt.Errorf("core files are not supported on %s", runtime.GOARCH)
+	}
+	regions := d.regions()
+	notes := d.notes(arch)
+
+	headerSize := uint64(binary.Size(elf.Header64{}))
+	progSize := uint64(binary.Size(elf.Prog64{}))
+	sectionSize := uint64(binary.Size(elf.Section64{}))
+	phnum := uint64(len(regions) + 1)
+	sectionsOffset := headerSize + progSize*phnum
+	notesOffset := sectionsOffset
+	if phnum >= pnXnum {
+		notesOffset += sectionSize
+	}
+	dataOffset := align(notesOffset+uint64(len(notes)), pageSize)
+
+	output := bufio.NewWriter(w)
+	header := elf.Header64{
+		Type:      uint16(elf.ET_CORE),
+		Machine:   uint16(arch.elf),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     headerSize,
+		Ehsize:    uint16(headerSize),
+		Phentsize: uint16(progSize),
+		Phnum:     uint16(phnum),
+	}
+	if phnum >= pnXnum {
+		header.Phnum = pnXnum
+		header.Shoff = sectionsOffset
+		header.Shentsize = uint16(sectionSize)
+		header.Shnum = 1
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	progs := []elf.Prog64{{
+		Type:   uint32(elf.PT_NOTE),
+		Off:    notesOffset,
+		Filesz: uint64(len(notes)),
+		Align:  4,
+	}}
+	offset := dataOffset
+	for _, m := range regions {
+		var flags elf.ProgFlag
+		if m.Permissions.Readable {
+			flags |= elf.PF_R
+		}
+		if m.Permissions.Writable {
+			flags |= elf.PF_W
+		}
+		if m.Permissions.Executable {
+			flags |= elf.PF_X
+		}
+		progs = append(progs, elf.Prog64{
+			Type:   uint32(elf.PT_LOAD),
+			Flags:  uint32(flags),
+			Off:    offset,
+			Vaddr:  m.Address,
+			Filesz: m.Size,
+			Memsz:  m.Size,
+			Align:  pageSize,
+		})
+		offset += m.Size
+	}
+
+	if err := binary.Write(output, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(output, binary.LittleEndian, progs); err != nil {
+		return nil, err
+	}
+	if phnum >= pnXnum {
+		section := elf.Section64{Type: uint32(elf.SHT_NULL), Size: uint64(header.Shnum), Info: uint32(phnum)}
+		if err := binary.Write(output, binary.LittleEndian, section); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := output.Write(notes); err != nil {
+		return nil, err
+	}
+	if _, err := output.Write(make([]byte, dataOffset-notesOffset-uint64(len(notes)))); err != nil {
+		return nil, err
+	}
+
+	manifest := d.manifest()
+	for _, m := range regions {
+		unreadable, err := d.copyRegion(output, m)
+		if err != nil {
+			return nil, err
+		}
+		region := newRegion(m)
+		region.Unreadable = unreadable
+		manifest.Regions = append(manifest.Regions, region)
+	}
+	return manifest, output.Flush()
+}
+
+func align(offset uint64, alignment uint64) uint64 {
+	return (offset + alignment - 1) / alignment * alignment
+}
+
+// notes builds the contents of the PT_NOTE segment.
+func (d *Dumper) notes(arch machine) []byte {
+	buffer := bytes.NewBuffer(nil)
+	writeNote(buffer, "CORE", ntPRStatus, d.prstatus(arch))
+	writeNote(buffer, "CORE", ntPRPSInfo, d.prpsinfo())
+	if len(d.info.Auxv) > 0 {
+		writeNote(buffer, "CORE", ntAuxv, d.info.Auxv)
+	}
+	writeNote(buffer, "CORE", ntFile, d.fileNote())
+	return buffer.Bytes()
+}
+
+// writeNote writes an ELF note. The name and description are padded to 4 bytes.
+func writeNote(buffer *bytes.Buffer, name string, kind uint32, desc []byte) {
+	_ = binary.Write(buffer, binary.LittleEndian, []uint32{uint32(len(name) + 1), uint32(len(desc)), kind})
+	buffer.WriteString(name)
+	buffer.Write(make([]byte, align(uint64(len(name)+1), 4)-uint64(len(name))))
+	buffer.Write(desc)
+	buffer.Write(make([]byte, align(uint64(len(desc)), 4)-uint64(len(desc))))
+}
+
+// prstatus builds a struct elf_prstatus for the main thread.
+func (d *Dumper) prstatus(arch machine) []byte {
+	desc := make([]byte, prstatusRegs+arch.registers*8+8)
+	binary.LittleEndian.PutUint32(desc[prstatusPID:], uint32(d.info.PID))
+	binary.LittleEndian.PutUint32(desc[prstatusPID+4:], uint32(d.info.Parent))
+	binary.LittleEndian.PutUint32(desc[prstatusPID+8:], uint32(d.info.ProcessGroup))
+	binary.LittleEndian.PutUint32(desc[prstatusPID+12:], uint32(d.info.Session))
+	binary.LittleEndian.PutUint64(desc[prstatusRegs+arch.sp*8:], d.info.StackPointer)
+	binary.LittleEndian.PutUint64(desc[prstatusRegs+arch.pc*8:], d.info.ProgramCounter)
+	return desc
+}
+
+// prpsinfo builds a struct elf_prpsinfo, which gdb uses to show the command which generated the core.
+func (d *Dumper) prpsinfo() []byte {
+	desc := make([]byte, prpsinfoSize)
+	desc[0] = d.info.State
+	desc[1] = d.info.State
+	binary.LittleEndian.PutUint32(desc[prpsinfoUID:], d.info.UID)
+	binary.LittleEndian.PutUint32(desc[prpsinfoUID+4:], d.info.GID)
+	binary.LittleEndian.PutUint32(desc[prpsinfoPID:], uint32(d.info.PID))
+	binary.LittleEndian.PutUint32(desc[prpsinfoPID+4:], uint32(d.info.Parent))
+	binary.LittleEndian.PutUint32(desc[prpsinfoPID+8:], uint32(d.info.ProcessGroup))
+	binary.LittleEndian.PutUint32(desc[prpsinfoPID+12:], uint32(d.info.Session))
+	copy(desc[prpsinfoFName:prpsinfoFName+15], d.info.Name)
+	copy(desc[prpsinfoArgs:prpsinfoArgs+prpsinfoArgLen-1], d.info.Args)
+	return desc
+}
+
+// fileNote builds an NT_FILE note listing every file-backed map of the process, so that a debugger can find the
+// executable and shared libraries.
+func (d *Dumper) fileNote() []byte {
+	var files []byte
+	var entries []uint64
+	for _, m := range d.maps {
+		if len(m.Path) == 0 || m.Path[0] != '/' {
+			continue
+		}
+		entries = append(entries, m.Address, m.Address+m.Size, m.Offset/pageSize)
+		files = append(append(files, m.Path...), 0)
+	}
+	buffer := bytes.NewBuffer(nil)
+	_ = binary.Write(buffer, binary.LittleEndian, []uint64{uint64(len(entries) / 3), pageSize})
+	_ = binary.Write(buffer, binary.LittleEndian, entries)
+	buffer.Write(files)
+	return buffer.Bytes()
+}
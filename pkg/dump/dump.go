@@ -0,0 +1,339 @@
+// Package dump saves the memory of a process for offline analysis, either as a directory of raw region files
+// described by a JSON manifest, or as an ELF core file which can be loaded by gdb.
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liamg/dismember/pkg/proc"
+)
+
+const (
+	// DefaultWindow is the default amount of memory read at once.
+	DefaultWindow = 64 * 1024 * 1024
+	// ManifestName is the name of the manifest within a raw dump directory.
+	ManifestName = "manifest.json"
+	// manifestVersion is the version of the manifest format.
+	manifestVersion = 1
+)
+
+// Info describes the process being dumped. Fields which cannot be read are left empty.
+type Info struct {
+	PID            uint64
+	Parent         uint64
+	ProcessGroup   int
+	Session        int
+	Name           string
+	Args           string // Args is the command line, with arguments separated by spaces
+	State          byte
+	UID            uint32
+	GID            uint32
+	StackPointer   uint64
+	ProgramCounter uint64
+	Auxv           []byte
+}
+
+// Describe collects the Info for a process, on a best-effort basis.
+func Describe(p proc.Process) Info {
+	info := Info{PID: p.PID(), Name: p.Name()}
+	if status, err := p.Status(); err == nil {
+		info.Parent = status.Parent.PID()
+		info.ProcessGroup = status.ProcessGroup
+		info.Session = status.Session
+		info.State = byte(status.State)
+	}
+	if cmdline, err := p.Cmdline(); err == nil {
+		info.Args = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	}
+	if owner, err := p.Ownership(); err == nil {
+		info.UID, info.GID = owner.UID, owner.GID
+	}
+	if sp, pc, err := p.Registers(); err == nil {
+		info.StackPointer, info.ProgramCounter = sp, pc
+	}
+	if auxv, err := p.Auxv(); err == nil {
+		info.Auxv = auxv
+	}
+	return info
+}
+
+// Address is a virtual address, written in JSON as a hex string so that it survives tools which use floats.
+type Address uint64
+
+func (a Address) MarshalJSON() ([]byte, error) {
+	return json.Marshal("0x" + strconv.FormatUint(uint64(a), 16))
+}
+
+func (a *Address) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	value, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+	if err != nil {
+		return fmt.Errorf("invalid address '%s': %w", s, err)
+	}
+	*a = Address(value)
+	return nil
+}
+
+// Range is a range of memory.
+type Range struct {
+	Address Address `json:"address"`
+	Size    uint64  `json:"size"`
+}
+
+// Region is a memory map which has been dumped.
+type Region struct {
+	Address     Address `json:"address"`
+	Size        uint64  `json:"size"`
+	Permissions string  `json:"permissions"`
+	Offset      uint64  `json:"offset"`
+	Device      uint64  `json:"device,omitempty"`
+	Inode       uint64  `json:"inode,omitempty"`
+	Path        string  `json:"path"`
+	File        string  `json:"file,omitempty"`       // File is the name of the raw region file, relative to the manifest
+	Unreadable  []Range `json:"unreadable,omitempty"` // Unreadable ranges are zero-filled in the dump
+}
+
+// Manifest describes a dump.
+type Manifest struct {
+	Version int       `json:"version"`
+	PID     uint64    `json:"pid"`
+	Process string    `json:"process"`
+	Created time.Time `json:"created"`
+	Regions []Region  `json:"regions"`
+}
+
+// Unreadable returns the total number of unreadable bytes in the dump.
+func (m *Manifest) Unreadable() uint64 {
+	var total uint64
+	for _, region := range m.Regions {
+		for _, r := range region.Unreadable {
+			total += r.Size
+		}
+	}
+	return total
+}
+
+// Size returns the total size of the regions in the dump.
+func (m *Manifest) Size() uint64 {
+	var total uint64
+	for _, region := range m.Regions {
+		total += region.Size
+	}
+	return total
+}
+
+// Logger receives debug messages from the Dumper.
+type Logger interface {
+	Log(format string, args ...interface{})
+}
+
+// Dumper writes the readable memory maps of a process.
+type Dumper struct {
+	mem           proc.MemoryReader
+	maps          proc.Maps
+	info          Info
+	regionFilters []func(proc.Map) (bool, string)
+	window        uint64
+	maxSize       uint64
+	logger        Logger
+}
+
+// Option configures a Dumper.
+type Option func(d *Dumper)
+
+// New creates a Dumper for the memory of a process, described by its maps and info.
+func New(mem proc.MemoryReader, maps proc.Maps, info Info, options ...Option) *Dumper {
+	d := &Dumper{
+		mem:    mem,
+		maps:   maps,
+		info:   info,
+		window: DefaultWindow,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// WithRegionFilter adds a filter which each map must pass to be dumped. The filter may be a scan.RegionFilter.
+func WithRegionFilter(filter func(proc.Map) (bool, string)) Option {
+	return func(d *Dumper) {
+		d.regionFilters = append(d.regionFilters, filter)
+	}
+}
+
+// WithWindow sets the amount of memory read at once.
+func WithWindow(window uint64) Option {
+	return func(d *Dumper) {
+		d.window = window
+	}
+}
+
+// WithMaxSize caps the total size of the dumped regions. Regions which would exceed the cap are skipped.
+func WithMaxSize(size uint64) Option {
+	return func(d *Dumper) {
+		d.maxSize = size
+	}
+}
+
+// WithLogger sets a Logger for debug messages.
+func WithLogger(logger Logger) Option {
+	return func(d *Dumper) {
+		d.logger = logger
+	}
+}
+
+// regions returns the maps to be dumped: those which are readable, pass the filters and fit within the size cap.
+func (d *Dumper) regions() proc.Maps {
+	var selected proc.Maps
+	var total uint64
+	for _, m := range d.maps {
+		if !m.Permissions.Readable {
+			d.log("skipping memory at %X: memory is not readable", m.Address)
+			continue
+		}
+		included := true
+		for _, filter := range d.regionFilters {
+			if ok, reason := filter(m); !ok {
+				d.log("skipping memory at %X: %s", m.Address, reason)
+				included = false
+				break
+			}
+		}
+		if !included {
+			continue
+		}
+		if d.maxSize > 0 && total+m.Size > d.maxSize {
+			d.log("skipping memory at %X: dump would exceed the maximum size of %d bytes", m.Address, d.maxSize)
+			continue
+		}
+		total += m.Size
+		selected = append(selected, m)
+	}
+	return selected
+}
+
+func (d *Dumper) manifest() *Manifest {
+	return &Manifest{
+		Version: manifestVersion,
+		PID:     d.info.PID,
+		Process: d.info.Name,
+		Created: time.Now().UTC(),
+		Regions: []Region{},
+	}
+}
+
+// copyRegion writes the contents of a map to w, returning the ranges which could not be read.
+func (d *Dumper) copyRegion(w io.Writer, m proc.Map) ([]Range, error) {
+	reader, err := proc.NewRegionReader(d.mem, m, d.window, 0)
+	if err != nil {
+		return nil, err
+	}
+	var unreadable []Range
+	for reader.Next() {
+		chunk := reader.Chunk()
+		for _, fault := range chunk.Faults {
+			d.log("unreadable memory at %X (%d bytes): %s", fault.Address, fault.Size, fault.Err)
+			unreadable = append(unreadable, Range{Address: Address(fault.Address), Size: fault.Size})
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read memory at %X: %w", m.Address, err)
+	}
+	return unreadable, nil
+}
+
+// WriteRaw writes each region to its own file in dir, along with a manifest. The directory is created if it does
+// not exist.
+func (d *Dumper) WriteRaw(dir string) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	manifest := d.manifest()
+	for _, m := range d.regions() {
+		region := newRegion(m)
+		region.File = fmt.Sprintf("%016x-%016x.bin", m.Address, m.Address+m.Size)
+		unreadable, err := d.writeRegionFile(filepath.Join(dir, region.File), m)
+		if err != nil {
+			return nil, err
+		}
+		region.Unreadable = unreadable
+		manifest.Regions = append(manifest.Regions, region)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), data, 0600); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Snapshot reads each region into memory, returning an Image of them along with a manifest. Nothing is written to
+// disk, so the size cap should be set to keep the snapshot within the memory available.
+func (d *Dumper) Snapshot() (*proc.Image, *Manifest, error) {
+	manifest := d.manifest()
+	var segments []proc.Segment
+	for _, m := range d.regions() {
+		buffer := bytes.NewBuffer(make([]byte, 0, m.Size))
+		unreadable, err := d.copyRegion(buffer, m)
+		if err != nil {
+			return nil, nil, err
+		}
+		region := newRegion(m)
+		region.Unreadable = unreadable
+		manifest.Regions = append(manifest.Regions, region)
+
+		segment := proc.Segment{Map: m, Data: io.NewSectionReader(bytes.NewReader(buffer.Bytes()), 0, int64(buffer.Len()))}
+		for _, r := range unreadable {
+			segment.Unreadable = append(segment.Unreadable, proc.Fault{Address: uint64(r.Address), Size: r.Size})
+		}
+		segments = append(segments, segment)
+	}
+	return proc.NewImage(segments, nil), manifest, nil
+}
+
+func (d *Dumper) writeRegionFile(path string, m proc.Map) ([]Range, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	unreadable, err := d.copyRegion(f, m)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return unreadable, err
+}
+
+func newRegion(m proc.Map) Region {
+	return Region{
+		Address:     Address(m.Address),
+		Size:        m.Size,
+		Permissions: m.Permissions.String(),
+		Offset:      m.Offset,
+		Device:      m.Device,
+		Inode:       m.Inode,
+		Path:        m.Path,
+	}
+}
+
+func (d *Dumper) log(format string, args ...interface{}) {
+	if d.logger != nil {
+		d.logger.Log(format, args...)
+	}
+}
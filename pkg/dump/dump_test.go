@@ -0,0 +1,274 @@
+package dump
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMemory holds the contents of each map, and fails to read a single page.
+type fakeMemory struct {
+	data       map[uint64][]byte
+	unreadable uint64
+}
+
+func (f *fakeMemory) ReadAt(buf []byte, address uint64) (proc.Faults, error) {
+	var faults proc.Faults
+	for base, data := range f.data {
+		if address >= base && address < base+uint64(len(data)) {
+			copy(buf, data[address-base:])
+		}
+	}
+	if f.unreadable >= address && f.unreadable < address+uint64(len(buf)) {
+		page := buf[f.unreadable-address:]
+		if len(page) > 0x1000 {
+			page = page[:0x1000]
+		}
+		for i := range page {
+			page[i] = 0
+		}
+		faults = append(faults, proc.Fault{Address: f.unreadable, Size: uint64(len(page)), Err: os.ErrPermission})
+	}
+	return faults, nil
+}
+
+func (f *fakeMemory) Close() error {
+	return nil
+}
+
+func newFake() (*fakeMemory, proc.Maps) {
+	heap := bytes.Repeat([]byte("heap"), 0x800)
+	stack := bytes.Repeat([]byte("stack..."), 0x400)
+	mem := &fakeMemory{
+		data: map[uint64][]byte{
+			0x10000: heap,
+			0x7f000: stack,
+		},
+		unreadable: 0x11000,
+	}
+	maps := proc.Maps{
+		{Address: 0x10000, Size: uint64(len(heap)), Permissions: proc.MemPerms{Readable: true, Writable: true}, Path: "[heap]"},
+		{Address: 0x40000, Size: 0x1000, Permissions: proc.MemPerms{}, Path: "/usr/lib/libc.so.6"},
+		{Address: 0x50000, Size: 0x1000, Permissions: proc.MemPerms{Readable: true, Executable: true}, Offset: 0x2000, Path: "/usr/lib/libc.so.6"},
+		{Address: 0x7f000, Size: uint64(len(stack)), Permissions: proc.MemPerms{Readable: true, Writable: true}, Path: "[stack]"},
+	}
+	mem.data[0x50000] = bytes.Repeat([]byte{0xc3}, 0x1000)
+	return mem, maps
+}
+
+func expected(mem *fakeMemory, m proc.Map) []byte {
+	data := make([]byte, m.Size)
+	copy(data, mem.data[m.Address])
+	if mem.unreadable >= m.Address && mem.unreadable < m.Address+m.Size {
+		copy(data[mem.unreadable-m.Address:], make([]byte, 0x1000))
+	}
+	return data
+}
+
+func Test_WriteRaw(t *testing.T) {
+	mem, maps := newFake()
+	dir := t.TempDir()
+
+	manifest, err := New(mem, maps, Info{PID: 42, Name: "target"}, WithWindow(0x1000)).WriteRaw(dir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	require.NoError(t, err)
+	var saved Manifest
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.Equal(t, uint64(42), saved.PID)
+	assert.Equal(t, "target", saved.Process)
+
+	require.Len(t, saved.Regions, 3)
+	assert.Equal(t, manifest.Regions, saved.Regions)
+	assert.Equal(t, uint64(0x1000), saved.Unreadable())
+	assert.Equal(t, []Range{{Address: 0x11000, Size: 0x1000}}, saved.Regions[0].Unreadable)
+	assert.Equal(t, "r-xp", saved.Regions[1].Permissions)
+	assert.Equal(t, "/usr/lib/libc.so.6", saved.Regions[1].Path)
+
+	for i, region := range saved.Regions {
+		content, err := os.ReadFile(filepath.Join(dir, region.File))
+		require.NoError(t, err)
+		assert.Equal(t, expected(mem, maps[[]int{0, 2, 3}[i]]), content)
+	}
+}
+
+func Test_WriteRawMaxSize(t *testing.T) {
+	mem, maps := newFake()
+
+	manifest, err := New(mem, maps, Info{}, WithMaxSize(0x3000)).WriteRaw(t.TempDir())
+	require.NoError(t, err)
+	require.Len(t, manifest.Regions, 2)
+	assert.Equal(t, Address(0x10000), manifest.Regions[0].Address)
+	assert.Equal(t, Address(0x50000), manifest.Regions[1].Address)
+}
+
+func Test_Snapshot(t *testing.T) {
+	mem, maps := newFake()
+
+	image, manifest, err := New(mem, maps, Info{PID: 42}, WithWindow(0x1000), WithMaxSize(0x3000)).Snapshot()
+	require.NoError(t, err)
+	require.Len(t, manifest.Regions, 2)
+	assert.Equal(t, []Range{{Address: 0x11000, Size: 0x1000}}, manifest.Regions[0].Unreadable)
+
+	snapshotted, err := image.Maps()
+	require.NoError(t, err)
+	assert.Equal(t, proc.Maps{maps[0], maps[2]}, snapshotted)
+	reader, err := image.OpenMemory()
+	require.NoError(t, err)
+
+	for _, m := range snapshotted {
+		data := make([]byte, m.Size)
+		faults, err := reader.ReadAt(data, m.Address)
+		require.NoError(t, err)
+		assert.Equal(t, expected(mem, m), data)
+		if m.Address == 0x10000 {
+			require.Len(t, faults, 1)
+			assert.Equal(t, uint64(0x11000), faults[0].Address)
+		} else {
+			assert.Empty(t, faults)
+		}
+	}
+}
+
+func Test_WriteRawRegionFilter(t *testing.T) {
+	mem, maps := newFake()
+
+	manifest, err := New(mem, maps, Info{}, WithRegionFilter(func(m proc.Map) (bool, string) {
+		return m.Path == "[stack]", "not the stack"
+	})).WriteRaw(t.TempDir())
+	require.NoError(t, err)
+	require.Len(t, manifest.Regions, 1)
+	assert.Equal(t, "[stack]", manifest.Regions[0].Path)
+}
+
+// readCore returns the PT_LOAD segments of a core file, and the contents of its notes by type.
+func readCore(t *testing.T, core []byte) ([]elf.Prog64, map[uint32][]byte) {
+	progs, err := readProgs(bytes.NewReader(core), uint64(len(core)))
+	require.NoError(t, err)
+	var loads []elf.Prog64
+	found := map[uint32][]byte{}
+	for _, prog := range progs {
+		switch elf.ProgType(prog.Type) {
+		case elf.PT_LOAD:
+			loads = append(loads, prog)
+		case elf.PT_NOTE:
+			for _, note := range parseNotes(core[prog.Off : prog.Off+prog.Filesz]) {
+				found[note.kind] = note.desc
+			}
+		}
+	}
+	return loads, found
+}
+
+// assertPRStatus checks the pid, stack pointer and program counter in an NT_PRSTATUS note.
+func assertPRStatus(t *testing.T, found map[uint32][]byte, info Info) {
+	require.Contains(t, found, uint32(ntPRStatus))
+	prstatus := found[ntPRStatus]
+	arch := machines[runtime.GOARCH]
+	assert.Equal(t, uint32(info.PID), binary.LittleEndian.Uint32(prstatus[prstatusPID:]))
+	assert.Equal(t, uint32(info.Parent), binary.LittleEndian.Uint32(prstatus[prstatusPID+4:]))
+	assert.Equal(t, info.StackPointer, binary.LittleEndian.Uint64(prstatus[prstatusRegs+arch.sp*8:]))
+	assert.Equal(t, info.ProgramCounter, binary.LittleEndian.Uint64(prstatus[prstatusRegs+arch.pc*8:]))
+}
+
+func Test_WriteCore(t *testing.T) {
+	if _, ok := machines[runtime.GOARCH]; !ok {
+		t.Skipf("core files are not supported on %s", runtime.GOARCH)
+	}
+	mem, maps := newFake()
+	info := Info{
+		PID:            42,
+		Parent:         1,
+		Name:           "target",
+		Args:           "target --flag",
+		State:          'S',
+		StackPointer:   0x7f100,
+		ProgramCounter: 0x50010,
+		Auxv:           []byte{6, 0, 0, 0, 0, 0, 0, 0, 0, 0x10, 0, 0, 0, 0, 0, 0},
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	manifest, err := New(mem, maps, info, WithWindow(0x1000)).WriteCore(buffer)
+	require.NoError(t, err)
+	require.Len(t, manifest.Regions, 3)
+
+	core, err := elf.NewFile(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, elf.ET_CORE, core.Type)
+	assert.Equal(t, machines[runtime.GOARCH].elf, core.Machine)
+
+	require.Len(t, core.Progs, 4)
+
+	loads, found := readCore(t, buffer.Bytes())
+	require.Len(t, loads, 3)
+	for i, m := range []proc.Map{maps[0], maps[2], maps[3]} {
+		assert.Equal(t, m.Address, loads[i].Vaddr)
+		assert.Equal(t, m.Size, loads[i].Memsz)
+		assert.Zero(t, loads[i].Off%pageSize)
+		assert.Equal(t, expected(mem, m), buffer.Bytes()[loads[i].Off:loads[i].Off+loads[i].Filesz])
+	}
+	assert.Equal(t, elf.PF_R|elf.PF_X, elf.ProgFlag(loads[1].Flags))
+
+	assertPRStatus(t, found, info)
+
+	require.Contains(t, found, uint32(ntPRPSInfo))
+	assert.Equal(t, "target\x00", string(found[ntPRPSInfo][prpsinfoFName:prpsinfoFName+7]))
+
+	assert.Equal(t, info.Auxv, found[ntAuxv])
+
+	require.Contains(t, found, uint32(ntFile))
+	file := found[ntFile]
+	assert.Equal(t, uint64(2), binary.LittleEndian.Uint64(file))
+	assert.Equal(t, uint64(0x50000), binary.LittleEndian.Uint64(file[16+24:]))
+	assert.Equal(t, uint64(2), binary.LittleEndian.Uint64(file[16+24+16:]))
+	assert.Equal(t, "/usr/lib/libc.so.6\x00/usr/lib/libc.so.6\x00", string(file[16+48:]))
+}
+
+func Test_WriteCoreManyRegions(t *testing.T) {
+	if _, ok := machines[runtime.GOARCH]; !ok {
+		t.Skipf("core files are not supported on %s", runtime.GOARCH)
+	}
+
+	// too many maps for e_phnum, which holds at most 0xfffe
+	var maps proc.Maps
+	for i := uint64(0); i < 70000; i++ {
+		maps = append(maps, proc.Map{Address: 0x100000 + i*0x10, Size: 0x10, Permissions: proc.MemPerms{Readable: true}})
+	}
+	mem := &fakeMemory{data: map[uint64][]byte{maps[69999].Address: []byte("last map")}}
+	info := Info{PID: 42, Parent: 1, StackPointer: 0x7ffc0000, ProgramCounter: 0x401000}
+
+	buffer := bytes.NewBuffer(nil)
+	manifest, err := New(mem, maps, info).WriteCore(buffer)
+	require.NoError(t, err)
+	require.Len(t, manifest.Regions, 70000)
+
+	assert.Equal(t, uint16(pnXnum), binary.LittleEndian.Uint16(buffer.Bytes()[56:]))
+
+	loads, found := readCore(t, buffer.Bytes())
+	require.Len(t, loads, 70000)
+	last := loads[len(loads)-1]
+	assert.Equal(t, maps[69999].Address, last.Vaddr)
+	assert.Equal(t, "last map\x00\x00\x00\x00\x00\x00\x00\x00", string(buffer.Bytes()[last.Off:last.Off+last.Filesz]))
+
+	assertPRStatus(t, found, info)
+
+	// the image reader finds every segment too
+	path := filepath.Join(t.TempDir(), "core")
+	require.NoError(t, os.WriteFile(path, buffer.Bytes(), 0600))
+	image, err := Open(path, 0)
+	require.NoError(t, err)
+	defer func() { _ = image.Close() }()
+	assert.Equal(t, uint64(42), image.PID)
+	loaded, _, _ := readImage(t, image)
+	assert.Len(t, loaded, 70000)
+}
@@ -0,0 +1,313 @@
+package dump
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/liamg/dismember/pkg/proc"
+)
+
+// Image is a saved memory image, along with what is known about the process it was taken from.
+type Image struct {
+	*proc.Image
+	PID     uint64 // PID is the PID of the process on the machine where the image was taken, or 0 if unknown
+	Process string // Process is the name of the process, or the name of the image file if unknown
+}
+
+// Open loads a memory image: a raw dump directory or its manifest, an ELF core file, or any other file as a raw
+// blob of memory starting at the given base address. The caller must Close the returned image.
+func Open(path string, base uint64) (*Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return openManifest(filepath.Join(path, ManifestName))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, len(elf.ELFMAG))
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		_ = f.Close()
+		return nil, err
+	}
+	magic = magic[:n]
+	switch {
+	case string(magic) == elf.ELFMAG:
+		return openCore(f)
+	case len(bytes.TrimSpace(magic)) > 0 && bytes.TrimSpace(magic)[0] == '{':
+		_ = f.Close()
+		return openManifest(path)
+	default:
+		return openRaw(f, base, info.Size())
+	}
+}
+
+// files closes a set of files.
+type files []*os.File
+
+func (f files) Close() error {
+	var first error
+	for _, file := range f {
+		if err := file.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func openManifest(path string) (*Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+
+	var opened files
+	var segments []proc.Segment
+	for _, region := range manifest.Regions {
+		perms, err := proc.ParsePermissions(region.Permissions)
+		if err != nil {
+			_ = opened.Close()
+			return nil, err
+		}
+		f, err := os.Open(filepath.Join(filepath.Dir(path), filepath.Clean("/"+region.File)))
+		if err != nil {
+			_ = opened.Close()
+			return nil, fmt.Errorf("failed to open region at %X: %w", uint64(region.Address), err)
+		}
+		opened = append(opened, f)
+		segment := proc.Segment{
+			Map: proc.Map{
+				Address:     uint64(region.Address),
+				Size:        region.Size,
+				Permissions: perms,
+				Offset:      region.Offset,
+				Device:      region.Device,
+				Inode:       region.Inode,
+				Path:        region.Path,
+			},
+			Data: io.NewSectionReader(f, 0, int64(region.Size)),
+		}
+		for _, r := range region.Unreadable {
+			segment.Unreadable = append(segment.Unreadable, proc.Fault{Address: uint64(r.Address), Size: r.Size})
+		}
+		segments = append(segments, segment)
+	}
+	return &Image{
+		Image:   proc.NewImage(segments, opened),
+		PID:     manifest.PID,
+		Process: manifest.Process,
+	}, nil
+}
+
+// maxNotesSize is the size of the largest PT_NOTE segment which will be read from a core file.
+const maxNotesSize = 64 * 1024 * 1024
+
+func openCore(f *os.File) (*Image, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	size := uint64(stat.Size())
+
+	progs, err := readProgs(f, size)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+
+	image := &Image{Process: filepath.Base(f.Name())}
+	var mapped []fileMapping
+	for _, prog := range progs {
+		if elf.ProgType(prog.Type) != elf.PT_NOTE {
+			continue
+		}
+		// the size comes from the file, so must be checked before it is allocated
+		if prog.Off > size || prog.Filesz > size-prog.Off || prog.Filesz > maxNotesSize {
+			_ = f.Close()
+			return nil, fmt.Errorf("invalid core file: notes of %d bytes at offset %d are too large", prog.Filesz, prog.Off)
+		}
+		data := make([]byte, prog.Filesz)
+		if _, err := f.ReadAt(data, int64(prog.Off)); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to read notes: %w", err)
+		}
+		for _, note := range parseNotes(data) {
+			switch note.kind {
+			case ntPRPSInfo:
+				if len(note.desc) >= prpsinfoFName+16 {
+					image.PID = uint64(binary.LittleEndian.Uint32(note.desc[prpsinfoPID:]))
+					image.Process = strings.TrimRight(string(note.desc[prpsinfoFName:prpsinfoFName+16]), "\x00")
+				}
+			case ntFile:
+				mapped = parseFileNote(note.desc)
+			}
+		}
+	}
+
+	var segments []proc.Segment
+	for _, prog := range progs {
+		if elf.ProgType(prog.Type) != elf.PT_LOAD || prog.Memsz == 0 {
+			continue
+		}
+		m := proc.Map{
+			Address: prog.Vaddr,
+			Size:    prog.Memsz,
+			Permissions: proc.MemPerms{
+				Readable:   elf.ProgFlag(prog.Flags)&elf.PF_R != 0,
+				Writable:   elf.ProgFlag(prog.Flags)&elf.PF_W != 0,
+				Executable: elf.ProgFlag(prog.Flags)&elf.PF_X != 0,
+			},
+		}
+		for _, file := range mapped {
+			if file.start == m.Address {
+				m.Path, m.Offset = file.path, file.offset
+				break
+			}
+		}
+		segment := proc.Segment{
+			Map:  m,
+			Data: io.NewSectionReader(f, int64(prog.Off), int64(prog.Filesz)),
+		}
+		// the kernel omits the contents of some maps, such as unmodified file-backed pages
+		if prog.Filesz < prog.Memsz {
+			segment.Unreadable = proc.Faults{{Address: m.Address + prog.Filesz, Size: prog.Memsz - prog.Filesz}}
+		}
+		segments = append(segments, segment)
+	}
+	image.Image = proc.NewImage(segments, f)
+	return image, nil
+}
+
+// readProgs reads the program headers of a 64-bit little-endian core file of the given size. The headers are read
+// directly, rather than through debug/elf, so that the count held in the first section header of a core file with
+// more than 0xfffe segments is understood.
+func readProgs(r io.ReaderAt, size uint64) ([]elf.Prog64, error) {
+	var header elf.Header64
+	if err := binary.Read(io.NewSectionReader(r, 0, int64(size)), binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read ELF header: %w", err)
+	}
+	if elf.Class(header.Ident[elf.EI_CLASS]) != elf.ELFCLASS64 || elf.Data(header.Ident[elf.EI_DATA]) != elf.ELFDATA2LSB {
+		return nil, fmt.Errorf("only 64-bit little-endian core files are supported")
+	}
+	if elf.Type(header.Type) != elf.ET_CORE {
+		return nil, fmt.Errorf("ELF file is not a core file")
+	}
+	progSize := uint64(binary.Size(elf.Prog64{}))
+	if uint64(header.Phentsize) != progSize {
+		return nil, fmt.Errorf("invalid core file: program headers of %d bytes", header.Phentsize)
+	}
+
+	count := uint64(header.Phnum)
+	if count == pnXnum {
+		var section elf.Section64
+		sectionSize := uint64(binary.Size(section))
+		if header.Shnum == 0 || header.Shoff > size || sectionSize > size-header.Shoff {
+			return nil, fmt.Errorf("invalid core file: missing section header for %d or more program headers", pnXnum)
+		}
+		if err := binary.Read(io.NewSectionReader(r, int64(header.Shoff), int64(sectionSize)), binary.LittleEndian, &section); err != nil {
+			return nil, fmt.Errorf("failed to read section header: %w", err)
+		}
+		count = uint64(section.Info)
+	}
+	if header.Phoff > size || count > (size-header.Phoff)/progSize {
+		return nil, fmt.Errorf("invalid core file: %d program headers at offset %d run past the end of the file", count, header.Phoff)
+	}
+
+	progs := make([]elf.Prog64, count)
+	if err := binary.Read(io.NewSectionReader(r, int64(header.Phoff), int64(count*progSize)), binary.LittleEndian, progs); err != nil {
+		return nil, fmt.Errorf("failed to read program headers: %w", err)
+	}
+	return progs, nil
+}
+
+func openRaw(f *os.File, base uint64, size int64) (*Image, error) {
+	segment := proc.Segment{
+		Map: proc.Map{
+			Address:     base,
+			Size:        uint64(size),
+			Permissions: proc.MemPerms{Readable: true},
+		},
+		Data: io.NewSectionReader(f, 0, size),
+	}
+	return &Image{
+		Image:   proc.NewImage([]proc.Segment{segment}, f),
+		Process: filepath.Base(f.Name()),
+	}, nil
+}
+
+type note struct {
+	kind uint32
+	desc []byte
+}
+
+// parseNotes splits the contents of a PT_NOTE segment into notes. A truncated note ends the list.
+func parseNotes(data []byte) []note {
+	var notes []note
+	for len(data) >= 12 {
+		nameSize := align(uint64(binary.LittleEndian.Uint32(data)), 4)
+		descSize := uint64(binary.LittleEndian.Uint32(data[4:]))
+		kind := binary.LittleEndian.Uint32(data[8:])
+		start := 12 + nameSize
+		if start+descSize > uint64(len(data)) {
+			break
+		}
+		notes = append(notes, note{kind: kind, desc: data[start : start+descSize]})
+		next := start + align(descSize, 4)
+		if next > uint64(len(data)) {
+			break
+		}
+		data = data[next:]
+	}
+	return notes
+}
+
+type fileMapping struct {
+	start  uint64
+	offset uint64
+	path   string
+}
+
+// parseFileNote reads the file-backed maps listed in an NT_FILE note.
+func parseFileNote(desc []byte) []fileMapping {
+	if len(desc) < 16 {
+		return nil
+	}
+	count := binary.LittleEndian.Uint64(desc)
+	pageSize := binary.LittleEndian.Uint64(desc[8:])
+	if count > uint64(len(desc)-16)/24 {
+		return nil
+	}
+	paths := strings.Split(string(desc[16+count*24:]), "\x00")
+	if uint64(len(paths)) < count {
+		return nil
+	}
+	mappings := make([]fileMapping, 0, count)
+	for i := uint64(0); i < count; i++ {
+		entry := desc[16+i*24:]
+		mappings = append(mappings, fileMapping{
+			start:  binary.LittleEndian.Uint64(entry),
+			offset: binary.LittleEndian.Uint64(entry[16:]) * pageSize,
+			path:   paths[i],
+		})
+	}
+	return mappings
+}
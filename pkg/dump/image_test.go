@@ -0,0 +1,142 @@
+package dump
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readImage reads the whole of each map in an image, returning the data and faults by address.
+func readImage(t *testing.T, image *Image) (proc.Maps, map[uint64][]byte, proc.Faults) {
+	maps, err := image.Maps()
+	require.NoError(t, err)
+	mem, err := image.OpenMemory()
+	require.NoError(t, err)
+	defer func() { _ = mem.Close() }()
+	contents := make(map[uint64][]byte)
+	var faults proc.Faults
+	for _, m := range maps {
+		buf := make([]byte, m.Size)
+		found, err := mem.ReadAt(buf, m.Address)
+		require.NoError(t, err)
+		faults = append(faults, found...)
+		contents[m.Address] = buf
+	}
+	return maps, contents, faults
+}
+
+func Test_OpenRawDump(t *testing.T) {
+	mem, maps := newFake()
+	dir := t.TempDir()
+	_, err := New(mem, maps, Info{PID: 42, Name: "target"}).WriteRaw(dir)
+	require.NoError(t, err)
+
+	for _, path := range []string{dir, filepath.Join(dir, ManifestName)} {
+		image, err := Open(path, 0)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(42), image.PID)
+		assert.Equal(t, "target", image.Process)
+
+		loaded, contents, faults := readImage(t, image)
+		require.Len(t, loaded, 3)
+		for _, m := range []proc.Map{maps[0], maps[2], maps[3]} {
+			assert.Equal(t, expected(mem, m), contents[m.Address])
+		}
+		assert.Equal(t, maps[2], loaded[1])
+		require.Len(t, faults, 1)
+		assert.Equal(t, uint64(0x11000), faults[0].Address)
+		require.NoError(t, image.Close())
+	}
+}
+
+func Test_OpenCore(t *testing.T) {
+	if _, ok := machines[runtime.GOARCH]; !ok {
+		t.Skipf("core files are not supported on %s", runtime.GOARCH)
+	}
+	mem, maps := newFake()
+	path := filepath.Join(t.TempDir(), "core")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	info := Info{PID: 42, Parent: 1, Name: "target", StackPointer: 0x7f100, ProgramCounter: 0x50010}
+	_, err = New(mem, maps, info).WriteCore(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	image, err := Open(path, 0)
+	require.NoError(t, err)
+	defer func() { _ = image.Close() }()
+	assert.Equal(t, uint64(42), image.PID)
+	assert.Equal(t, "target", image.Process)
+
+	loaded, contents, _ := readImage(t, image)
+	require.Len(t, loaded, 3)
+	for _, m := range []proc.Map{maps[0], maps[2], maps[3]} {
+		assert.Equal(t, expected(mem, m), contents[m.Address])
+	}
+	assert.Equal(t, "/usr/lib/libc.so.6", loaded[1].Path)
+	assert.Equal(t, uint64(0x2000), loaded[1].Offset)
+	assert.Equal(t, "r-xp", loaded[1].Permissions.String())
+
+	core, err := os.ReadFile(path)
+	require.NoError(t, err)
+	_, found := readCore(t, core)
+	assertPRStatus(t, found, info)
+}
+
+func Test_OpenCoreOversizedNotes(t *testing.T) {
+	if _, ok := machines[runtime.GOARCH]; !ok {
+		t.Skipf("core files are not supported on %s", runtime.GOARCH)
+	}
+	mem, maps := newFake()
+	buffer := bytes.NewBuffer(nil)
+	_, err := New(mem, maps, Info{PID: 42, Name: "target"}).WriteCore(buffer)
+	require.NoError(t, err)
+
+	progs, err := readProgs(bytes.NewReader(buffer.Bytes()), uint64(buffer.Len()))
+	require.NoError(t, err)
+	index := -1
+	for i, prog := range progs {
+		if elf.ProgType(prog.Type) == elf.PT_NOTE {
+			index = i
+		}
+	}
+	require.NotEqual(t, -1, index)
+	phoff := binary.LittleEndian.Uint64(buffer.Bytes()[32:])
+
+	for _, size := range []uint64{uint64(buffer.Len()), 1 << 40} {
+		data := append([]byte(nil), buffer.Bytes()...)
+		// p_filesz is 32 bytes into each 56 byte program header
+		binary.LittleEndian.PutUint64(data[phoff+uint64(index)*56+32:], size)
+		path := filepath.Join(t.TempDir(), "core")
+		require.NoError(t, os.WriteFile(path, data, 0600))
+
+		_, err := Open(path, 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "notes of")
+	}
+}
+
+func Test_OpenRawBlob(t *testing.T) {
+	data := bytes.Repeat([]byte("blob"), 100)
+	path := filepath.Join(t.TempDir(), "memory.bin")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	image, err := Open(path, 0x400000)
+	require.NoError(t, err)
+	defer func() { _ = image.Close() }()
+	assert.Equal(t, "memory.bin", image.Process)
+
+	loaded, contents, faults := readImage(t, image)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, uint64(0x400000), loaded[0].Address)
+	assert.Equal(t, data, contents[0x400000])
+	assert.Empty(t, faults)
+}
@@ -0,0 +1,252 @@
+// Package extract finds printable strings in the memory of a process, in the manner of the strings utility.
+package extract
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+)
+
+const (
+	// DefaultMinLength is the default minimum number of characters in a string.
+	DefaultMinLength = 4
+	// DefaultWindow is the default amount of memory read at once.
+	DefaultWindow = 64 * 1024 * 1024
+)
+
+// String is a run of printable ASCII characters found in memory.
+type String struct {
+	Map      proc.Map
+	Address  uint64
+	Encoding scan.Encoding
+	Text     string // Text is the string decoded to ASCII
+}
+
+// Logger receives debug messages from the Extractor.
+type Logger interface {
+	Log(format string, args ...interface{})
+}
+
+// Extractor finds strings in memory.
+type Extractor struct {
+	minLength     int
+	encodings     []scan.Encoding
+	regex         *regexp.Regexp
+	regionFilters []func(proc.Map) (bool, string)
+	window        uint64
+	logger        Logger
+}
+
+// Option configures an Extractor.
+type Option func(e *Extractor)
+
+// New creates an Extractor. By default it finds UTF-8 strings of at least DefaultMinLength characters.
+func New(options ...Option) *Extractor {
+	e := &Extractor{
+		minLength: DefaultMinLength,
+		encodings: []scan.Encoding{scan.EncodingUTF8},
+		window:    DefaultWindow,
+	}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+// WithMinLength sets the minimum number of characters in a string.
+func WithMinLength(n int) Option {
+	return func(e *Extractor) {
+		e.minLength = n
+	}
+}
+
+// WithEncodings sets the encodings in which strings are found. Only the ASCII subset of each encoding is
+// considered printable, and UTF-16 strings must be aligned to 2 bytes.
+func WithEncodings(encodings ...scan.Encoding) Option {
+	return func(e *Extractor) {
+		e.encodings = encodings
+	}
+}
+
+// WithRegex only reports strings which match the regex.
+func WithRegex(regex *regexp.Regexp) Option {
+	return func(e *Extractor) {
+		e.regex = regex
+	}
+}
+
+// WithRegionFilter adds a filter which each map must pass to be read. The filter may be a scan.RegionFilter.
+func WithRegionFilter(filter func(proc.Map) (bool, string)) Option {
+	return func(e *Extractor) {
+		e.regionFilters = append(e.regionFilters, filter)
+	}
+}
+
+// WithWindow sets the amount of memory read at once. It is rounded down to an even number of bytes.
+func WithWindow(window uint64) Option {
+	return func(e *Extractor) {
+		e.window = window
+	}
+}
+
+// WithLogger sets a Logger for debug messages.
+func WithLogger(logger Logger) Option {
+	return func(e *Extractor) {
+		e.logger = logger
+	}
+}
+
+// Extract finds the strings in each readable map of the memory, calling fn for each in order of address within
+// each window of memory. It stops early if fn returns false.
+func (e *Extractor) Extract(ctx context.Context, mem proc.Memory, fn func(String) bool) error {
+	if e.minLength < 1 {
+		return fmt.Errorf("minimum length must be at least 1")
+	}
+	window := e.window &^ 1
+	if window == 0 {
+		return fmt.Errorf("window must be at least 2 bytes")
+	}
+
+	maps, err := mem.Maps()
+	if err != nil {
+		return fmt.Errorf("failed to read maps: %w", err)
+	}
+	reader, err := mem.OpenMemory()
+	if err != nil {
+		return fmt.Errorf("failed to open memory: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	for _, m := range maps {
+		if !e.include(m) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		more, err := e.extractRegion(ctx, reader, m, window, fn)
+		if err != nil {
+			e.log("failed to read memory at %X: %s", m.Address, err)
+		}
+		if !more {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+func (e *Extractor) include(m proc.Map) bool {
+	if !m.Permissions.Readable {
+		e.log("skipping memory at %X: memory is not readable", m.Address)
+		return false
+	}
+	for _, filter := range e.regionFilters {
+		if ok, reason := filter(m); !ok {
+			e.log("skipping memory at %X: %s", m.Address, reason)
+			return false
+		}
+	}
+	return true
+}
+
+// run is a string which is being read, and may continue into the next window.
+type run struct {
+	offset uint64 // offset of the first character from the start of the map
+	text   []byte
+}
+
+// extractRegion finds the strings in a map. It returns false if fn asked to stop.
+func (e *Extractor) extractRegion(ctx context.Context, reader proc.MemoryReader, m proc.Map, window uint64, fn func(String) bool) (bool, error) {
+	region, err := proc.NewRegionReader(reader, m, window, 0)
+	if err != nil {
+		return true, err
+	}
+
+	runs := make([]run, len(e.encodings))
+	var found []String
+	end := func(i int) {
+		if len(runs[i].text) >= e.minLength && (e.regex == nil || e.regex.Match(runs[i].text)) {
+			found = append(found, String{
+				Map:      m,
+				Address:  m.Address + runs[i].offset,
+				Encoding: e.encodings[i],
+				Text:     string(runs[i].text),
+			})
+		}
+		runs[i].text = runs[i].text[:0]
+	}
+	deliver := func() bool {
+		sort.SliceStable(found, func(i, j int) bool {
+			return found[i].Address < found[j].Address
+		})
+		for _, s := range found {
+			if !fn(s) {
+				return false
+			}
+		}
+		found = found[:0]
+		return true
+	}
+
+	for ctx.Err() == nil && region.Next() {
+		chunk := region.Chunk()
+		for i, encoding := range e.encodings {
+			size := encoding.UnitSize()
+			for pos := 0; pos+size <= len(chunk.Data); pos += size {
+				c, ok := printable(chunk.Data[pos:pos+size], encoding)
+				if !ok {
+					if len(runs[i].text) > 0 {
+						end(i)
+					}
+					continue
+				}
+				if len(runs[i].text) == 0 {
+					runs[i].offset = chunk.Offset + uint64(pos)
+				}
+				runs[i].text = append(runs[i].text, c)
+			}
+		}
+		if !deliver() {
+			return false, nil
+		}
+	}
+	for i := range runs {
+		if len(runs[i].text) > 0 {
+			end(i)
+		}
+	}
+	if !deliver() {
+		return false, nil
+	}
+	return true, region.Err()
+}
+
+// printable returns the ASCII character held in a code unit, if it is printable. Tabs are printable.
+func printable(unit []byte, encoding scan.Encoding) (byte, bool) {
+	var c byte
+	switch encoding {
+	case scan.EncodingUTF16LE:
+		if unit[1] != 0 {
+			return 0, false
+		}
+		c = unit[0]
+	case scan.EncodingUTF16BE:
+		if unit[0] != 0 {
+			return 0, false
+		}
+		c = unit[1]
+	default:
+		c = unit[0]
+	}
+	return c, c == '\t' || (c >= ' ' && c <= '~')
+}
+
+func (e *Extractor) log(format string, args ...interface{}) {
+	if e.logger != nil {
+		e.logger.Log(format, args...)
+	}
+}
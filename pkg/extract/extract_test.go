@@ -0,0 +1,123 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/scan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func image(regions map[uint64][]byte, paths map[uint64]string) *proc.Image {
+	var segments []proc.Segment
+	for address, data := range regions {
+		segments = append(segments, proc.Segment{
+			Map:  proc.Map{Address: address, Size: uint64(len(data)), Permissions: proc.MemPerms{Readable: true}, Path: paths[address]},
+			Data: io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))),
+		})
+	}
+	return proc.NewImage(segments, nil)
+}
+
+func collect(t *testing.T, e *Extractor, mem proc.Memory) []String {
+	var found []String
+	require.NoError(t, e.Extract(context.Background(), mem, func(s String) bool {
+		found = append(found, s)
+		return true
+	}))
+	return found
+}
+
+func Test_Extract(t *testing.T) {
+
+	heap := []byte("\x00\x01hello world\x00ab\x00x\x00\x00tab\there\xff")
+	wide := []byte("\x00\x00p\x00a\x00s\x00s\x00w\x00d\x00\x00\x00s\x00e\x00c\x00r\x00e\x00t\x00")
+	mem := image(map[uint64][]byte{0x1000: heap, 0x2000: wide}, map[uint64]string{0x1000: "[heap]"})
+
+	tests := []struct {
+		name     string
+		options  []Option
+		expected []String
+	}{
+		{
+			name: "defaults",
+			expected: []String{
+				{Address: 0x1002, Encoding: scan.EncodingUTF8, Text: "hello world"},
+				{Address: 0x1014, Encoding: scan.EncodingUTF8, Text: "tab\there"},
+			},
+		},
+		{
+			name:    "minimum length",
+			options: []Option{WithMinLength(2)},
+			expected: []String{
+				{Address: 0x1002, Encoding: scan.EncodingUTF8, Text: "hello world"},
+				{Address: 0x100e, Encoding: scan.EncodingUTF8, Text: "ab"},
+				{Address: 0x1014, Encoding: scan.EncodingUTF8, Text: "tab\there"},
+			},
+		},
+		{
+			name:    "utf-16",
+			options: []Option{WithEncodings(scan.EncodingUTF8, scan.EncodingUTF16LE)},
+			expected: []String{
+				{Address: 0x1002, Encoding: scan.EncodingUTF8, Text: "hello world"},
+				{Address: 0x1014, Encoding: scan.EncodingUTF8, Text: "tab\there"},
+				{Address: 0x2002, Encoding: scan.EncodingUTF16LE, Text: "passwd"},
+				{Address: 0x2010, Encoding: scan.EncodingUTF16LE, Text: "secret"},
+			},
+		},
+		{
+			name:    "regex",
+			options: []Option{WithEncodings(scan.EncodingUTF8, scan.EncodingUTF16LE), WithRegex(regexp.MustCompile(`^(hello|secret)`))},
+			expected: []String{
+				{Address: 0x1002, Encoding: scan.EncodingUTF8, Text: "hello world"},
+				{Address: 0x2010, Encoding: scan.EncodingUTF16LE, Text: "secret"},
+			},
+		},
+		{
+			name: "region filter",
+			options: []Option{WithRegionFilter(func(m proc.Map) (bool, string) {
+				return m.Path == "[heap]", "not the heap"
+			})},
+			expected: []String{
+				{Address: 0x1002, Encoding: scan.EncodingUTF8, Text: "hello world"},
+				{Address: 0x1014, Encoding: scan.EncodingUTF8, Text: "tab\there"},
+			},
+		},
+		{
+			name:    "strings across windows",
+			options: []Option{WithWindow(4)},
+			expected: []String{
+				{Address: 0x1002, Encoding: scan.EncodingUTF8, Text: "hello world"},
+				{Address: 0x1014, Encoding: scan.EncodingUTF8, Text: "tab\there"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found := collect(t, New(test.options...), mem)
+			require.Len(t, found, len(test.expected))
+			for i, expected := range test.expected {
+				assert.Equal(t, expected.Address, found[i].Address)
+				assert.Equal(t, expected.Encoding, found[i].Encoding)
+				assert.Equal(t, expected.Text, found[i].Text)
+			}
+		})
+	}
+}
+
+func Test_ExtractStops(t *testing.T) {
+	mem := image(map[uint64][]byte{0x1000: []byte("first\x00second\x00third")}, nil)
+
+	var found []string
+	require.NoError(t, New().Extract(context.Background(), mem, func(s String) bool {
+		found = append(found, s.Text)
+		return len(found) < 2
+	}))
+	assert.Equal(t, []string{"first", "second"}, found)
+}
@@ -0,0 +1,367 @@
+// Package hexpattern matches sequences of bytes described in the style of YARA hex strings, e.g.
+// "4D 5A ?? [2-4] (00 | FF) E?". Bytes may contain nibble wildcards (?), jumps skip a fixed or variable
+// number of bytes, and alternatives match any one of several sequences.
+package hexpattern
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type kind int
+
+const (
+	kindByte kind = iota
+	kindJump
+	kindAlternatives
+)
+
+// unbounded marks a jump with no upper bound, e.g. [4-].
+const unbounded = -1
+
+// MaxUnboundedJump is the most bytes skipped by a jump with no upper bound, e.g. [4-]. It matches the default
+// overlap between the windows of memory searched by grep, as a match longer than the overlap may be missed when it
+// crosses a window boundary anyway.
+const MaxUnboundedJump = 4096
+
+type element struct {
+	kind         kind
+	value        byte // value is the byte to match, after masking
+	mask         byte // mask selects the bits of the byte which must match
+	min          int
+	max          int
+	alternatives [][]element
+}
+
+// Pattern is a compiled hex pattern.
+type Pattern struct {
+	source     string
+	elements   []element
+	atom       []byte // atom is the longest run of literal bytes at a fixed offset from the start of a match
+	atomOffset int
+}
+
+// MustCompile is like Compile, but panics if the expression is invalid.
+func MustCompile(expr string) *Pattern {
+	p, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Compile parses a hex pattern. The surrounding braces used by YARA are optional.
+func Compile(expr string) (*Pattern, error) {
+	source := strings.TrimSpace(expr)
+	input := source
+	if strings.HasPrefix(input, "{") && strings.HasSuffix(input, "}") {
+		input = input[1 : len(input)-1]
+	}
+	p := &parser{input: input}
+	elements, err := p.sequence(false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex pattern '%s': %w", source, err)
+	}
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("invalid hex pattern '%s': unexpected '%c' at position %d", source, p.input[p.pos], p.pos+1)
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("invalid hex pattern '%s': pattern is empty", source)
+	}
+	if elements[0].kind == kindJump || elements[len(elements)-1].kind == kindJump {
+		return nil, fmt.Errorf("invalid hex pattern '%s': pattern cannot start or end with a jump", source)
+	}
+	pattern := &Pattern{source: source, elements: elements}
+	pattern.atom, pattern.atomOffset = findAtom(elements)
+	return pattern, nil
+}
+
+// String returns the source of the pattern.
+func (p *Pattern) String() string {
+	return p.source
+}
+
+// Match returns true if the pattern matches anywhere in data.
+func (p *Pattern) Match(data []byte) bool {
+	return len(p.FindAllIndex(data, 1)) > 0
+}
+
+// FindAllIndex returns the start and end offsets of successive non-overlapping matches in data. If n >= 0, at
+// most n matches are returned. Jumps match as few bytes as possible, and unbounded jumps skip at most
+// MaxUnboundedJump bytes.
+func (p *Pattern) FindAllIndex(data []byte, n int) [][]int {
+	var matches [][]int
+	m := &matcher{data: data, failed: make(map[int]*span)}
+	for start := 0; start <= len(data) && (n < 0 || len(matches) < n); {
+		candidate, ok := p.next(data, start)
+		if !ok {
+			break
+		}
+		end, ok := m.match(candidate, p.elements, nil)
+		if !ok {
+			start = candidate + 1
+			continue
+		}
+		matches = append(matches, []int{candidate, end})
+		if end > candidate {
+			start = end
+		} else {
+			start = candidate + 1
+		}
+	}
+	return matches
+}
+
+// next returns the first offset at or after start where a match could begin, using the atom to skip ahead.
+func (p *Pattern) next(data []byte, start int) (int, bool) {
+	if len(p.atom) == 0 {
+		return start, start < len(data)
+	}
+	from := start + p.atomOffset
+	if from > len(data) {
+		return 0, false
+	}
+	index := bytes.Index(data[from:], p.atom)
+	if index < 0 {
+		return 0, false
+	}
+	return from + index - p.atomOffset, true
+}
+
+// span is an inclusive range of offsets.
+type span struct {
+	from, to int
+}
+
+// matcher matches the elements of a pattern against data. Jumps are only allowed at the top level of a pattern,
+// so the elements after a jump are identified by how many of them remain, and whether they match at an offset
+// does not depend on where the match started. For each jump, matcher records the latest span of offsets at which
+// the elements after it are known not to match, so that each offset is only tried once as the candidates for the
+// start of a match advance through the data, rather than once for every candidate within reach of the jump.
+type matcher struct {
+	data   []byte
+	failed map[int]*span // failed maps the number of elements after a jump to the offsets at which they don't match
+}
+
+// match attempts to match the elements, followed by the rest, at pos. It returns the end of the match.
+func (m *matcher) match(pos int, elements []element, rest [][]element) (int, bool) {
+	data := m.data
+	for i, e := range elements {
+		switch e.kind {
+		case kindByte:
+			if pos >= len(data) || data[pos]&e.mask != e.value {
+				return 0, false
+			}
+			pos++
+		case kindJump:
+			max := e.max
+			if max == unbounded {
+				max = MaxUnboundedJump
+				if max < e.min {
+					max = e.min
+				}
+			}
+			if pos+max > len(data) {
+				max = len(data) - pos
+			}
+			following := elements[i+1:]
+			failed, ok := m.failed[len(following)]
+			if !ok {
+				failed = &span{from: -1, to: -1}
+				m.failed[len(following)] = failed
+			}
+			for skip := e.min; skip <= max; skip++ {
+				offset := pos + skip
+				if offset >= failed.from && offset <= failed.to {
+					skip = failed.to - pos
+					continue
+				}
+				if end, ok := m.match(offset, following, rest); ok {
+					return end, true
+				}
+				switch {
+				case offset == failed.to+1:
+					failed.to = offset
+				case offset == failed.from-1:
+					failed.from = offset
+				default:
+					failed.from, failed.to = offset, offset
+				}
+			}
+			return 0, false
+		case kindAlternatives:
+			remaining := append([][]element{elements[i+1:]}, rest...)
+			for _, alternative := range e.alternatives {
+				if end, ok := m.match(pos, alternative, remaining); ok {
+					return end, true
+				}
+			}
+			return 0, false
+		}
+	}
+	if len(rest) > 0 {
+		return m.match(pos, rest[0], rest[1:])
+	}
+	return pos, true
+}
+
+// findAtom returns the longest run of fully-specified bytes which is at a fixed offset from the start of a match.
+func findAtom(elements []element) ([]byte, int) {
+	var best []byte
+	var bestOffset int
+	var current []byte
+	var offset int
+	for i, e := range elements {
+		if e.kind != kindByte {
+			break
+		}
+		if e.mask == 0xff {
+			if len(current) == 0 {
+				offset = i
+			}
+			current = append(current, e.value)
+			if len(current) > len(best) {
+				best, bestOffset = current, offset
+			}
+			continue
+		}
+		current = nil
+	}
+	return best, bestOffset
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// sequence parses elements until the end of the input, or the end of an alternative if nested is true.
+func (p *parser) sequence(nested bool) ([]element, error) {
+	var elements []element
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.input) {
+			return elements, nil
+		}
+		switch c := p.input[p.pos]; {
+		case c == '|' || c == ')':
+			if !nested {
+				return nil, fmt.Errorf("unexpected '%c' at position %d", c, p.pos+1)
+			}
+			return elements, nil
+		case c == '(':
+			alternatives, err := p.alternatives()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, alternatives)
+		case c == '[':
+			if nested {
+				return nil, fmt.Errorf("jumps are not supported inside alternatives, at position %d", p.pos+1)
+			}
+			jump, err := p.jump()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, jump)
+		default:
+			b, err := p.byte()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, b)
+		}
+	}
+}
+
+func (p *parser) alternatives() (element, error) {
+	start := p.pos
+	p.pos++ // (
+	e := element{kind: kindAlternatives}
+	for {
+		alternative, err := p.sequence(true)
+		if err != nil {
+			return e, err
+		}
+		if len(alternative) == 0 {
+			return e, fmt.Errorf("empty alternative at position %d", p.pos+1)
+		}
+		e.alternatives = append(e.alternatives, alternative)
+		if p.pos >= len(p.input) {
+			return e, fmt.Errorf("unclosed '(' at position %d", start+1)
+		}
+		c := p.input[p.pos]
+		p.pos++
+		if c == ')' {
+			break
+		}
+	}
+	if len(e.alternatives) < 2 {
+		return e, fmt.Errorf("alternatives at position %d must contain a '|'", start+1)
+	}
+	return e, nil
+}
+
+func (p *parser) jump() (element, error) {
+	start := p.pos
+	end := strings.IndexByte(p.input[p.pos:], ']')
+	if end < 0 {
+		return element{}, fmt.Errorf("unclosed '[' at position %d", start+1)
+	}
+	body := strings.ReplaceAll(p.input[p.pos+1:p.pos+end], " ", "")
+	p.pos += end + 1
+
+	e := element{kind: kindJump}
+	var err error
+	lower, upper, isRange := strings.Cut(body, "-")
+	if lower != "" {
+		if e.min, err = strconv.Atoi(lower); err != nil || e.min < 0 {
+			return e, fmt.Errorf("invalid jump '[%s]' at position %d", body, start+1)
+		}
+	}
+	switch {
+	case !isRange:
+		if lower == "" {
+			return e, fmt.Errorf("invalid jump '[%s]' at position %d", body, start+1)
+		}
+		e.max = e.min
+	case upper == "":
+		e.max = unbounded
+	default:
+		if e.max, err = strconv.Atoi(upper); err != nil || e.max < e.min {
+			return e, fmt.Errorf("invalid jump '[%s]' at position %d", body, start+1)
+		}
+	}
+	return e, nil
+}
+
+func (p *parser) byte() (element, error) {
+	if p.pos+1 >= len(p.input) {
+		return element{}, fmt.Errorf("incomplete byte at position %d", p.pos+1)
+	}
+	e := element{kind: kindByte}
+	for i := 0; i < 2; i++ {
+		c := p.input[p.pos+i]
+		e.value <<= 4
+		e.mask <<= 4
+		if c == '?' {
+			continue
+		}
+		nibble, err := strconv.ParseUint(string(c), 16, 8)
+		if err != nil {
+			return e, fmt.Errorf("unexpected '%c' at position %d", c, p.pos+i+1)
+		}
+		e.value |= byte(nibble)
+		e.mask |= 0xf
+	}
+	p.pos += 2
+	return e, nil
+}
@@ -0,0 +1,128 @@
+package hexpattern
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FindAllIndex(t *testing.T) {
+
+	data := []byte{0x00, 0xde, 0xad, 0xbe, 0xef, 0x11, 0xde, 0xad, 0x00, 0xef, 0x4d, 0x5a, 0x90, 0x00, 0x03, 0xff}
+
+	tests := []struct {
+		expr string
+		want [][]int
+	}{
+		{expr: "DE AD BE EF", want: [][]int{{1, 5}}},
+		{expr: "de ad ?? ef", want: [][]int{{1, 5}, {6, 10}}},
+		{expr: "{ DEAD??EF }", want: [][]int{{1, 5}, {6, 10}}},
+		{expr: "DE A? B? EF", want: [][]int{{1, 5}}},
+		{expr: "?E AD", want: [][]int{{1, 3}, {6, 8}}},
+		{expr: "DE [2] EF", want: [][]int{{1, 5}, {6, 10}}},
+		{expr: "DE [1-3] EF", want: [][]int{{1, 5}, {6, 10}}},
+		{expr: "DE [4-] 4D", want: [][]int{{1, 11}}},
+		{expr: "4D 5A (90 | 91) 00", want: [][]int{{10, 14}}},
+		{expr: "DE AD (BE EF | 00 ??) ", want: [][]int{{1, 5}, {6, 10}}},
+		{expr: "?? 4D 5A", want: [][]int{{9, 12}}},
+		{expr: "DE AD BE EE", want: nil},
+		{expr: "03 FF ??", want: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			pattern, err := Compile(test.expr)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, pattern.FindAllIndex(data, -1))
+		})
+	}
+}
+
+func Test_FindAllIndexLimit(t *testing.T) {
+	pattern := MustCompile("AA")
+	assert.Len(t, pattern.FindAllIndex([]byte{0xaa, 0xaa, 0xaa}, 2), 2)
+	assert.True(t, pattern.Match([]byte{0x00, 0xaa}))
+	assert.False(t, pattern.Match([]byte{0x00, 0xab}))
+}
+
+func Test_UnboundedJumpIsCapped(t *testing.T) {
+	pattern := MustCompile("AA [4-] BB")
+
+	near := append(append([]byte{0xaa}, make([]byte, 100)...), 0xbb)
+	assert.Equal(t, [][]int{{0, 102}}, pattern.FindAllIndex(near, -1))
+
+	far := append(append([]byte{0xaa}, make([]byte, MaxUnboundedJump+1)...), 0xbb)
+	assert.Empty(t, pattern.FindAllIndex(far, -1))
+}
+
+// Test_JumpsInLargeBuffers checks that jumps don't retry the same offsets for every candidate start of a match,
+// which would take hours on buffers full of candidates.
+func Test_JumpsInLargeBuffers(t *testing.T) {
+
+	tests := []struct {
+		name string
+		expr string
+		data []byte
+		want [][]int
+	}{
+		{
+			name: "unbounded jump",
+			expr: "AA [0-] BB",
+			data: bytes.Repeat([]byte{0xaa}, 8<<20),
+		},
+		{
+			name: "nested unbounded jumps",
+			expr: "AA [1-] BB [0-] CC",
+			data: bytes.Repeat([]byte{0xaa, 0xbb}, 4<<20),
+		},
+		{
+			name: "wide bounded jump",
+			expr: "AA [0-100000] BB",
+			data: bytes.Repeat([]byte{0xaa}, 8<<20),
+		},
+		{
+			name: "match after two capped jumps",
+			expr: "AA [0-] BB [0-] CC",
+			data: append(bytes.Repeat([]byte{0xaa, 0xbb}, 4<<20), 0xcc),
+			want: [][]int{{8<<20 - 2 - 2*MaxUnboundedJump, 8<<20 + 1}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			done := make(chan [][]int)
+			go func() {
+				done <- MustCompile(test.expr).FindAllIndex(test.data, -1)
+			}()
+			select {
+			case matches := <-done:
+				assert.Equal(t, test.want, matches)
+			case <-time.After(10 * time.Second):
+				t.Fatal("search did not finish in time")
+			}
+		})
+	}
+}
+
+func Test_CompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"DE A",
+		"DE AG",
+		"[2] DE",
+		"DE [2]",
+		"DE [3-1] AD",
+		"DE [x] AD",
+		"DE (AD | ) EF",
+		"DE (AD BE",
+		"DE (AD) EF",
+		"DE | AD",
+		"DE (AD [2] BE | EF) 00",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Compile(expr)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -2,8 +2,13 @@ package proc
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
 // Files discovers a list of all files being accessed the Process.
@@ -23,3 +28,74 @@ func (p *Process) Files() ([]string, error) {
 	}
 	return matches, nil
 }
+
+// FileDescriptor is a file held open by a process.
+type FileDescriptor struct {
+	FD      int
+	Path    string // Path is the target of the fd link, e.g. "/tmp/creds (deleted)" or "/memfd:name (deleted)"
+	Regular bool
+	Size    uint64
+	Device  uint64
+	Inode   uint64
+}
+
+// Deleted returns true if the file has been unlinked from the filesystem, but is still open.
+func (f FileDescriptor) Deleted() bool {
+	return strings.HasSuffix(f.Path, " (deleted)")
+}
+
+// Memfd returns true if the file is an anonymous memory-backed file created with memfd_create(2).
+func (f FileDescriptor) Memfd() bool {
+	return strings.HasPrefix(f.Path, "/memfd:")
+}
+
+// FileDescriptors returns the open file descriptors of the process, ordered by number. Descriptors which
+// can't be inspected, e.g. because they were closed while being listed, are skipped.
+func (p *Process) FileDescriptors() ([]FileDescriptor, error) {
+	base := fmt.Sprintf("/proc/%d/fd", p.PID())
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil, err
+	}
+	var descriptors []FileDescriptor
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		link, err := os.Readlink(filepath.Join(base, entry.Name()))
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(base, entry.Name()))
+		if err != nil {
+			continue
+		}
+		descriptor := FileDescriptor{
+			FD:      fd,
+			Path:    link,
+			Regular: info.Mode().IsRegular(),
+			Size:    uint64(info.Size()),
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			descriptor.Device = uint64(stat.Dev)
+			descriptor.Inode = stat.Ino
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	sort.Slice(descriptors, func(i, j int) bool {
+		return descriptors[i].FD < descriptors[j].FD
+	})
+	return descriptors, nil
+}
+
+// ReadFileDescriptor reads up to limit bytes from the start of a file held open by the process. This works even
+// if the file has been deleted, or was never on disk, as with a memfd.
+func (p *Process) ReadFileDescriptor(fd int, limit uint64) ([]byte, error) {
+	f, err := p.openFile("fd", strconv.Itoa(fd))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return io.ReadAll(io.LimitReader(f, int64(limit)))
+}
@@ -0,0 +1,41 @@
+package proc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FileDescriptors(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.WriteFile(path, []byte("password=hunter2"), 0o600))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	require.NoError(t, os.Remove(path))
+
+	self := Self()
+	descriptors, err := self.FileDescriptors()
+	require.NoError(t, err)
+
+	var found *FileDescriptor
+	for i, descriptor := range descriptors {
+		if descriptor.FD == int(f.Fd()) {
+			found = &descriptors[i]
+		}
+	}
+	require.NotNil(t, found)
+	assert.True(t, found.Regular)
+	assert.True(t, found.Deleted())
+	assert.False(t, found.Memfd())
+	assert.Equal(t, uint64(16), found.Size)
+	assert.NotZero(t, found.Inode)
+
+	data, err := self.ReadFileDescriptor(found.FD, 8)
+	require.NoError(t, err)
+	assert.Equal(t, "password", string(data))
+}
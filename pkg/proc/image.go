@@ -0,0 +1,122 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+var (
+	errNotMapped  = errors.New("address is not mapped in the image")
+	errUnreadable = errors.New("memory was not readable when the image was taken")
+)
+
+// Segment is a memory map within an Image, along with its contents.
+type Segment struct {
+	Map Map
+	// Data holds the contents of the map, from its first byte. If Data is shorter than the map, the rest is zero.
+	Data *io.SectionReader
+	// Unreadable ranges could not be read when the image was taken. They are zero-filled, and reported as Faults.
+	Unreadable Faults
+}
+
+// Image is memory loaded from a saved image, such as a dump or a core file, rather than from a running process.
+type Image struct {
+	segments []Segment
+	closer   io.Closer
+}
+
+// NewImage creates an Image from its segments. The closer, if any, is closed by Image.Close.
+func NewImage(segments []Segment, closer io.Closer) *Image {
+	sorted := append([]Segment(nil), segments...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Map.Address < sorted[j].Map.Address
+	})
+	return &Image{segments: sorted, closer: closer}
+}
+
+// Maps returns the memory maps of the image.
+func (i *Image) Maps() (Maps, error) {
+	maps := make(Maps, 0, len(i.segments))
+	for _, segment := range i.segments {
+		maps = append(maps, segment.Map)
+	}
+	return maps, nil
+}
+
+// OpenMemory opens the image for reading. Closing the returned reader does not close the image.
+func (i *Image) OpenMemory() (MemoryReader, error) {
+	return &imageReader{image: i}, nil
+}
+
+// Close closes the files holding the image.
+func (i *Image) Close() error {
+	if i.closer == nil {
+		return nil
+	}
+	return i.closer.Close()
+}
+
+// segment returns the index of the first segment which ends after the given address.
+func (i *Image) segment(address uint64) int {
+	return sort.Search(len(i.segments), func(n int) bool {
+		m := i.segments[n].Map
+		return m.Address+m.Size > address
+	})
+}
+
+type imageReader struct {
+	image *Image
+}
+
+func (r *imageReader) ReadAt(buf []byte, address uint64) (Faults, error) {
+	zero(buf)
+	var faults Faults
+	end := address + uint64(len(buf))
+	for pos := address; pos < end; {
+		index := r.image.segment(pos)
+		if index == len(r.image.segments) || r.image.segments[index].Map.Address >= end {
+			faults = faults.add(pos, end-pos, errNotMapped)
+			break
+		}
+		segment := r.image.segments[index]
+		if segment.Map.Address > pos {
+			faults = faults.add(pos, segment.Map.Address-pos, errNotMapped)
+			pos = segment.Map.Address
+		}
+		stop := segment.Map.Address + segment.Map.Size
+		if stop > end {
+			stop = end
+		}
+		offset := pos - segment.Map.Address
+		if available := uint64(segment.Data.Size()); offset < available {
+			size := stop - pos
+			if size > available-offset {
+				size = available - offset
+			}
+			if _, err := segment.Data.ReadAt(buf[pos-address:pos-address+size], int64(offset)); err != nil && err != io.EOF {
+				return nil, fmt.Errorf("failed to read image at %X: %w", pos, err)
+			}
+		}
+		for _, fault := range segment.Unreadable {
+			from, to := fault.Address, fault.Address+fault.Size
+			if from < pos {
+				from = pos
+			}
+			if to > stop {
+				to = stop
+			}
+			if from < to {
+				zero(buf[from-address : to-address])
+				faults = faults.add(from, to-from, errUnreadable)
+			}
+		}
+		pos = stop
+	}
+	return faults, nil
+}
+
+func (r *imageReader) Close() error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package proc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ImageReadAt(t *testing.T) {
+
+	first := bytes.Repeat([]byte{0xaa}, 0x2000)
+	second := bytes.Repeat([]byte{0xbb}, 0x800)
+	image := NewImage([]Segment{
+		{
+			Map:  Map{Address: 0x5000, Size: 0x1000, Permissions: MemPerms{Readable: true}},
+			Data: io.NewSectionReader(bytes.NewReader(second), 0, int64(len(second))),
+		},
+		{
+			Map:        Map{Address: 0x1000, Size: 0x2000, Permissions: MemPerms{Readable: true}, Path: "[heap]"},
+			Data:       io.NewSectionReader(bytes.NewReader(first), 0, int64(len(first))),
+			Unreadable: Faults{{Address: 0x2000, Size: 0x100}},
+		},
+	}, nil)
+
+	maps, err := image.Maps()
+	require.NoError(t, err)
+	require.Len(t, maps, 2)
+	assert.Equal(t, uint64(0x1000), maps[0].Address)
+	assert.Equal(t, "[heap]", maps[0].Path)
+
+	mem, err := image.OpenMemory()
+	require.NoError(t, err)
+	defer func() { _ = mem.Close() }()
+
+	tests := []struct {
+		name     string
+		address  uint64
+		size     int
+		expected func(buf []byte)
+		faults   []Fault
+	}{
+		{
+			name:    "within a segment",
+			address: 0x1800,
+			size:    0x100,
+			expected: func(buf []byte) {
+				copy(buf, first)
+			},
+		},
+		{
+			name:    "unreadable range",
+			address: 0x1f00,
+			size:    0x200,
+			expected: func(buf []byte) {
+				copy(buf[:0x100], first)
+			},
+			faults: []Fault{{Address: 0x2000, Size: 0x100, Err: errUnreadable}},
+		},
+		{
+			name:    "across a gap",
+			address: 0x2f00,
+			size:    0x2200,
+			expected: func(buf []byte) {
+				copy(buf[:0x100], first)
+				copy(buf[0x2100:], second)
+			},
+			faults: []Fault{{Address: 0x3000, Size: 0x2000, Err: errNotMapped}},
+		},
+		{
+			name:     "beyond the data of a segment",
+			address:  0x5700,
+			size:     0x200,
+			expected: func(buf []byte) { copy(buf[:0x100], second) },
+		},
+		{
+			name:     "after the last segment",
+			address:  0x6000,
+			size:     0x10,
+			expected: func([]byte) {},
+			faults:   []Fault{{Address: 0x6000, Size: 0x10, Err: errNotMapped}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := bytes.Repeat([]byte{0xff}, test.size)
+			faults, err := mem.ReadAt(buf, test.address)
+			require.NoError(t, err)
+			expected := make([]byte, test.size)
+			test.expected(expected)
+			assert.Equal(t, expected, buf)
+			assert.Equal(t, Faults(test.faults), faults)
+		})
+	}
+}
@@ -60,7 +60,7 @@ func parseMaps(data []byte) (Maps, error) {
 		}
 		m.Address = start
 		m.Size = end - start
-		m.Permissions, err = parsePermissions(fields[1])
+		m.Permissions, err = ParsePermissions(fields[1])
 		if err != nil {
 			return nil, err
 		}
@@ -82,7 +82,66 @@ func parseMaps(data []byte) (Maps, error) {
 	return maps, nil
 }
 
-func parsePermissions(s string) (MemPerms, error) {
+// MapKind describes what a memory map is used for.
+type MapKind string
+
+const (
+	MapKindHeap  MapKind = "heap"
+	MapKindStack MapKind = "stack"
+	MapKindAnon  MapKind = "anon"
+	MapKindFile  MapKind = "file"
+	MapKindVDSO  MapKind = "vdso"
+	MapKindOther MapKind = "other"
+)
+
+// Find returns the map containing the given address.
+func (m Maps) Find(address uint64) (Map, bool) {
+	for _, candidate := range m {
+		if address >= candidate.Address && address-candidate.Address < candidate.Size {
+			return candidate, true
+		}
+	}
+	return Map{}, false
+}
+
+// Kind returns the kind of the map, based on its path.
+func (m Map) Kind() MapKind {
+	switch {
+	case m.Path == "[heap]":
+		return MapKindHeap
+	case m.Path == "[stack]" || strings.HasPrefix(m.Path, "[stack:"):
+		return MapKindStack
+	case m.Path == "" || strings.HasPrefix(m.Path, "[anon:"):
+		return MapKindAnon
+	case m.Path == "[vdso]" || m.Path == "[vvar]" || m.Path == "[vsyscall]":
+		return MapKindVDSO
+	case strings.HasPrefix(m.Path, "/"):
+		return MapKindFile
+	default:
+		return MapKindOther
+	}
+}
+
+// String returns the permissions in the format used by /proc/[pid]/maps, e.g. "rw-p".
+func (m MemPerms) String() string {
+	perms := []byte("---p")
+	if m.Readable {
+		perms[0] = 'r'
+	}
+	if m.Writable {
+		perms[1] = 'w'
+	}
+	if m.Executable {
+		perms[2] = 'x'
+	}
+	if m.Shared {
+		perms[3] = 's'
+	}
+	return string(perms)
+}
+
+// ParsePermissions parses permissions in the format used by /proc/[pid]/maps, e.g. "rw-p".
+func ParsePermissions(s string) (MemPerms, error) {
 	var perms MemPerms
 	if len(s) != 4 {
 		return perms, fmt.Errorf("invalid permissions: %s", s)
@@ -1,6 +1,7 @@
 package proc
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -109,3 +110,55 @@ ffffffffff600000-ffffffffff601000 --xp 00000000 00:00 0                  [vsysca
 		})
 	}
 }
+
+func Test_MemPermsString(t *testing.T) {
+	for _, input := range []string{"r--p", "rw-p", "r-xp", "rw-s", "---p", "rwxs"} {
+		t.Run(input, func(t *testing.T) {
+			perms, err := ParsePermissions(input)
+			require.NoError(t, err)
+			assert.Equal(t, input, perms.String())
+		})
+	}
+}
+
+func Test_MapKind(t *testing.T) {
+	tests := map[string]MapKind{
+		"[heap]":                 MapKindHeap,
+		"[stack]":                MapKindStack,
+		"[stack:1234]":           MapKindStack,
+		"":                       MapKindAnon,
+		"[anon:scudo:primary]":   MapKindAnon,
+		"[vdso]":                 MapKindVDSO,
+		"[vvar]":                 MapKindVDSO,
+		"/usr/lib/libc.so.6":     MapKindFile,
+		"/memfd:vault (deleted)": MapKindFile,
+		"[uprobes]":              MapKindOther,
+	}
+	for path, kind := range tests {
+		t.Run(path, func(t *testing.T) {
+			assert.Equal(t, kind, Map{Path: path}.Kind())
+		})
+	}
+}
+
+func Test_MapsFind(t *testing.T) {
+	maps := Maps{
+		{Address: 0x1000, Size: 0x1000, Path: "[heap]"},
+		{Address: 0x3000, Size: 0x2000, Path: "[stack]"},
+	}
+	tests := map[uint64]string{
+		0x0fff: "",
+		0x1000: "[heap]",
+		0x1fff: "[heap]",
+		0x2000: "",
+		0x4fff: "[stack]",
+		0x5000: "",
+	}
+	for address, path := range tests {
+		t.Run(fmt.Sprintf("%x", address), func(t *testing.T) {
+			m, ok := maps.Find(address)
+			assert.Equal(t, path != "", ok)
+			assert.Equal(t, path, m.Path)
+		})
+	}
+}
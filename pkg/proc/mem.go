@@ -1,25 +1,382 @@
 package proc
 
-// ReadMemory reads the memory of the process for the given memory Map.
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// MemoryBackend is a mechanism for reading the memory of another process. Writes use the matching mechanism,
+// process_vm_writev or /proc/[pid]/mem.
+type MemoryBackend uint8
+
+const (
+	// MemoryBackendAuto uses process_vm_readv, falling back to /proc/[pid]/mem if it is refused.
+	MemoryBackendAuto MemoryBackend = iota
+	// MemoryBackendProcessVM reads memory using the process_vm_readv syscall.
+	MemoryBackendProcessVM
+	// MemoryBackendProcMem reads memory from /proc/[pid]/mem.
+	MemoryBackendProcMem
+)
+
+// String returns a string representation of the backend.
+func (b MemoryBackend) String() string {
+	switch b {
+	case MemoryBackendAuto:
+		return "auto"
+	case MemoryBackendProcessVM:
+		return "process_vm_readv"
+	case MemoryBackendProcMem:
+		return "/proc/[pid]/mem"
+	default:
+		return "unknown"
+	}
+}
+
+// Fault is a range of memory which could not be read.
+type Fault struct {
+	Address uint64
+	Size    uint64
+	Err     error
+}
+
+// Faults is a list of unreadable memory ranges.
+type Faults []Fault
+
+// Size returns the total number of unreadable bytes.
+func (f Faults) Size() uint64 {
+	var total uint64
+	for _, fault := range f {
+		total += fault.Size
+	}
+	return total
+}
+
+// add records an unreadable range, merging it with the previous range if they are contiguous.
+func (f Faults) add(address uint64, size uint64, err error) Faults {
+	if len(f) > 0 {
+		last := &f[len(f)-1]
+		if last.Address+last.Size == address {
+			last.Size += size
+			return f
+		}
+	}
+	return append(f, Fault{Address: address, Size: size, Err: err})
+}
+
+// MemoryReader reads the virtual memory of a process.
+type MemoryReader interface {
+	// ReadAt fills buf with the memory found at the given virtual address. Pages which cannot be read are
+	// zero-filled and reported as Faults rather than failing the entire read. An error is returned only if
+	// the memory of the process cannot be accessed at all.
+	ReadAt(buf []byte, address uint64) (Faults, error)
+	io.Closer
+}
+
+// Memory is a source of memory which can be read: a running process, or a saved Image.
+type Memory interface {
+	// Maps returns the memory maps.
+	Maps() (Maps, error)
+	// OpenMemory opens the memory for reading. The caller must Close the returned reader.
+	OpenMemory() (MemoryReader, error)
+}
+
+// Live returns the Memory of a running process, read using the auto backend.
+func Live(p Process) Memory {
+	return liveMemory{process: p}
+}
+
+type liveMemory struct {
+	process Process
+}
+
+func (l liveMemory) Maps() (Maps, error) {
+	return l.process.Maps()
+}
+
+func (l liveMemory) OpenMemory() (MemoryReader, error) {
+	return l.process.OpenMemory(MemoryBackendAuto)
+}
+
+// OpenMemory opens the memory of the process for reading using the given backend.
+// The caller must Close the returned reader when done.
+func (p *Process) OpenMemory(backend MemoryBackend) (MemoryReader, error) {
+	switch backend {
+	case MemoryBackendAuto:
+		return &autoMemoryReader{process: *p, current: newProcessVMReader(*p)}, nil
+	case MemoryBackendProcessVM:
+		return newProcessVMReader(*p), nil
+	case MemoryBackendProcMem:
+		return newProcMemReader(*p)
+	default:
+		return nil, fmt.Errorf("unsupported memory backend: %d", backend)
+	}
+}
+
+// ReadMemory reads the memory of the process for the given memory Map. If size is 0, the remainder of the Map
+// is read. Unreadable pages are zero-filled, and an error is returned only if none of the memory could be read.
 func (p *Process) ReadMemory(m Map, offset uint64, size uint64) ([]byte, error) {
-	f, err := p.openFile("mem")
-	if err != nil {
-		return nil, err
+	if offset > m.Size {
+		return nil, fmt.Errorf("offset %d beyond end of map at %X of %d bytes", offset, m.Address, m.Size)
 	}
-	defer func() { _ = f.Close() }()
 
-	if _, err := f.Seek(int64(m.Address+offset), 0); err != nil {
+	mem, err := p.OpenMemory(MemoryBackendAuto)
+	if err != nil {
 		return nil, err
 	}
+	defer func() { _ = mem.Close() }()
 
 	if size == 0 {
-		size = m.Size
+		size = m.Size - offset
 	}
 
 	data := make([]byte, size)
-	if _, err := f.Read(data); err != nil {
+	faults, err := mem.ReadAt(data, m.Address+offset)
+	if err != nil {
 		return nil, err
 	}
-
+	if len(faults) > 0 && faults.Size() == size {
+		return nil, faults[0].Err
+	}
 	return data, nil
 }
+
+// WriteMemory writes data to the memory of the process at the given virtual address using the given backend.
+// Unlike reads, a write which cannot be completed fails, although any bytes before the failure will have been
+// written. The auto backend falls back to /proc/[pid]/mem if process_vm_writev is refused.
+func (p *Process) WriteMemory(address uint64, data []byte, backend MemoryBackend) error {
+	switch backend {
+	case MemoryBackendAuto:
+		err := writeProcessVM(*p, address, data)
+		if err == nil || !isRefused(err) {
+			return err
+		}
+		if ferr := writeProcMem(*p, address, data); ferr != nil {
+			return fmt.Errorf("%s refused (%s), and fallback failed: %w", "process_vm_writev", err, ferr)
+		}
+		return nil
+	case MemoryBackendProcessVM:
+		return writeProcessVM(*p, address, data)
+	case MemoryBackendProcMem:
+		return writeProcMem(*p, address, data)
+	default:
+		return fmt.Errorf("unsupported memory backend: %d", backend)
+	}
+}
+
+// writeProcMem writes memory via /proc/[pid]/mem.
+func writeProcMem(p Process, address uint64, data []byte) error {
+	f, err := os.OpenFile(filepath.Join("/proc", strconv.Itoa(int(p.PID())), "mem"), os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	n, err := f.WriteAt(data, int64(address))
+	if err != nil {
+		return fmt.Errorf("wrote %d of %d bytes at %X: %w", n, len(data), address, err)
+	}
+	return nil
+}
+
+// isRefused returns true if the error indicates that a backend is not permitted or not supported,
+// rather than that a particular address is unreadable.
+func isRefused(err error) bool {
+	return errors.Is(err, syscall.EPERM) ||
+		errors.Is(err, syscall.EACCES) ||
+		errors.Is(err, syscall.ENOSYS)
+}
+
+// autoMemoryReader uses process_vm_readv, switching permanently to /proc/[pid]/mem if it is refused.
+type autoMemoryReader struct {
+	process  Process
+	current  MemoryReader
+	fellBack bool
+}
+
+func (r *autoMemoryReader) ReadAt(buf []byte, address uint64) (Faults, error) {
+	faults, err := r.current.ReadAt(buf, address)
+	if err == nil || r.fellBack || !isRefused(err) {
+		return faults, err
+	}
+	fallback, ferr := newProcMemReader(r.process)
+	if ferr != nil {
+		return nil, fmt.Errorf("%s refused (%s), and fallback failed: %w", MemoryBackendProcessVM, err, ferr)
+	}
+	_ = r.current.Close()
+	r.current = fallback
+	r.fellBack = true
+	return r.current.ReadAt(buf, address)
+}
+
+func (r *autoMemoryReader) Close() error {
+	return r.current.Close()
+}
+
+// procMemReader reads memory via /proc/[pid]/mem.
+type procMemReader struct {
+	file *os.File
+}
+
+func newProcMemReader(p Process) (*procMemReader, error) {
+	f, err := p.openFile("mem")
+	if err != nil {
+		return nil, err
+	}
+	return &procMemReader{file: f}, nil
+}
+
+func (r *procMemReader) ReadAt(buf []byte, address uint64) (Faults, error) {
+	n, err := r.file.ReadAt(buf, int64(address))
+	if err == nil || n == len(buf) {
+		return nil, nil
+	}
+	if isRefused(err) {
+		return nil, err
+	}
+
+	// retry the remainder page by page, so a single bad page doesn't discard the rest of the range
+	var faults Faults
+	pageSize := uint64(os.Getpagesize())
+	pos := uint64(n)
+	for pos < uint64(len(buf)) {
+		end := pageEnd(address+pos, pageSize) - address
+		if end > uint64(len(buf)) {
+			end = uint64(len(buf))
+		}
+		read, err := r.file.ReadAt(buf[pos:end], int64(address+pos))
+		if err != nil && isRefused(err) {
+			return nil, err
+		}
+		if uint64(read) < end-pos {
+			zero(buf[pos+uint64(read) : end])
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			faults = faults.add(address+pos+uint64(read), end-pos-uint64(read), err)
+		}
+		pos = end
+	}
+	return faults, nil
+}
+
+func (r *procMemReader) Close() error {
+	return r.file.Close()
+}
+
+// pageEnd returns the address of the first byte after the page containing the given address.
+func pageEnd(address uint64, pageSize uint64) uint64 {
+	return (address/pageSize + 1) * pageSize
+}
+
+func zero(buf []byte) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
+
+// Chunk is a window of memory read from a Map by a RegionReader.
+type Chunk struct {
+	Offset  uint64 // Offset of Data from the start of the Map
+	Data    []byte // Data is only valid until the next call to RegionReader.Next
+	Last    bool   // Last is true if this is the final Chunk of the Map
+	Faults  Faults // Faults lists any unreadable ranges within the Chunk, which are zero-filled in Data
+	overlap uint64
+}
+
+// Address returns the virtual address of the given index within the Chunk.
+func (c Chunk) Address(m Map, index int) uint64 {
+	return m.Address + c.Offset + uint64(index)
+}
+
+// Owns returns true if a match starting at the given index should be reported for this Chunk.
+// Matches starting in the trailing overlap are skipped, as they will be reported by the following Chunk.
+func (c Chunk) Owns(index int) bool {
+	if c.Last {
+		return true
+	}
+	return index < len(c.Data)-c.tail()
+}
+
+// tail returns the number of trailing bytes which will be repeated at the start of the next Chunk.
+func (c Chunk) tail() int {
+	if uint64(len(c.Data)) < c.overlap {
+		return len(c.Data)
+	}
+	return int(c.overlap)
+}
+
+// RegionReader reads a memory Map in fixed-size windows, so that large maps can be processed without
+// holding the entire map in memory. Consecutive windows share `overlap` bytes, so that any match no longer
+// than the overlap is fully contained within at least one window.
+type RegionReader struct {
+	mem     MemoryReader
+	region  Map
+	buffer  []byte
+	overlap uint64
+	next    uint64
+	chunk   Chunk
+	err     error
+}
+
+// NewRegionReader creates a RegionReader for the given Map, reading through the given MemoryReader.
+func NewRegionReader(mem MemoryReader, m Map, window uint64, overlap uint64) (*RegionReader, error) {
+	if window == 0 || overlap >= window {
+		return nil, fmt.Errorf("window size (%d) must be greater than overlap (%d)", window, overlap)
+	}
+	if window > m.Size {
+		window = m.Size
+	}
+	return &RegionReader{
+		mem:     mem,
+		region:  m,
+		buffer:  make([]byte, window),
+		overlap: overlap,
+	}, nil
+}
+
+// Next reads the next Chunk of the Map, returning false when the Map is exhausted or an error occurs.
+func (r *RegionReader) Next() bool {
+	if r.err != nil || r.next >= r.region.Size || len(r.buffer) == 0 {
+		return false
+	}
+
+	// re-use the tail of the previous window rather than reading it again
+	kept := r.chunk.tail()
+	copy(r.buffer, r.chunk.Data[len(r.chunk.Data)-kept:])
+	start := r.next - uint64(kept)
+
+	want := uint64(len(r.buffer) - kept)
+	if remaining := r.region.Size - r.next; remaining < want {
+		want = remaining
+	}
+
+	faults, err := r.mem.ReadAt(r.buffer[kept:kept+int(want)], r.region.Address+r.next)
+	if err != nil {
+		r.err = err
+		return false
+	}
+
+	r.next += want
+	r.chunk = Chunk{
+		Offset:  start,
+		Data:    r.buffer[:kept+int(want)],
+		Last:    r.next >= r.region.Size,
+		Faults:  faults,
+		overlap: r.overlap,
+	}
+	return true
+}
+
+// Chunk returns the current Chunk.
+func (r *RegionReader) Chunk() Chunk {
+	return r.chunk
+}
+
+// Err returns the first error encountered while reading, if any.
+func (r *RegionReader) Err() error {
+	return r.err
+}
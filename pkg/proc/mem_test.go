@@ -0,0 +1,164 @@
+package proc
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegionReader(t *testing.T) {
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	address := uint64(uintptr(unsafe.Pointer(&data[0])))
+	region := Map{
+		Address:     address,
+		Size:        uint64(len(data)),
+		Permissions: MemPerms{Readable: true},
+	}
+
+	tests := []struct {
+		name    string
+		window  uint64
+		overlap uint64
+	}{
+		{
+			name:    "single window",
+			window:  uint64(len(data)),
+			overlap: 16,
+		},
+		{
+			name:    "aligned windows",
+			window:  1024,
+			overlap: 64,
+		},
+		{
+			name:    "unaligned windows",
+			window:  1000,
+			overlap: 7,
+		},
+	}
+
+	self := Self()
+	mem, err := self.OpenMemory(MemoryBackendAuto)
+	require.NoError(t, err)
+	defer func() { _ = mem.Close() }()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := NewRegionReader(mem, region, test.window, test.overlap)
+			require.NoError(t, err)
+
+			var owned []byte
+			var last Chunk
+			for reader.Next() {
+				chunk := reader.Chunk()
+				assert.LessOrEqual(t, uint64(len(chunk.Data)), test.window)
+				assert.Equal(t, data[chunk.Offset:chunk.Offset+uint64(len(chunk.Data))], chunk.Data)
+				for i := range chunk.Data {
+					if chunk.Owns(i) {
+						owned = append(owned, chunk.Data[i])
+					}
+				}
+				last = chunk
+			}
+			require.NoError(t, reader.Err())
+			assert.True(t, last.Last)
+			assert.Equal(t, data, owned)
+		})
+	}
+}
+
+func Test_RegionReaderInvalidWindow(t *testing.T) {
+	_, err := NewRegionReader(nil, Map{Size: 4096}, 16, 16)
+	assert.Error(t, err)
+}
+
+func Test_MemoryReaderFaults(t *testing.T) {
+
+	pageSize := os.Getpagesize()
+	data, err := syscall.Mmap(-1, 0, pageSize*3, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	require.NoError(t, err)
+	defer func() { _ = syscall.Munmap(data) }()
+
+	for i := range data {
+		data[i] = 0xff
+	}
+	address := uint64(uintptr(unsafe.Pointer(&data[0])))
+
+	// punch a hole in the middle of the mapping
+	_, _, errno := syscall.Syscall(syscall.SYS_MUNMAP, uintptr(address)+uintptr(pageSize), uintptr(pageSize), 0)
+	require.Zero(t, errno)
+
+	for _, backend := range []MemoryBackend{MemoryBackendProcessVM, MemoryBackendProcMem} {
+		t.Run(backend.String(), func(t *testing.T) {
+			self := Self()
+			mem, err := self.OpenMemory(backend)
+			require.NoError(t, err)
+			defer func() { _ = mem.Close() }()
+
+			buf := make([]byte, len(data))
+			faults, err := mem.ReadAt(buf, address)
+			require.NoError(t, err)
+			require.Len(t, faults, 1)
+			assert.Equal(t, address+uint64(pageSize), faults[0].Address)
+			assert.Equal(t, uint64(pageSize), faults[0].Size)
+			assert.Equal(t, bytes.Repeat([]byte{0xff}, pageSize), buf[:pageSize])
+			assert.Equal(t, make([]byte, pageSize), buf[pageSize:pageSize*2])
+			assert.Equal(t, bytes.Repeat([]byte{0xff}, pageSize), buf[pageSize*2:])
+		})
+	}
+}
+
+func Test_ReadMemory(t *testing.T) {
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1)
+	region := Map{
+		Address:     uint64(uintptr(unsafe.Pointer(&data[0]))),
+		Size:        uint64(len(data)),
+		Permissions: MemPerms{Readable: true},
+	}
+	self := Self()
+
+	t.Run("remainder", func(t *testing.T) {
+		read, err := self.ReadMemory(region, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("abcdef"), read)
+	})
+
+	t.Run("size", func(t *testing.T) {
+		read, err := self.ReadMemory(region, 4, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("456"), read)
+	})
+
+	t.Run("offset beyond end of map", func(t *testing.T) {
+		_, err := self.ReadMemory(region, region.Size+1, 0)
+		assert.ErrorContains(t, err, "beyond end of map")
+	})
+}
+
+func Test_WriteMemory(t *testing.T) {
+
+	pageSize := os.Getpagesize()
+	data, err := syscall.Mmap(-1, 0, pageSize*2, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	require.NoError(t, err)
+	defer func() { _ = syscall.Munmap(data) }()
+	address := uint64(uintptr(unsafe.Pointer(&data[0])))
+
+	for _, backend := range []MemoryBackend{MemoryBackendAuto, MemoryBackendProcessVM, MemoryBackendProcMem} {
+		t.Run(backend.String(), func(t *testing.T) {
+			zero(data)
+			self := Self()
+
+			// the write crosses a page boundary
+			require.NoError(t, self.WriteMemory(address+uint64(pageSize)-2, []byte("poke"), backend))
+			assert.Equal(t, []byte("poke"), data[pageSize-2:pageSize+2])
+			assert.Equal(t, make([]byte, pageSize-2), data[:pageSize-2])
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxIovecs is the maximum number of iovecs accepted by a single process_vm_readv call (IOV_MAX).
+const maxIovecs = 1024
+
+// processVMReader reads memory using process_vm_readv. Each page is passed as a separate remote iovec,
+// so that many pages are read in a single syscall, yet a partial read identifies exactly which page failed.
+type processVMReader struct {
+	pid      int
+	pageSize uint64
+	remote   []unix.RemoteIovec
+}
+
+func newProcessVMReader(p Process) *processVMReader {
+	return &processVMReader{
+		pid:      int(p.PID()),
+		pageSize: uint64(os.Getpagesize()),
+		remote:   make([]unix.RemoteIovec, 0, maxIovecs),
+	}
+}
+
+func (r *processVMReader) ReadAt(buf []byte, address uint64) (Faults, error) {
+	var faults Faults
+	pos := uint64(0)
+	for pos < uint64(len(buf)) {
+
+		r.remote = r.remote[:0]
+		end := pos
+		for end < uint64(len(buf)) && len(r.remote) < maxIovecs {
+			next := pageEnd(address+end, r.pageSize) - address
+			if next > uint64(len(buf)) {
+				next = uint64(len(buf))
+			}
+			r.remote = append(r.remote, unix.RemoteIovec{
+				Base: uintptr(address + end),
+				Len:  int(next - end),
+			})
+			end = next
+		}
+
+		n, err := r.readv(buf[pos:end])
+		if err != nil && isRefused(err) {
+			return nil, err
+		}
+		if errors.Is(err, syscall.ESRCH) {
+			return nil, err
+		}
+		pos += n
+		if pos < end {
+			// the page containing pos could not be read - skip it and carry on from the next page
+			faultEnd := pageEnd(address+pos, r.pageSize) - address
+			if faultEnd > end {
+				faultEnd = end
+			}
+			if err == nil {
+				err = syscall.EFAULT
+			}
+			zero(buf[pos:faultEnd])
+			faults = faults.add(address+pos, faultEnd-pos, err)
+			pos = faultEnd
+		}
+	}
+	return faults, nil
+}
+
+func (r *processVMReader) readv(local []byte) (uint64, error) {
+	if len(local) == 0 || len(r.remote) == 0 {
+		return 0, nil
+	}
+	localIov := []unix.Iovec{{Base: &local[0]}}
+	localIov[0].SetLen(len(local))
+	n, err := unix.ProcessVMReadv(r.pid, localIov, r.remote, 0)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(n), nil
+}
+
+func (r *processVMReader) Close() error {
+	return nil
+}
+
+// writeProcessVM writes memory using process_vm_writev.
+func writeProcessVM(p Process, address uint64, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	local := []unix.Iovec{{Base: &data[0]}}
+	local[0].SetLen(len(data))
+	remote := []unix.RemoteIovec{{Base: uintptr(address), Len: len(data)}}
+	n, err := unix.ProcessVMWritev(int(p.PID()), local, remote, 0)
+	if err != nil {
+		return err
+	}
+	if n < len(data) {
+		return fmt.Errorf("wrote %d of %d bytes at %X: %w", n, len(data), address, syscall.EFAULT)
+	}
+	return nil
+}
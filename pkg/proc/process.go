@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -100,3 +101,43 @@ func (p *Process) openFile(path ...string) (*os.File, error) {
 	final := filepath.Join(append([]string{"/proc", strconv.Itoa(int(p.PID()))}, path...)...)
 	return os.Open(final)
 }
+
+// Executable returns the path of the executable run by the process.
+func (p *Process) Executable() (string, error) {
+	return os.Readlink(filepath.Join("/proc", strconv.Itoa(int(p.PID())), "exe"))
+}
+
+// Environ returns the initial environment of the process, as NUL-separated KEY=VALUE pairs.
+func (p *Process) Environ() ([]byte, error) {
+	return p.readFile("environ")
+}
+
+// Cmdline returns the command line of the process, as NUL-separated arguments.
+func (p *Process) Cmdline() ([]byte, error) {
+	return p.readFile("cmdline")
+}
+
+// Auxv returns the raw auxiliary vector passed to the process by the kernel.
+func (p *Process) Auxv() ([]byte, error) {
+	return p.readFile("auxv")
+}
+
+// Registers returns the stack pointer and program counter of the process, as reported by /proc/[pid]/syscall.
+// They are only available while the process is blocked, e.g. in a system call.
+func (p *Process) Registers() (sp uint64, pc uint64, err error) {
+	data, err := p.readFile("syscall")
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return 0, 0, fmt.Errorf("registers are not available while process %d is running", *p)
+	}
+	if sp, err = strconv.ParseUint(strings.TrimPrefix(fields[len(fields)-2], "0x"), 16, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid stack pointer: %w", err)
+	}
+	if pc, err = strconv.ParseUint(strings.TrimPrefix(fields[len(fields)-1], "0x"), 16, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid program counter: %w", err)
+	}
+	return sp, pc, nil
+}
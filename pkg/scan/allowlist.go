@@ -0,0 +1,144 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Example:
+//
+// allowlist:
+//   - pattern: Password literal text
+//     process: python3
+//     reason: test fixture
+//   - region: /usr/lib/**
+//   - match: '^password=example'
+
+type allowlistFile struct {
+	Allowlist []allowlistDefinition `yaml:"allowlist"`
+}
+
+type allowlistDefinition struct {
+	Pattern string `yaml:"pattern"`
+	Process string `yaml:"process"`
+	Region  string `yaml:"region"`
+	Match   string `yaml:"match"`
+	Reason  string `yaml:"reason"`
+}
+
+// AllowlistEntry suppresses results which satisfy every one of its non-empty fields. Pattern, Process and
+// Region are glob patterns, or exact strings, matched against the pattern name, process name and region path.
+// A "**" element in Region matches any number of directories.
+type AllowlistEntry struct {
+	Pattern string
+	Process string
+	Region  string
+	Match   *regexp.Regexp
+	Reason  string
+}
+
+// Allowlist suppresses results which match any of its entries.
+type Allowlist struct {
+	Entries []AllowlistEntry
+}
+
+// LoadAllowlist reads a YAML allowlist file. Every invalid entry in the file is reported in the returned error,
+// rather than stopping at the first.
+func LoadAllowlist(filename string) (*Allowlist, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseAllowlist(data, filename)
+}
+
+func parseAllowlist(data []byte, source string) (*Allowlist, error) {
+
+	var file allowlistFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	var allowlist Allowlist
+	var problems []string
+	for i, definition := range file.Allowlist {
+		if definition.Pattern == "" && definition.Process == "" && definition.Region == "" && definition.Match == "" {
+			problems = append(problems, fmt.Sprintf("entry #%d: at least one of pattern, process, region or match is required", i+1))
+			continue
+		}
+		var invalid bool
+		for _, glob := range []string{definition.Pattern, definition.Process, definition.Region} {
+			if _, err := path.Match(glob, ""); err != nil {
+				problems = append(problems, fmt.Sprintf("entry #%d: invalid glob '%s': %s", i+1, glob, err))
+				invalid = true
+			}
+		}
+		entry := AllowlistEntry{
+			Pattern: definition.Pattern,
+			Process: definition.Process,
+			Region:  definition.Region,
+			Reason:  definition.Reason,
+		}
+		if definition.Match != "" {
+			regex, err := regexp.Compile(definition.Match)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("entry #%d: invalid match regex: %s", i+1, err))
+				continue
+			}
+			entry.Match = regex
+		}
+		if !invalid {
+			allowlist.Entries = append(allowlist.Entries, entry)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s: %s", source, strings.Join(problems, "; "))
+	}
+	return &allowlist, nil
+}
+
+// Allows returns the first entry which suppresses the result, if any.
+func (a *Allowlist) Allows(r Result) (*AllowlistEntry, bool) {
+	for i, entry := range a.Entries {
+		if entry.matches(r) {
+			return &a.Entries[i], true
+		}
+	}
+	return nil, false
+}
+
+// Filter returns a ResultFilter which excludes allowlisted results.
+func (a *Allowlist) Filter() ResultFilter {
+	return func(r Result) bool {
+		_, allowed := a.Allows(r)
+		return !allowed
+	}
+}
+
+func (e AllowlistEntry) matches(r Result) bool {
+	if e.Pattern != "" {
+		if !globMatch(e.Pattern, patternName(r.Pattern)) {
+			return false
+		}
+	}
+	if e.Process != "" {
+		if !globMatch(e.Process, r.processName()) {
+			return false
+		}
+	}
+	if e.Region != "" {
+		if !globMatch(e.Region, r.Map.Path) {
+			return false
+		}
+	}
+	if e.Match != nil && !e.Match.Match(r.Text()) {
+		return false
+	}
+	return true
+}
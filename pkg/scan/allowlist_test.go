@@ -0,0 +1,76 @@
+package scan
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Allowlist(t *testing.T) {
+
+	allowlist, err := parseAllowlist([]byte(`
+allowlist:
+  - pattern: Password*
+    region: /usr/lib/*
+    reason: library strings
+  - match: '^tok_example'
+  - process: nosuchprocess
+`), "allow.yaml")
+	require.NoError(t, err)
+	require.Len(t, allowlist.Entries, 3)
+
+	password := secrets.Pattern{Name: "Password literal text", Regex: regexp.MustCompile(`password=\S+`)}
+	token := secrets.Pattern{Name: "Token", Regex: regexp.MustCompile(`tok_[a-z]+`)}
+
+	tests := []struct {
+		name    string
+		result  Result
+		allowed bool
+	}{
+		{
+			name:    "pattern and region",
+			result:  Result{Pattern: password, Process: proc.Self(), Map: proc.Map{Path: "/usr/lib/libc.so.6"}, Match: []byte("password=x")},
+			allowed: true,
+		},
+		{
+			name:   "pattern only",
+			result: Result{Pattern: password, Process: proc.Self(), Map: proc.Map{Path: "[heap]"}, Match: []byte("password=x")},
+		},
+		{
+			name:    "match",
+			result:  Result{Pattern: token, Process: proc.Self(), Match: []byte("tok_example")},
+			allowed: true,
+		},
+		{
+			name:   "no entry",
+			result: Result{Pattern: token, Process: proc.Self(), Match: []byte("tok_live")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, allowed := allowlist.Allows(test.result)
+			assert.Equal(t, test.allowed, allowed)
+			assert.Equal(t, !test.allowed, allowlist.Filter()(test.result))
+		})
+	}
+
+	entry, _ := allowlist.Allows(tests[0].result)
+	assert.Equal(t, "library strings", entry.Reason)
+}
+
+func Test_AllowlistReportsProblems(t *testing.T) {
+	_, err := parseAllowlist([]byte(`
+allowlist:
+  - reason: matches everything
+  - match: '(unclosed'
+  - region: '[heap'
+`), "allow.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "entry #1: at least one of")
+	assert.Contains(t, err.Error(), "entry #2: invalid match regex")
+	assert.Contains(t, err.Error(), "entry #3: invalid glob '[heap'")
+}
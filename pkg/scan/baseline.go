@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+const baselineVersion = 1
+
+// Baseline is a set of known findings, identified by their fingerprints. Findings in the baseline can be
+// excluded from later scans so that only new findings are reported.
+type Baseline struct {
+	mu      sync.RWMutex
+	entries map[string]BaselineEntry
+}
+
+// BaselineEntry describes a single known finding. Only the fingerprint is used for matching, the other fields
+// are recorded to make the file easier to review.
+type BaselineEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Pattern     string `json:"pattern"`
+	Executable  string `json:"executable"`
+	Region      string `json:"region,omitempty"`
+}
+
+type baselineFile struct {
+	Version  int             `json:"version"`
+	Findings []BaselineEntry `json:"findings"`
+}
+
+// NewBaseline returns an empty baseline.
+func NewBaseline() *Baseline {
+	return &Baseline{entries: make(map[string]BaselineEntry)}
+}
+
+// LoadBaseline reads a baseline previously written by Save.
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file baselineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if file.Version != baselineVersion {
+		return nil, fmt.Errorf("%s: unsupported baseline version %d", path, file.Version)
+	}
+	baseline := NewBaseline()
+	for _, entry := range file.Findings {
+		baseline.entries[entry.Fingerprint] = entry
+	}
+	return baseline, nil
+}
+
+// Save writes the baseline to a file, ordered by fingerprint so that it can be diffed between runs.
+func (b *Baseline) Save(path string) error {
+	b.mu.RLock()
+	file := baselineFile{
+		Version:  baselineVersion,
+		Findings: make([]BaselineEntry, 0, len(b.entries)),
+	}
+	for _, entry := range b.entries {
+		file.Findings = append(file.Findings, entry)
+	}
+	b.mu.RUnlock()
+
+	sort.Slice(file.Findings, func(i, j int) bool {
+		return file.Findings[i].Fingerprint < file.Findings[j].Fingerprint
+	})
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// Add records a result in the baseline. It returns false if the result was already known.
+func (b *Baseline) Add(r Result) bool {
+	fingerprint := r.Fingerprint()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.entries[fingerprint]; ok {
+		return false
+	}
+	pattern := patternName(r.Pattern)
+	b.entries[fingerprint] = BaselineEntry{
+		Fingerprint: fingerprint,
+		Pattern:     pattern,
+		Executable:  r.program(),
+		Region:      r.Map.Path,
+	}
+	return true
+}
+
+// Contains returns true if the result is already in the baseline.
+func (b *Baseline) Contains(r Result) bool {
+	fingerprint := r.Fingerprint()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, ok := b.entries[fingerprint]
+	return ok
+}
+
+// Len returns the number of findings in the baseline.
+func (b *Baseline) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.entries)
+}
+
+// Filter returns a ResultFilter which excludes results already in the baseline.
+func (b *Baseline) Filter() ResultFilter {
+	return func(r Result) bool {
+		return !b.Contains(r)
+	}
+}
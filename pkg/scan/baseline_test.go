@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Baseline(t *testing.T) {
+
+	pattern := secrets.Pattern{Name: "Token", Regex: regexp.MustCompile(`tok_[a-z]+`)}
+	known := Result{Pattern: pattern, Process: proc.Self(), Address: 0x1000, Match: []byte("tok_abc"), Map: proc.Map{Path: "[heap]"}}
+	moved := Result{Pattern: pattern, Process: proc.Self(), Address: 0x8000, Match: []byte("tok_abc"), Map: proc.Map{Path: "[stack]"}}
+	unknown := Result{Pattern: pattern, Process: proc.Self(), Address: 0x1000, Match: []byte("tok_def")}
+
+	baseline := NewBaseline()
+	assert.True(t, baseline.Add(known))
+	assert.False(t, baseline.Add(moved))
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	require.NoError(t, baseline.Save(path))
+
+	loaded, err := LoadBaseline(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded.Len())
+
+	filter := loaded.Filter()
+	assert.False(t, filter(known))
+	assert.False(t, filter(moved))
+	assert.True(t, filter(unknown))
+}
+
+func Test_ScannerBaseline(t *testing.T) {
+
+	pattern := secrets.Pattern{Regex: regexp.MustCompile(`password=[a-z]+`)}
+	baseline := NewBaseline()
+	baseline.Add(Result{Pattern: pattern, Process: proc.Self(), Match: []byte("password=abc")})
+
+	target := newFakeTarget(proc.Self()).
+		withRegion(0x10000, "[heap]", []byte("password=abc password=def"))
+
+	scanner := New(
+		WithPatterns(pattern),
+		WithProcessSelector(Targets(target)),
+		WithSelf(true),
+		WithResultFilter(baseline.Filter()),
+	)
+
+	results := collect(t, scanner)
+	require.Len(t, results, 1)
+	assert.Equal(t, "password=def", string(results[0].Match))
+}
@@ -0,0 +1,89 @@
+package scan
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Encoding is a text encoding in which patterns are searched for.
+type Encoding string
+
+const (
+	EncodingUTF8    Encoding = "utf8"
+	EncodingUTF16LE Encoding = "utf16le"
+	EncodingUTF16BE Encoding = "utf16be"
+)
+
+// substitute replaces non-ASCII code units in a decoded view of UTF-16 data.
+const substitute = 0x1a
+
+// ParseEncodings parses a comma-separated list of encodings, e.g. "utf8,utf16le".
+func ParseEncodings(s string) ([]Encoding, error) {
+	var encodings []Encoding
+	for _, name := range strings.Split(s, ",") {
+		switch encoding := Encoding(strings.ToLower(strings.TrimSpace(name))); encoding {
+		case EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE:
+			encodings = append(encodings, encoding)
+		default:
+			return nil, fmt.Errorf("invalid encoding '%s': must be one of utf8, utf16le or utf16be", name)
+		}
+	}
+	return encodings, nil
+}
+
+// UnitSize returns the number of bytes in each code unit of the encoding.
+func (e Encoding) UnitSize() int {
+	switch e {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func (e Encoding) order() binary.ByteOrder {
+	if e == EncodingUTF16BE {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// view returns a decoded view of UTF-16 data starting at the given parity (0 or 1), with one byte per code unit,
+// re-using buf where possible. ASCII code units are kept and all others are replaced by SUB, so offset i of the
+// view is offset parity+2i of the data. Strings are usually aligned to 2 bytes, but can be found at odd addresses
+// in packed structures and serialised data, so both parities are searched.
+func (e Encoding) view(data []byte, parity int, buf []byte) []byte {
+	n := (len(data) - parity) / 2
+	if n < 0 {
+		n = 0
+	}
+	if cap(buf) < n {
+		buf = make([]byte, n)
+	}
+	buf = buf[:n]
+	order := e.order()
+	for i := range buf {
+		unit := order.Uint16(data[parity+2*i:])
+		if unit < 0x80 {
+			buf[i] = byte(unit)
+		} else {
+			buf[i] = substitute
+		}
+	}
+	return buf
+}
+
+// Decode converts bytes in the encoding to UTF-8. A trailing odd byte of UTF-16 data is dropped.
+func (e Encoding) Decode(data []byte) []byte {
+	if e.UnitSize() == 1 {
+		return data
+	}
+	order := e.order()
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
@@ -0,0 +1,115 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseEncodings(t *testing.T) {
+	encodings, err := ParseEncodings("utf8, UTF16LE,utf16be")
+	require.NoError(t, err)
+	assert.Equal(t, []Encoding{EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE}, encodings)
+
+	_, err = ParseEncodings("utf32")
+	assert.Error(t, err)
+}
+
+func Test_EncodingView(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding Encoding
+		data     []byte
+		parity   int
+		want     []byte
+	}{
+		{
+			name:     "little endian",
+			encoding: EncodingUTF16LE,
+			data:     []byte{'a', 0, 'b', 0, 0, 0},
+			want:     []byte{'a', 'b', 0},
+		},
+		{
+			name:     "big endian",
+			encoding: EncodingUTF16BE,
+			data:     []byte{0, 'a', 0, 'b'},
+			want:     []byte{'a', 'b'},
+		},
+		{
+			name:     "non-ascii code units are substituted",
+			encoding: EncodingUTF16LE,
+			data:     []byte{0xe9, 0x00, 'a', 0x01, 'b', 0},
+			want:     []byte{substitute, substitute, 'b'},
+		},
+		{
+			name:     "trailing odd byte is dropped",
+			encoding: EncodingUTF16LE,
+			data:     []byte{'a', 0, 'b'},
+			want:     []byte{'a'},
+		},
+		{
+			name:     "odd offsets",
+			encoding: EncodingUTF16LE,
+			data:     []byte{0xff, 'a', 0, 'b', 0},
+			parity:   1,
+			want:     []byte{'a', 'b'},
+		},
+		{
+			name:     "odd offsets of a single byte",
+			encoding: EncodingUTF16LE,
+			data:     []byte{0xff},
+			parity:   1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.encoding.view(test.data, test.parity, nil))
+		})
+	}
+}
+
+func Test_EncodingDecode(t *testing.T) {
+	assert.Equal(t, "héllo", string(EncodingUTF16LE.Decode([]byte{'h', 0, 0xe9, 0, 'l', 0, 'l', 0, 'o', 0})))
+	assert.Equal(t, "hi", string(EncodingUTF16BE.Decode([]byte{0, 'h', 0, 'i'})))
+	assert.Equal(t, "hi", string(EncodingUTF8.Decode([]byte("hi"))))
+}
+
+func Test_ShrinkMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding Encoding
+		match    []byte
+		want     []byte
+	}{
+		{
+			name:     "utf8 stops at nul",
+			encoding: EncodingUTF8,
+			match:    []byte("abc\x00def"),
+			want:     []byte("abc"),
+		},
+		{
+			name:     "utf16 keeps nul bytes within characters",
+			encoding: EncodingUTF16LE,
+			match:    []byte{'a', 0, 'b', 0},
+			want:     []byte{'a', 0, 'b', 0},
+		},
+		{
+			name:     "utf16 stops at nul code unit",
+			encoding: EncodingUTF16LE,
+			match:    []byte{'a', 0, 0, 0, 'b', 0},
+			want:     []byte{'a', 0},
+		},
+		{
+			name:     "utf16 ignores unaligned nul bytes",
+			encoding: EncodingUTF16BE,
+			match:    []byte{0, 'a', 0, 'b'},
+			want:     []byte{0, 'a', 0, 'b'},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, shrinkMatch(test.match, test.encoding))
+		})
+	}
+}
@@ -0,0 +1,597 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/secrets"
+)
+
+// minWindow is the smallest window of memory a worker will read at once.
+const minWindow = 64 * 1024
+
+// pendingPerWorker is the number of units each worker may run ahead of the oldest unit whose results have not been
+// delivered, which bounds the results held back while a slow unit completes.
+const pendingPerWorker = 4
+
+// unit is a single map, or another source of data, of a single target to be scanned.
+type unit struct {
+	sequence int
+	target   Target
+	source   Source
+	region   proc.Map
+	file     proc.FileDescriptor
+}
+
+// batch is the set of results for a completed unit.
+type batch struct {
+	sequence int
+	results  []Result
+	file     *fileID // file identifies the open file scanned by the unit, if any
+}
+
+// limiter enforces the limits on the number of results delivered, in total and for each process.
+type limiter struct {
+	mu            sync.Mutex
+	maxResults    int
+	maxPerProcess int
+	total         int
+	perProcess    map[proc.Process]int
+}
+
+// allow returns true if a result for the process may be delivered, and counts it.
+func (l *limiter) allow(p proc.Process) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxPerProcess > 0 && l.perProcess[p] >= l.maxPerProcess {
+		return false
+	}
+	l.perProcess[p]++
+	l.total++
+	return true
+}
+
+// full returns true if no more results will be delivered for the process.
+func (l *limiter) full(p proc.Process) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.maxPerProcess > 0 && l.perProcess[p] >= l.maxPerProcess
+}
+
+// budget limits the number of bytes buffered by all workers at once, whether windows of memory or open files.
+type budget struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	available uint64
+}
+
+func newBudget(ctx context.Context, size uint64) *budget {
+	b := &budget{available: size}
+	b.cond = sync.NewCond(&b.mu)
+	// wake any waiting workers when the scan is cancelled
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.cond.Broadcast()
+	}()
+	return b
+}
+
+// acquire waits until size bytes are available and takes them. It returns false, taking nothing, if the context
+// is cancelled first.
+func (b *budget) acquire(ctx context.Context, size uint64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.available < size {
+		if ctx.Err() != nil {
+			return false
+		}
+		b.cond.Wait()
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	b.available -= size
+	return true
+}
+
+// release returns bytes taken by acquire.
+func (b *budget) release(size uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.available += size
+	b.cond.Broadcast()
+}
+
+// fileReads records the earliest unit through which each open file was read, so that a file shared between
+// processes is reported once, through the same process, however the workers are scheduled.
+type fileReads struct {
+	mu    sync.Mutex
+	first map[fileID]int
+}
+
+// read records that the file was read by the unit with the given sequence number.
+func (f *fileReads) read(id fileID, sequence int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if first, ok := f.first[id]; !ok || sequence < first {
+		f.first[id] = sequence
+	}
+}
+
+// readBefore returns true if the file has been read by a unit earlier than the given sequence number.
+func (f *fileReads) readBefore(id fileID, sequence int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	first, ok := f.first[id]
+	return ok && first < sequence
+}
+
+// done returns true if no more results will be delivered at all.
+func (l *limiter) done() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.maxResults > 0 && l.total >= l.maxResults
+}
+
+func (s *Scanner) run(parent context.Context, targets []Target, fn func(Result)) error {
+
+	workers := s.workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	// share the memory budget between the workers, reducing the number of workers if necessary
+	window := s.maxBuffer / uint64(workers)
+	for workers > 1 && (window < minWindow || window <= s.overlap*2) {
+		workers--
+		window = s.maxBuffer / uint64(workers)
+	}
+	if window <= s.overlap {
+		return fmt.Errorf("max buffer (%d bytes) is too small for %d worker(s) with an overlap of %d bytes", s.maxBuffer, workers, s.overlap)
+	}
+
+	s.matcher = secrets.NewMatcher(s.patterns)
+	s.keyPattern = secrets.SensitiveKeyPattern()
+	s.argumentPattern = secrets.SensitiveArgumentPattern()
+
+	// the scan stops early once the maximum number of results has been delivered
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	s.budget = newBudget(ctx, s.maxBuffer)
+	s.fileReads = &fileReads{first: make(map[fileID]int)}
+	limits := &limiter{
+		maxResults:    s.maxResults,
+		maxPerProcess: s.maxResultsPerProcess,
+		perProcess:    make(map[proc.Process]int),
+	}
+
+	units := make(chan unit)
+	batches := make(chan batch)
+
+	// a unit holds a slot from when it is queued until its results are delivered
+	slots := make(chan struct{}, workers*pendingPerWorker)
+
+	go func() {
+		defer close(units)
+		var sequence int
+		send := func(u unit) bool {
+			u.sequence = sequence
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return false
+			}
+			select {
+			case units <- u:
+				sequence++
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, target := range sortTargets(targets) {
+			for _, source := range s.sources {
+				if source == SourceFDs {
+					if !s.files(target, send) {
+						return
+					}
+					continue
+				}
+				if source != SourceMemory {
+					if !send(unit{target: target, source: source}) {
+						return
+					}
+					continue
+				}
+				maps, err := target.Maps()
+				if err != nil {
+					s.log("failed to read maps for process %d: %s", target.Process(), err)
+					continue
+				}
+				for _, region := range maps {
+					if !s.include(target, region) {
+						continue
+					}
+					if !send(unit{target: target, source: source, region: region}) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range units {
+				if limits.full(u.target.Process()) {
+					batches <- batch{sequence: u.sequence}
+					continue
+				}
+				b := batch{sequence: u.sequence, results: s.scanUnit(ctx, u, window)}
+				if u.source == SourceFDs {
+					b.file = &fileID{device: u.file.Device, inode: u.file.Inode}
+				}
+				batches <- b
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(batches)
+	}()
+
+	// re-order the completed batches, so results are delivered deterministically
+	pending := make(map[int]batch)
+	var next int
+	for b := range batches {
+		pending[b.sequence] = b
+		for {
+			b, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-slots
+			if ctx.Err() != nil {
+				continue
+			}
+			// every earlier unit has completed, so the earliest read of a shared file is known
+			results := b.results
+			if b.file != nil && s.fileReads.readBefore(*b.file, b.sequence) {
+				results = nil
+			}
+			for _, result := range results {
+				if !limits.allow(result.Process) {
+					continue
+				}
+				fn(result)
+				if limits.full(result.Process) {
+					s.log("reached the maximum of %d result(s) for process %d", s.maxResultsPerProcess, result.Process)
+				}
+				if limits.done() {
+					s.log("reached the maximum of %d result(s), stopping scan", s.maxResults)
+					cancel()
+					break
+				}
+			}
+		}
+	}
+
+	return parent.Err()
+}
+
+// report returns true if the result passes every result filter.
+func (s *Scanner) report(r Result) bool {
+	for _, filter := range s.resultFilters {
+		if !filter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Scanner) include(t Target, m proc.Map) bool {
+	if !m.Permissions.Readable {
+		s.log("skipping memory at %X for process %d: memory is not readable", m.Address, t.Process())
+		return false
+	}
+	for _, filter := range s.regionFilters {
+		if ok, reason := filter(m); !ok {
+			s.log("skipping memory at %X for process %d: %s", m.Address, t.Process(), reason)
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Scanner) scanUnit(ctx context.Context, u unit, window uint64) []Result {
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	switch u.source {
+	case SourceMemory:
+	case SourceFDs:
+		return s.scanFile(ctx, u)
+	default:
+		data, err := s.readSource(u)
+		if err != nil {
+			s.log("failed to read %s for process %d: %s", u.source, u.target.Process(), err)
+			return nil
+		}
+		return s.scanSource(u, data)
+	}
+
+	// the window is paid for from the budget shared with open files
+	if !s.budget.acquire(ctx, window) {
+		return nil
+	}
+	defer s.budget.release(window)
+
+	process := u.target.Process()
+	name := u.target.Name()
+	executable, _ := u.target.Executable()
+
+	mem, err := u.target.OpenMemory()
+	if err != nil {
+		s.log("failed to open memory for process %d: %s", process, err)
+		return nil
+	}
+	defer func() { _ = mem.Close() }()
+
+	reader, err := proc.NewRegionReader(mem, u.region, window, s.overlap)
+	if err != nil {
+		s.log("failed to read memory at %X for process %d: %s", u.region.Address, process, err)
+		return nil
+	}
+
+	var results []Result
+	var faults proc.Faults
+	var view []byte
+	for ctx.Err() == nil && reader.Next() {
+		chunk := reader.Chunk()
+		faults = append(faults, chunk.Faults...)
+		for _, h := range s.find(chunk.Data, &view) {
+			if !chunk.Owns(h.start) {
+				continue
+			}
+			// a match which reaches the end of a window, other than the last, may continue into the next one
+			end := h.start + len(h.bytes)
+			result := Result{
+				Pattern:     *h.match.Pattern,
+				Process:     process,
+				ProcessName: name,
+				Executable:  executable,
+				Map:         u.region,
+				Source:      SourceMemory,
+				Encoding:    h.encoding,
+				Address:     chunk.Address(u.region, h.start),
+				Match:       h.bytes,
+				Truncated:   !chunk.Last && end+h.encoding.UnitSize() > len(chunk.Data),
+				Entropy:     h.match.Entropy,
+				Validation:  h.validation,
+			}
+			if !s.report(result) {
+				continue
+			}
+			readContext(mem, &result, s.contextRadius)
+			results = append(results, result)
+		}
+	}
+	if err := reader.Err(); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			s.log("process %d exited during scan", process)
+		} else {
+			s.log("failed to read memory at %X for process %d: %s", u.region.Address, process, err)
+		}
+	}
+	for _, fault := range faults {
+		s.log("unreadable memory at %X (%d bytes) for process %d: %s", fault.Address, fault.Size, process, fault.Err)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Address < results[j].Address
+	})
+	return results
+}
+
+// scanFile reads and scans an open file, unless it has already been read through an earlier unit. The file is held
+// in memory while it is scanned, so it is paid for from the budget shared with windows of memory.
+func (s *Scanner) scanFile(ctx context.Context, u unit) []Result {
+
+	process := u.target.Process()
+	id := fileID{device: u.file.Device, inode: u.file.Inode}
+	if s.fileReads.readBefore(id, u.sequence) {
+		s.log("skipping fd %d (%s) for process %d: file has already been scanned", u.file.FD, u.file.Path, process)
+		return nil
+	}
+
+	if !s.budget.acquire(ctx, u.file.Size) {
+		return nil
+	}
+	defer s.budget.release(u.file.Size)
+
+	data, err := u.target.ReadFileDescriptor(u.file.FD, u.file.Size)
+	if err != nil {
+		s.log("failed to read fd %d (%s) for process %d: %s", u.file.FD, u.file.Path, process, err)
+		return nil
+	}
+	s.fileReads.read(id, u.sequence)
+	return s.scanSource(u, data)
+}
+
+// scanSource scans the data from a source other than memory, such as the environment of a process. Variables in
+// the environment, and flags on the command line, whose names suggest they hold a secret are reported even if no
+// pattern matches their values.
+func (s *Scanner) scanSource(u unit, data []byte) []Result {
+
+	process := u.target.Process()
+	name := u.target.Name()
+	executable, _ := u.target.Executable()
+
+	region := sourceRegion(u, len(data))
+	mem := blobReader(data)
+
+	var variables []envVariable
+	if u.source == SourceEnv {
+		variables = parseEnviron(data)
+	}
+
+	var arguments []argument
+	if u.source == SourceCmdline {
+		arguments = parseArguments(data)
+	}
+
+	var results []Result
+	matchedKeys := make(map[string]bool)
+	var matched [][2]int
+	add := func(result Result) {
+		if !s.report(result) {
+			return
+		}
+		readContext(mem, &result, s.contextRadius)
+		results = append(results, result)
+	}
+
+	var view []byte
+	for _, h := range s.find(data, &view) {
+		key := envKey(variables, h.start)
+		matchedKeys[key] = true
+		matched = append(matched, [2]int{h.start, h.start + len(h.bytes)})
+		add(Result{
+			Pattern:     *h.match.Pattern,
+			Process:     process,
+			ProcessName: name,
+			Executable:  executable,
+			Map:         region,
+			Source:      u.source,
+			Key:         key,
+			Encoding:    h.encoding,
+			Address:     uint64(h.start),
+			Match:       h.bytes,
+			Entropy:     h.match.Entropy,
+			Validation:  h.validation,
+		})
+	}
+	for _, variable := range variables {
+		if len(variable.value) == 0 || matchedKeys[variable.key] || !s.keyPattern.Regex.MatchString(variable.key) {
+			continue
+		}
+		add(Result{
+			Pattern:     s.keyPattern,
+			Process:     process,
+			ProcessName: name,
+			Executable:  executable,
+			Map:         region,
+			Source:      u.source,
+			Key:         variable.key,
+			Encoding:    EncodingUTF8,
+			Address:     uint64(variable.valueOffset),
+			Match:       append([]byte(nil), variable.value...),
+		})
+	}
+
+	// flags such as --password are reported unless a pattern already matched their values
+arguments:
+	for _, arg := range arguments {
+		if len(arg.value) == 0 || !s.argumentPattern.Regex.MatchString(arg.name) {
+			continue
+		}
+		for _, m := range matched {
+			if m[0] < arg.offset+len(arg.value) && arg.offset < m[1] {
+				continue arguments
+			}
+		}
+		add(Result{
+			Pattern:     s.argumentPattern,
+			Process:     process,
+			ProcessName: name,
+			Executable:  executable,
+			Map:         region,
+			Source:      u.source,
+			Key:         arg.flag,
+			Encoding:    EncodingUTF8,
+			Address:     uint64(arg.offset),
+			Match:       append([]byte(nil), arg.value...),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Address < results[j].Address
+	})
+	return results
+}
+
+// hit is a match of a pattern in some encoding, with its offset into the original data.
+type hit struct {
+	match      secrets.Match
+	encoding   Encoding
+	start      int
+	bytes      []byte
+	validation secrets.Validation
+}
+
+// find searches data for every pattern in each of the configured encodings. UTF-16 is searched through decoded
+// views of the even and odd offsets, held in view between calls, and the offsets and bytes of each hit refer to
+// the original data. Byte patterns only match raw data, so they are not searched for in decoded views.
+func (s *Scanner) find(data []byte, view *[]byte) []hit {
+	var hits []hit
+	for _, encoding := range s.encodings {
+		if encoding.UnitSize() == 1 {
+			for _, match := range s.matcher.FindAll(data) {
+				original := matchBytes(match, data)
+				hits = append(hits, hit{
+					match:      match,
+					encoding:   encoding,
+					start:      match.Start,
+					bytes:      original,
+					validation: match.Pattern.Validate(original, lookahead(data, match.End)),
+				})
+			}
+			continue
+		}
+		size := encoding.UnitSize()
+		for parity := 0; parity < size; parity++ {
+			*view = encoding.view(data, parity, *view)
+			decoded := *view
+			for _, match := range s.matcher.FindAll(decoded) {
+				if match.Pattern.Finder != nil {
+					continue
+				}
+				start := parity + match.Start*size
+				original := shrinkMatch(data[start:parity+match.End*size], encoding)
+				if len(original) == 0 {
+					continue
+				}
+				end := match.Start + len(original)/size
+				hits = append(hits, hit{
+					match:      match,
+					encoding:   encoding,
+					start:      start,
+					bytes:      original,
+					validation: match.Pattern.Validate(decoded[match.Start:end], lookahead(decoded, end)),
+				})
+			}
+		}
+	}
+	return hits
+}
+
+// lookahead returns the bytes following a match which are needed to validate it.
+func lookahead(data []byte, end int) []byte {
+	following := data[end:]
+	if len(following) > secrets.ValidationLookahead {
+		following = following[:secrets.ValidationLookahead]
+	}
+	return following
+}
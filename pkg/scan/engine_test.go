@@ -0,0 +1,318 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var needle = secrets.Pattern{Regex: regexp.MustCompile(`needle`)}
+
+// slowTarget is a fakeTarget whose map at the given address can't be read until released. It records the maps
+// which have been read.
+type slowTarget struct {
+	*fakeTarget
+	slow    uint64
+	release chan struct{}
+	mu      sync.Mutex
+	read    map[uint64]bool
+}
+
+func newSlowTarget(target *fakeTarget, slow uint64) *slowTarget {
+	return &slowTarget{fakeTarget: target, slow: slow, release: make(chan struct{}), read: make(map[uint64]bool)}
+}
+
+func (s *slowTarget) OpenMemory() (proc.MemoryReader, error) {
+	return s, nil
+}
+
+func (s *slowTarget) ReadAt(buf []byte, address uint64) (proc.Faults, error) {
+	if address == s.slow {
+		<-s.release
+	} else {
+		s.mu.Lock()
+		s.read[address] = true
+		s.mu.Unlock()
+	}
+	return s.fakeTarget.ReadAt(buf, address)
+}
+
+// reads returns the number of maps, other than the slow one, which have been read.
+func (s *slowTarget) reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.read)
+}
+
+// blockingFilesTarget is a fakeTarget whose open files can't be read until released. It records the number of
+// files being read at once.
+type blockingFilesTarget struct {
+	*fakeTarget
+	release chan struct{}
+	mu      sync.Mutex
+	reading int
+}
+
+func (b *blockingFilesTarget) ReadFileDescriptor(fd int, limit uint64) ([]byte, error) {
+	b.mu.Lock()
+	b.reading++
+	b.mu.Unlock()
+	<-b.release
+	b.mu.Lock()
+	b.reading--
+	b.mu.Unlock()
+	return b.fakeTarget.ReadFileDescriptor(fd, limit)
+}
+
+func (b *blockingFilesTarget) readers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reading
+}
+
+// brokenTarget is a fakeTarget whose maps or memory can't be read.
+type brokenTarget struct {
+	*fakeTarget
+	mapsErr error
+	openErr error
+}
+
+func (b *brokenTarget) Maps() (proc.Maps, error) {
+	if b.mapsErr != nil {
+		return nil, b.mapsErr
+	}
+	return b.fakeTarget.Maps()
+}
+
+func (b *brokenTarget) OpenMemory() (proc.MemoryReader, error) {
+	if b.openErr != nil {
+		return nil, b.openErr
+	}
+	return b.fakeTarget.OpenMemory()
+}
+
+// testLogger records the messages logged by a Scanner.
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Log(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func Test_EngineOrdersResultsBehindSlowUnit(t *testing.T) {
+
+	target := newSlowTarget(newFakeTarget(1000), 0x10000)
+	for i := 0; i < 50; i++ {
+		target.withRegion(0x10000+uint64(i)*0x1000, "", []byte("needle"))
+	}
+
+	results, errs := New(
+		WithPatterns(needle),
+		WithProcessSelector(Targets(target)),
+		WithSelf(true),
+		WithWorkers(4),
+	).Results(context.Background())
+
+	// the other workers carry on while the first map is held up, but nothing is delivered before it
+	require.Eventually(t, func() bool { return target.reads() > 0 }, 5*time.Second, time.Millisecond)
+	select {
+	case result := <-results:
+		t.Fatalf("result at %X delivered before the first map was scanned", result.Address)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(target.release)
+
+	var addresses []uint64
+	for result := range results {
+		addresses = append(addresses, result.Address)
+	}
+	require.NoError(t, <-errs)
+	require.Len(t, addresses, 50)
+	for i, address := range addresses {
+		assert.Equal(t, 0x10000+uint64(i)*0x1000, address)
+	}
+}
+
+func Test_EngineBoundsPendingResults(t *testing.T) {
+
+	target := newSlowTarget(newFakeTarget(1000), 0x10000)
+	for i := 0; i < 100; i++ {
+		target.withRegion(0x10000+uint64(i)*0x1000, "", []byte("needle"))
+	}
+
+	const workers = 2
+	results, errs := New(
+		WithPatterns(needle),
+		WithProcessSelector(Targets(target)),
+		WithSelf(true),
+		WithWorkers(workers),
+	).Results(context.Background())
+
+	// while the first map is held up, only a bounded number of maps after it are scanned
+	limit := workers*pendingPerWorker - 1
+	require.Eventually(t, func() bool { return target.reads() == limit }, 5*time.Second, time.Millisecond)
+	assert.Never(t, func() bool { return target.reads() > limit }, 100*time.Millisecond, time.Millisecond)
+	close(target.release)
+
+	var count int
+	for range results {
+		count++
+	}
+	require.NoError(t, <-errs)
+	assert.Equal(t, 100, count)
+	assert.Equal(t, 99, target.reads())
+}
+
+func Test_EngineBoundsOpenFiles(t *testing.T) {
+
+	// each file takes over a third of the max buffer, so only two can be held at once by the four workers
+	const size = 100 * 1024
+	target := &blockingFilesTarget{fakeTarget: newFakeTarget(1000), release: make(chan struct{})}
+	for fd := 3; fd < 11; fd++ {
+		data := make([]byte, size)
+		copy(data, "needle")
+		target.withFile(fd, fmt.Sprintf("/tmp/%d", fd), uint64(fd), data)
+	}
+
+	results, errs := New(
+		WithPatterns(needle),
+		WithProcessSelector(Targets(target)),
+		WithSelf(true),
+		WithSources(SourceFDs),
+		WithWorkers(4),
+		WithMaxBuffer(4*minWindow),
+	).Results(context.Background())
+
+	require.Eventually(t, func() bool { return target.readers() == 2 }, 5*time.Second, time.Millisecond)
+	assert.Never(t, func() bool { return target.readers() > 2 }, 100*time.Millisecond, time.Millisecond)
+	close(target.release)
+
+	var count int
+	for range results {
+		count++
+	}
+	require.NoError(t, <-errs)
+	assert.Equal(t, 8, count)
+}
+
+func Test_EngineCancellationDuringScan(t *testing.T) {
+
+	target := newFakeTarget(1000)
+	for i := 0; i < 100; i++ {
+		target.withRegion(0x10000+uint64(i)*0x1000, "", []byte("needle"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var delivered int
+	done := make(chan error)
+	go func() {
+		done <- New(
+			WithPatterns(needle),
+			WithProcessSelector(Targets(target)),
+			WithSelf(true),
+			WithWorkers(4),
+		).Scan(ctx, func(Result) {
+			delivered++
+			cancel()
+		})
+	}()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not stop after cancellation")
+	}
+	assert.Equal(t, 1, delivered)
+}
+
+func Test_EngineCancellationWhileWorkerBlocked(t *testing.T) {
+
+	target := newSlowTarget(newFakeTarget(1000), 0x10000)
+	for i := 0; i < 100; i++ {
+		target.withRegion(0x10000+uint64(i)*0x1000, "", []byte("needle"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- New(
+			WithPatterns(needle),
+			WithProcessSelector(Targets(target)),
+			WithSelf(true),
+			WithWorkers(2),
+		).Scan(ctx, func(Result) {
+			t.Error("unexpected result")
+		})
+	}()
+
+	require.Eventually(t, func() bool { return target.reads() > 0 }, 5*time.Second, time.Millisecond)
+	cancel()
+	close(target.release)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not stop after cancellation")
+	}
+}
+
+func Test_EngineErrors(t *testing.T) {
+
+	t.Run("selector", func(t *testing.T) {
+		failed := errors.New("no processes")
+		err := New(
+			WithPatterns(needle),
+			WithProcessSelector(func() ([]Target, error) { return nil, failed }),
+		).Scan(context.Background(), func(Result) {})
+		assert.ErrorIs(t, err, failed)
+	})
+
+	t.Run("buffer too small", func(t *testing.T) {
+		err := New(
+			WithPatterns(needle),
+			WithProcessSelector(Targets(newFakeTarget(1000).withRegion(0x10000, "", []byte("needle")))),
+			WithSelf(true),
+			WithMaxBuffer(1024),
+			WithOverlap(1024),
+		).Scan(context.Background(), func(Result) {})
+		assert.Error(t, err)
+	})
+
+	t.Run("unreadable targets", func(t *testing.T) {
+		logger := &testLogger{}
+		results := collect(t, New(
+			WithPatterns(needle),
+			WithProcessSelector(Targets(
+				&brokenTarget{fakeTarget: newFakeTarget(1000).withRegion(0x10000, "", []byte("needle")), mapsErr: errors.New("maps gone")},
+				&brokenTarget{fakeTarget: newFakeTarget(2000).withRegion(0x10000, "", []byte("needle")), openErr: errors.New("memory gone")},
+				newFakeTarget(3000).withRegion(0x10000, "", []byte("needle")),
+			)),
+			WithSelf(true),
+			WithWorkers(2),
+			WithLogger(logger),
+		))
+
+		// the targets which can't be read are logged and skipped, without stopping the scan
+		require.Len(t, results, 1)
+		assert.Equal(t, proc.Process(3000), results[0].Process)
+		assert.Contains(t, logger.messages, "failed to read maps for process 1000: maps gone")
+		assert.Contains(t, logger.messages, "failed to open memory for process 2000: memory gone")
+	})
+}
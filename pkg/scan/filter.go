@@ -0,0 +1,168 @@
+package scan
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/secrets"
+)
+
+// RegionFilter decides whether a memory map should be scanned. If not, it returns the reason the map was skipped.
+type RegionFilter func(m proc.Map) (bool, string)
+
+// SkipFileBacked skips maps which are backed by a file on disk.
+func SkipFileBacked() RegionFilter {
+	return func(m proc.Map) (bool, string) {
+		if m.Path != "" && m.Path[0] != '[' {
+			return false, "location is memory-mapped"
+		}
+		return true, ""
+	}
+}
+
+// ResultFilter decides whether a result should be reported.
+type ResultFilter func(r Result) bool
+
+// ValidOnly reports only results which passed offline validation.
+func ValidOnly() ResultFilter {
+	return func(r Result) bool {
+		return r.Validation.Validity == secrets.ValidityValid
+	}
+}
+
+// Kinds matches maps of any of the given kinds.
+func Kinds(kinds ...proc.MapKind) RegionFilter {
+	return func(m proc.Map) (bool, string) {
+		kind := m.Kind()
+		for _, k := range kinds {
+			if kind == k {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("region kind '%s' was not selected", kind)
+	}
+}
+
+// Permissions matches maps whose permissions match a mask in the format used by /proc/[pid]/maps, e.g. "rw-p".
+// A '?' in the mask matches any permission in that position.
+func Permissions(mask string) (RegionFilter, error) {
+	if len(mask) != 4 {
+		return nil, fmt.Errorf("invalid permissions '%s': must be 4 characters, e.g. rw-p", mask)
+	}
+	for i, c := range mask {
+		if c != '?' && c != '-' && c != rune("rwxs"[i]) && !(i == 3 && c == 'p') {
+			return nil, fmt.Errorf("invalid permissions '%s': unexpected '%c' at position %d", mask, c, i+1)
+		}
+	}
+	return func(m proc.Map) (bool, string) {
+		perms := m.Permissions.String()
+		for i := range mask {
+			if mask[i] != '?' && mask[i] != perms[i] {
+				return false, fmt.Sprintf("permissions %s do not match %s", perms, mask)
+			}
+		}
+		return true, ""
+	}, nil
+}
+
+// WritableOnly skips maps which are not writable.
+func WritableOnly() RegionFilter {
+	return func(m proc.Map) (bool, string) {
+		if !m.Permissions.Writable {
+			return false, "region is not writable"
+		}
+		return true, ""
+	}
+}
+
+// SkipExecutable skips maps which are executable.
+func SkipExecutable() RegionFilter {
+	return func(m proc.Map) (bool, string) {
+		if m.Permissions.Executable {
+			return false, "region is executable"
+		}
+		return true, ""
+	}
+}
+
+// PathGlob matches maps whose path matches the glob, or is exactly equal to it, e.g. "[heap]".
+func PathGlob(glob string) (RegionFilter, error) {
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob '%s': %w", glob, err)
+	}
+	return func(m proc.Map) (bool, string) {
+		if !globMatch(glob, m.Path) {
+			return false, fmt.Sprintf("path '%s' does not match '%s'", m.Path, glob)
+		}
+		return true, ""
+	}, nil
+}
+
+// AddressRange matches maps which overlap the range of addresses from start (inclusive) to end (exclusive).
+func AddressRange(start uint64, end uint64) RegionFilter {
+	return func(m proc.Map) (bool, string) {
+		if m.Address >= end || m.Address+m.Size <= start {
+			return false, fmt.Sprintf("region is outside of the address range %X-%X", start, end)
+		}
+		return true, ""
+	}
+}
+
+// MinSize skips maps smaller than size bytes.
+func MinSize(size uint64) RegionFilter {
+	return func(m proc.Map) (bool, string) {
+		if m.Size < size {
+			return false, fmt.Sprintf("region size %d is smaller than %d bytes", m.Size, size)
+		}
+		return true, ""
+	}
+}
+
+// MaxSize skips maps larger than size bytes.
+func MaxSize(size uint64) RegionFilter {
+	return func(m proc.Map) (bool, string) {
+		if m.Size > size {
+			return false, fmt.Sprintf("region size %d is larger than %d bytes", m.Size, size)
+		}
+		return true, ""
+	}
+}
+
+// globMatch matches a glob pattern, or the exact string, so that region names such as "[heap]" can be used
+// without escaping. As with path.Match, "*" does not match "/", but a "**" element matches any number of
+// directories, so "/usr/lib/**" matches every file beneath /usr/lib.
+func globMatch(glob string, s string) bool {
+	if glob == s {
+		return true
+	}
+	if !strings.Contains(glob, "**") {
+		ok, _ := path.Match(glob, s)
+		return ok
+	}
+	return matchElements(strings.Split(glob, "/"), strings.Split(s, "/"))
+}
+
+// matchElements matches the elements of a path against those of a glob, where a "**" element matches zero or
+// more elements of the path.
+func matchElements(glob []string, elements []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := range elements {
+				if matchElements(glob[1:], elements[i:]) {
+					return true
+				}
+			}
+			return matchElements(glob[1:], nil)
+		}
+		if len(elements) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], elements[0]); !ok {
+			return false
+		}
+		glob, elements = glob[1:], elements[1:]
+	}
+	return len(elements) == 0
+}
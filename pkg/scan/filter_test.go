@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegionFilters(t *testing.T) {
+
+	heap := proc.Map{Address: 0x1000, Size: 0x1000, Path: "[heap]", Permissions: proc.MemPerms{Readable: true, Writable: true}}
+	libc := proc.Map{Address: 0x8000, Size: 0x4000, Path: "/usr/lib/libc.so.6", Permissions: proc.MemPerms{Readable: true, Executable: true}}
+	anon := proc.Map{Address: 0x20000, Size: 0x100, Permissions: proc.MemPerms{Readable: true, Writable: true, Shared: true}}
+
+	mustFilter := func(filter RegionFilter, err error) RegionFilter {
+		require.NoError(t, err)
+		return filter
+	}
+
+	tests := []struct {
+		name   string
+		filter RegionFilter
+		want   []bool // heap, libc, anon
+	}{
+		{name: "kinds", filter: Kinds(proc.MapKindHeap, proc.MapKindAnon), want: []bool{true, false, true}},
+		{name: "exact permissions", filter: mustFilter(Permissions("rw-p")), want: []bool{true, false, false}},
+		{name: "wildcard permissions", filter: mustFilter(Permissions("rw-?")), want: []bool{true, false, true}},
+		{name: "writable only", filter: WritableOnly(), want: []bool{true, false, true}},
+		{name: "no exec", filter: SkipExecutable(), want: []bool{true, false, true}},
+		{name: "path glob", filter: mustFilter(PathGlob("/usr/lib/*")), want: []bool{false, true, false}},
+		{name: "exact path", filter: mustFilter(PathGlob("[heap]")), want: []bool{true, false, false}},
+		{name: "address range", filter: AddressRange(0x1800, 0x9000), want: []bool{true, true, false}},
+		{name: "min size", filter: MinSize(0x1000), want: []bool{true, true, false}},
+		{name: "max size", filter: MaxSize(0x1000), want: []bool{true, false, true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, m := range []proc.Map{heap, libc, anon} {
+				ok, reason := test.filter(m)
+				assert.Equal(t, test.want[i], ok, m.Path)
+				assert.Equal(t, ok, reason == "", reason)
+			}
+		})
+	}
+}
+
+func Test_PathGlobNestedPaths(t *testing.T) {
+
+	tests := []struct {
+		glob string
+		path string
+		want bool
+	}{
+		{glob: "/usr/lib/*", path: "/usr/lib/libc.so.6", want: true},
+		{glob: "/usr/lib/*", path: "/usr/lib/x86_64-linux-gnu/libc.so.6", want: false},
+		{glob: "/usr/lib/**", path: "/usr/lib/x86_64-linux-gnu/libc.so.6", want: true},
+		{glob: "/usr/lib/**", path: "/usr/lib/libc.so.6", want: true},
+		{glob: "/usr/lib/**", path: "/usr/libexec/foo", want: false},
+		{glob: "/usr/**/libc.so.*", path: "/usr/lib/x86_64-linux-gnu/libc.so.6", want: true},
+		{glob: "/usr/**/libc.so.*", path: "/usr/libc.so.6", want: true},
+		{glob: "/usr/**/libc.so.*", path: "/usr/lib/x86_64-linux-gnu/libm.so.6", want: false},
+		{glob: "**/libc.so.6", path: "/usr/lib/x86_64-linux-gnu/libc.so.6", want: true},
+		{glob: "/opt/**/bin/**", path: "/opt/app/v1/bin/tools/run", want: true},
+		{glob: "/opt/**/bin/**", path: "/opt/app/v1/lib/run", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.glob+" "+test.path, func(t *testing.T) {
+			filter, err := PathGlob(test.glob)
+			require.NoError(t, err)
+			ok, _ := filter(proc.Map{Path: test.path})
+			assert.Equal(t, test.want, ok)
+		})
+	}
+}
+
+func Test_RegionFilterErrors(t *testing.T) {
+	_, err := Permissions("rw")
+	assert.Error(t, err)
+	_, err = Permissions("wr-p")
+	assert.Error(t, err)
+	_, err = PathGlob("[heap")
+	assert.Error(t, err)
+}
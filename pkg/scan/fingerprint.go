@@ -0,0 +1,25 @@
+package scan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Fingerprint returns an identifier for the result which is stable across runs, and across restarts of the
+// process which owns the memory. It is derived from the pattern name, a hash of the decoded match, and the path of
+// the executable run by the process - the PID and address are deliberately excluded.
+func (r Result) Fingerprint() string {
+	pattern := patternName(r.Pattern)
+
+	executable := r.program()
+
+	match := sha256.Sum256(r.Text())
+
+	hash := sha256.New()
+	_, _ = hash.Write([]byte(pattern))
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write(match[:])
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write([]byte(executable))
+	return hex.EncodeToString(hash.Sum(nil))
+}
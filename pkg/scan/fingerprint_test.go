@@ -0,0 +1,25 @@
+package scan
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Fingerprint(t *testing.T) {
+
+	pattern := secrets.Pattern{Name: "Token", Regex: regexp.MustCompile(`tok_[a-z]+`)}
+
+	a := Result{Pattern: pattern, Process: proc.Self(), Address: 0x1000, Match: []byte("tok_abc")}
+	b := Result{Pattern: pattern, Process: proc.Self(), Address: 0x2000, Match: []byte("tok_abc"), Map: proc.Map{Path: "[heap]"}}
+	c := Result{Pattern: pattern, Process: proc.Self(), Address: 0x1000, Match: []byte("tok_def")}
+	d := Result{Pattern: secrets.Pattern{Name: "Other", Regex: pattern.Regex}, Process: proc.Self(), Match: []byte("tok_abc")}
+
+	assert.Len(t, a.Fingerprint(), 64)
+	assert.Equal(t, a.Fingerprint(), b.Fingerprint())
+	assert.NotEqual(t, a.Fingerprint(), c.Fingerprint())
+	assert.NotEqual(t, a.Fingerprint(), d.Fingerprint())
+}
@@ -0,0 +1,117 @@
+package scan
+
+import (
+	"bytes"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/secrets"
+)
+
+// Result is a single match found in the memory of a process.
+type Result struct {
+	Pattern        secrets.Pattern
+	Process        proc.Process
+	ProcessName    string // ProcessName is the name of the process, as reported by its Target
+	Executable     string // Executable is the path of the executable run by the process, if known
+	Map            proc.Map
+	Source         Source   // Source is where the match was found, e.g. in memory or in the environment
+	Key            string   // Key is the name of the environment variable or command-line flag containing the match, if any
+	Encoding       Encoding // Encoding is the text encoding in which the match was found
+	Address        uint64
+	Match          []byte  // Match holds the original bytes of the match, in its encoding
+	Truncated      bool    // Truncated is true if the match ran to the end of the window of memory being searched, so it may continue past the end of Match
+	Entropy        float64 // Entropy is the measured entropy of the secret, for patterns with an entropy threshold
+	Validation     secrets.Validation
+	Context        []byte // Context is the memory surrounding the match, suitable for a hex dump
+	ContextAddress uint64
+	ContextErr     error
+}
+
+// Text returns the match decoded to UTF-8.
+func (r Result) Text() []byte {
+	return r.Encoding.Decode(r.Match)
+}
+
+// processName returns the name of the process which owns the memory.
+func (r Result) processName() string {
+	if r.ProcessName != "" {
+		return r.ProcessName
+	}
+	return r.Process.Name()
+}
+
+// program identifies the program which owns the memory: the path of its executable, or its name if the path is
+// unknown.
+func (r Result) program() string {
+	if r.Executable != "" {
+		return r.Executable
+	}
+	if r.ProcessName == "" {
+		if executable, err := r.Process.Executable(); err == nil {
+			return executable
+		}
+	}
+	return r.processName()
+}
+
+// patternName returns the name of a pattern, or its regex if it is unnamed.
+func patternName(p secrets.Pattern) string {
+	if p.Name == "" {
+		return p.Expression()
+	}
+	return p.Name
+}
+
+// readContext reads `radius` lines of 16 bytes either side of a result, clamped to the bounds of the Map.
+func readContext(mem proc.MemoryReader, r *Result, radius int) {
+
+	linesEitherSide := uint64(0)
+	if radius > 0 {
+		linesEitherSide = uint64(radius)
+	}
+
+	// the map may not be aligned to 16 bytes, e.g. a raw blob loaded at an arbitrary base, so the aligned start
+	// can fall before the map
+	start := (r.Address / 16) * 16
+	if start >= r.Map.Address+16*linesEitherSide {
+		start -= 16 * linesEitherSide
+	} else {
+		start = r.Map.Address
+	}
+	end := ((r.Address+uint64(len(r.Match))+15)/16)*16 + 16*linesEitherSide
+	if mapEnd := r.Map.Address + r.Map.Size; end > mapEnd {
+		end = mapEnd
+	}
+
+	data := make([]byte, end-start)
+	faults, err := mem.ReadAt(data, start)
+	if err == nil && faults.Size() == uint64(len(data)) {
+		err = faults[0].Err
+	}
+	r.Context = data
+	r.ContextAddress = start
+	r.ContextErr = err
+}
+
+// matchBytes returns a copy of the bytes of a match. Regex matches are shrunk, as they often run on past the
+// end of a string, but matches of a Finder such as a hex pattern are exact and may contain NUL bytes.
+func matchBytes(match secrets.Match, data []byte) []byte {
+	if match.Pattern.Finder != nil {
+		return append([]byte(nil), data[match.Start:match.End]...)
+	}
+	return shrinkMatch(data[match.Start:match.End], EncodingUTF8)
+}
+
+// shrinkMatch trims the match at the first NUL terminator of the encoding - a NUL byte, or a NUL code unit for
+// UTF-16. The result is a copy, so it remains valid after the underlying memory buffer is re-used.
+func shrinkMatch(match []byte, encoding Encoding) []byte {
+	size := encoding.UnitSize()
+	end := len(match) - len(match)%size
+	for i := 0; i < end; i += size {
+		if bytes.Count(match[i:i+size], []byte{0x00}) == size {
+			end = i
+			break
+		}
+	}
+	return append([]byte(nil), match[:end]...)
+}
@@ -0,0 +1,258 @@
+package scan
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/secrets"
+)
+
+const (
+	// DefaultMaxBuffer is the default amount of memory buffered across all workers.
+	DefaultMaxBuffer = 64 * 1024 * 1024
+	// DefaultOverlap is the default number of bytes shared by consecutive windows of a map.
+	DefaultOverlap = 4 * 1024
+	// DefaultMaxFileSize is the default size of the largest open file which will be scanned.
+	DefaultMaxFileSize = 16 * 1024 * 1024
+	// DefaultContextRadius is the default number of lines of context read either side of a match.
+	DefaultContextRadius = 2
+)
+
+// Logger receives debug messages from the Scanner.
+type Logger interface {
+	Log(format string, args ...interface{})
+}
+
+// Scanner searches the memory of many processes concurrently for a set of patterns. Each readable memory map of
+// each process is a unit of work, and units are spread across a pool of workers. Results are always delivered
+// in order of PID and then address, regardless of the order in which the workers complete.
+type Scanner struct {
+	patterns             []secrets.Pattern
+	selector             ProcessSelector
+	processFilters       []ProcessFilter
+	regionFilters        []RegionFilter
+	resultFilters        []ResultFilter
+	sources              []Source
+	encodings            []Encoding
+	maxFileSize          uint64
+	includeSelf          bool
+	workers              int
+	maxBuffer            uint64
+	overlap              uint64
+	contextRadius        int
+	maxResults           int
+	maxResultsPerProcess int
+	logger               Logger
+	matcher              *secrets.Matcher
+	keyPattern           secrets.Pattern
+	argumentPattern      secrets.Pattern
+	budget               *budget
+	fileReads            *fileReads
+}
+
+// Option configures a Scanner.
+type Option func(s *Scanner)
+
+// New creates a Scanner. By default, all processes except the current process and its ancestors are scanned.
+func New(options ...Option) *Scanner {
+	s := &Scanner{
+		selector:      AllProcesses(),
+		sources:       []Source{SourceMemory},
+		encodings:     []Encoding{EncodingUTF8},
+		maxFileSize:   DefaultMaxFileSize,
+		workers:       runtime.NumCPU(),
+		maxBuffer:     DefaultMaxBuffer,
+		overlap:       DefaultOverlap,
+		contextRadius: DefaultContextRadius,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// WithPatterns adds patterns to search for.
+func WithPatterns(patterns ...secrets.Pattern) Option {
+	return func(s *Scanner) {
+		s.patterns = append(s.patterns, patterns...)
+	}
+}
+
+// WithProcessSelector sets the processes to scan.
+func WithProcessSelector(selector ProcessSelector) Option {
+	return func(s *Scanner) {
+		s.selector = selector
+	}
+}
+
+// WithProcessFilter adds a filter which selected processes must pass in order to be scanned.
+func WithProcessFilter(filter ProcessFilter) Option {
+	return func(s *Scanner) {
+		s.processFilters = append(s.processFilters, filter)
+	}
+}
+
+// WithRegionFilter adds a filter which memory maps must pass in order to be scanned.
+func WithRegionFilter(filter RegionFilter) Option {
+	return func(s *Scanner) {
+		s.regionFilters = append(s.regionFilters, filter)
+	}
+}
+
+// WithResultFilter adds a filter which every result must pass to be reported.
+func WithResultFilter(filter ResultFilter) Option {
+	return func(s *Scanner) {
+		s.resultFilters = append(s.resultFilters, filter)
+	}
+}
+
+// WithSources sets the sources of data to scan for each process. By default, only memory is scanned.
+func WithSources(sources ...Source) Option {
+	return func(s *Scanner) {
+		s.sources = sources
+	}
+}
+
+// WithEncodings sets the text encodings in which patterns are searched for. By default, only UTF-8 is searched.
+func WithEncodings(encodings ...Encoding) Option {
+	return func(s *Scanner) {
+		s.encodings = encodings
+	}
+}
+
+// WithMaxFileSize sets the size of the largest open file which will be scanned, when scanning SourceFDs.
+// Larger files are skipped.
+func WithMaxFileSize(size uint64) Option {
+	return func(s *Scanner) {
+		s.maxFileSize = size
+	}
+}
+
+// WithSelf includes the current process and its ancestors in the scan.
+func WithSelf(include bool) Option {
+	return func(s *Scanner) {
+		s.includeSelf = include
+	}
+}
+
+// WithWorkers sets the number of concurrent workers.
+func WithWorkers(workers int) Option {
+	return func(s *Scanner) {
+		s.workers = workers
+	}
+}
+
+// WithMaxBuffer sets the total amount of memory buffered across all workers.
+func WithMaxBuffer(size uint64) Option {
+	return func(s *Scanner) {
+		s.maxBuffer = size
+	}
+}
+
+// WithOverlap sets the number of bytes shared by consecutive windows of a map. Matches longer than this may be
+// missed if they cross a window boundary.
+func WithOverlap(size uint64) Option {
+	return func(s *Scanner) {
+		s.overlap = size
+	}
+}
+
+// WithContextRadius sets the number of lines of 16 bytes to read either side of each match.
+func WithContextRadius(lines int) Option {
+	return func(s *Scanner) {
+		s.contextRadius = lines
+	}
+}
+
+// WithMaxResults stops the scan once n results have been delivered. If n is 0, there is no limit.
+func WithMaxResults(n int) Option {
+	return func(s *Scanner) {
+		s.maxResults = n
+	}
+}
+
+// WithMaxResultsPerProcess limits the number of results delivered for each process to n. Once a process reaches
+// the limit, the rest of its memory is skipped. If n is 0, there is no limit.
+func WithMaxResultsPerProcess(n int) Option {
+	return func(s *Scanner) {
+		s.maxResultsPerProcess = n
+	}
+}
+
+// WithLogger sets a Logger for debug messages.
+func WithLogger(logger Logger) Option {
+	return func(s *Scanner) {
+		s.logger = logger
+	}
+}
+
+// Scan runs the scan, calling fn for each result. fn is never called concurrently.
+func (s *Scanner) Scan(ctx context.Context, fn func(Result)) error {
+	targets, err := s.Targets()
+	if err != nil {
+		return err
+	}
+	return s.run(ctx, targets, fn)
+}
+
+// Results runs the scan in the background, delivering results on the returned channel. The error channel
+// receives a single value once the results channel has been closed.
+func (s *Scanner) Results(ctx context.Context) (<-chan Result, <-chan error) {
+	results := make(chan Result)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		err := s.Scan(ctx, func(result Result) {
+			select {
+			case results <- result:
+			case <-ctx.Done():
+			}
+		})
+		close(results)
+		errs <- err
+	}()
+	return results, errs
+}
+
+// Targets returns the targets which will be scanned: those chosen by the process selector which pass every process
+// filter. Unless WithSelf is set, the current process and its ancestors are excluded.
+func (s *Scanner) Targets() ([]Target, error) {
+	targets, err := s.selector()
+	if err != nil {
+		return nil, err
+	}
+	self := proc.Self()
+	var selected []Target
+	for _, target := range targets {
+		process := target.Process()
+		// an image may have been taken on another machine, so its PID says nothing about the current process
+		if _, image := target.(*imageTarget); image {
+			selected = append(selected, target)
+			continue
+		}
+		if !s.includeSelf && process != proc.NoProcess && process.IsAncestor(self) {
+			continue
+		}
+		if !s.allowProcess(process) {
+			continue
+		}
+		selected = append(selected, target)
+	}
+	return selected, nil
+}
+
+func (s *Scanner) allowProcess(p proc.Process) bool {
+	for _, filter := range s.processFilters {
+		if !filter(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Scanner) log(format string, args ...interface{}) {
+	if s.logger != nil {
+		s.logger.Log(format, args...)
+	}
+}
@@ -0,0 +1,200 @@
+package scan
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/liamg/dismember/pkg/proc"
+)
+
+// Source is a kind of data read from a target.
+type Source string
+
+const (
+	SourceMemory  Source = "memory"
+	SourceEnv     Source = "env"
+	SourceCmdline Source = "cmdline"
+	SourceFDs     Source = "fds"
+)
+
+// ParseSources parses a comma-separated list of sources, e.g. "env,cmdline,memory".
+func ParseSources(s string) ([]Source, error) {
+	var sources []Source
+	for _, name := range strings.Split(s, ",") {
+		switch source := Source(strings.ToLower(strings.TrimSpace(name))); source {
+		case SourceMemory, SourceEnv, SourceCmdline, SourceFDs:
+			sources = append(sources, source)
+		default:
+			return nil, fmt.Errorf("invalid source '%s': must be one of memory, env, cmdline or fds", name)
+		}
+	}
+	return sources, nil
+}
+
+// sourceRegion returns the pseudo-map used for results from a source other than memory. Addresses of these
+// results are offsets into the data read from the source.
+func sourceRegion(u unit, size int) proc.Map {
+	region := proc.Map{
+		Size:        uint64(size),
+		Permissions: proc.MemPerms{Readable: true},
+		Path:        "[" + string(u.source) + "]",
+	}
+	if u.source == SourceFDs {
+		region.Path = u.file.Path
+		region.Device = u.file.Device
+		region.Inode = u.file.Inode
+	}
+	return region
+}
+
+// readSource reads the data for the environment or command line of a target.
+func (s *Scanner) readSource(u unit) ([]byte, error) {
+	switch u.source {
+	case SourceEnv:
+		return u.target.Environ()
+	case SourceCmdline:
+		return u.target.Cmdline()
+	default:
+		return nil, fmt.Errorf("unsupported source '%s'", u.source)
+	}
+}
+
+// fileID identifies a file by device and inode, so that files shared between processes are only scanned once.
+type fileID struct {
+	device uint64
+	inode  uint64
+}
+
+// files sends a unit for each open file of a target which should be scanned, returning false if sending was
+// stopped. Only regular files, including deleted files and memfds, are scanned. Files are read by the workers, and
+// a file shared between processes is only reported through the first process it could be read through.
+func (s *Scanner) files(t Target, send func(unit) bool) bool {
+	descriptors, err := t.FileDescriptors()
+	if err != nil {
+		s.log("failed to list open files for process %d: %s", t.Process(), err)
+		return true
+	}
+	for _, descriptor := range descriptors {
+		switch {
+		case !descriptor.Regular, descriptor.Size == 0:
+			continue
+		case descriptor.Size > s.maxFileSize:
+			s.log("skipping fd %d (%s) for process %d: file is larger than %d bytes", descriptor.FD, descriptor.Path, t.Process(), s.maxFileSize)
+			continue
+		case descriptor.Size > s.maxBuffer:
+			s.log("skipping fd %d (%s) for process %d: file is larger than the max buffer of %d bytes", descriptor.FD, descriptor.Path, t.Process(), s.maxBuffer)
+			continue
+		}
+		if !send(unit{target: t, source: SourceFDs, file: descriptor}) {
+			return false
+		}
+	}
+	return true
+}
+
+// envVariable is a single KEY=VALUE pair from an environment, with the offset of its value.
+type envVariable struct {
+	key         string
+	value       []byte
+	valueOffset int
+}
+
+// parseEnviron splits a NUL-separated environment into its variables.
+func parseEnviron(data []byte) []envVariable {
+	var variables []envVariable
+	var offset int
+	for _, entry := range bytes.Split(data, []byte{0}) {
+		if index := bytes.IndexByte(entry, '='); index > 0 {
+			variables = append(variables, envVariable{
+				key:         string(entry[:index]),
+				value:       entry[index+1:],
+				valueOffset: offset + index + 1,
+			})
+		}
+		offset += len(entry) + 1
+	}
+	return variables
+}
+
+// envKey returns the name of the variable containing the byte at offset, if any.
+func envKey(variables []envVariable, offset int) string {
+	for _, variable := range variables {
+		start := variable.valueOffset - len(variable.key) - 1
+		if offset >= start && offset < variable.valueOffset+len(variable.value) {
+			return variable.key
+		}
+	}
+	return ""
+}
+
+// argument is the value of a command-line flag, with the offset of the value within the command line.
+type argument struct {
+	flag   string // flag is the flag as it was written, e.g. "--api-key"
+	name   string // name is the name of the flag in the style of an environment variable, e.g. "api_key"
+	value  []byte
+	offset int
+}
+
+// flagName returns the name of a flag in the style of an environment variable.
+func flagName(flag []byte) string {
+	return strings.ReplaceAll(strings.TrimLeft(string(flag), "-"), "-", "_")
+}
+
+// shortPasswordPrograms take a password in a -p flag with the value attached, e.g. mysql -pPASSWORD. Other
+// programs use -p for unrelated options, such as gcc -pthread.
+var shortPasswordPrograms = regexp.MustCompile(`^(mysql|mariadb)`)
+
+// parseArguments finds the values of the flags in a NUL-separated command line, written as --name=value or
+// --name value, with one or two dashes. Programs which take a password as -pPASSWORD are also understood.
+func parseArguments(data []byte) []argument {
+	args := bytes.Split(data, []byte{0})
+	offsets := make([]int, len(args))
+	for i := 1; i < len(args); i++ {
+		offsets[i] = offsets[i-1] + len(args[i-1]) + 1
+	}
+	program := filepath.Base(string(args[0]))
+
+	var arguments []argument
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+		if string(arg) == "--" {
+			break
+		}
+		if arg[1] == 'p' && len(arg) > 2 && shortPasswordPrograms.MatchString(program) {
+			arguments = append(arguments, argument{flag: "-p", name: "password", value: arg[2:], offset: offsets[i] + 2})
+			continue
+		}
+		if index := bytes.IndexByte(arg, '='); index > 0 {
+			arguments = append(arguments, argument{flag: string(arg[:index]), name: flagName(arg[:index]), value: arg[index+1:], offset: offsets[i] + index + 1})
+			continue
+		}
+		if i+1 < len(args) && len(args[i+1]) > 0 && args[i+1][0] != '-' {
+			arguments = append(arguments, argument{flag: string(arg), name: flagName(arg), value: args[i+1], offset: offsets[i+1]})
+		}
+	}
+	return arguments
+}
+
+// blobReader is a MemoryReader over data read from a source other than memory.
+type blobReader []byte
+
+func (b blobReader) ReadAt(buf []byte, address uint64) (proc.Faults, error) {
+	if address > uint64(len(b)) {
+		return nil, fmt.Errorf("address %X is out of range", address)
+	}
+	n := copy(buf, b[address:])
+	for i := n; i < len(buf); i++ {
+		buf[i] = 0
+	}
+	return nil, nil
+}
+
+func (b blobReader) Close() error {
+	return nil
+}
@@ -0,0 +1,214 @@
+package scan
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/liamg/dismember/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseSources(t *testing.T) {
+	sources, err := ParseSources("env, cmdline,MEMORY")
+	require.NoError(t, err)
+	assert.Equal(t, []Source{SourceEnv, SourceCmdline, SourceMemory}, sources)
+
+	_, err = ParseSources("env,disk")
+	assert.Error(t, err)
+}
+
+func Test_ScannerSources(t *testing.T) {
+
+	target := newFakeTarget(1000).
+		withRegion(0x10000, "[heap]", []byte("password=heap")).
+		withEnviron(
+			"HOME=/root\x00DB_URL=password=env\x00GITHUB_TOKEN=abc123\x00DB_PASSWORD=\x00",
+			"mysql\x00-u\x00root\x00password=cmdline\x00",
+		)
+
+	scanner := New(
+		WithPatterns(secrets.Pattern{Name: "Password", Regex: regexp.MustCompile(`password=[a-z]+`)}),
+		WithProcessSelector(Targets(target)),
+		WithSelf(true),
+		WithSources(SourceEnv, SourceCmdline, SourceMemory),
+	)
+
+	results := collect(t, scanner)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, SourceEnv, results[0].Source)
+	assert.Equal(t, "password=env", string(results[0].Match))
+	assert.Equal(t, "DB_URL", results[0].Key)
+	assert.Equal(t, uint64(18), results[0].Address)
+	assert.Equal(t, "[env]", results[0].Map.Path)
+
+	assert.Equal(t, SourceEnv, results[1].Source)
+	assert.Equal(t, "Sensitive Environment Variable", results[1].Pattern.Name)
+	assert.Equal(t, "GITHUB_TOKEN", results[1].Key)
+	assert.Equal(t, "abc123", string(results[1].Match))
+
+	assert.Equal(t, SourceCmdline, results[2].Source)
+	assert.Equal(t, "password=cmdline", string(results[2].Match))
+	assert.Empty(t, results[2].Key)
+
+	assert.Equal(t, SourceMemory, results[3].Source)
+	assert.Equal(t, "password=heap", string(results[3].Match))
+}
+
+func Test_ScannerFileDescriptors(t *testing.T) {
+
+	shared := []byte("password=shared")
+	first := newFakeTarget(1000).
+		withFile(3, "/tmp/creds (deleted)", 10, []byte("user=root\npassword=deleted\n")).
+		withFile(4, "/etc/shared.conf", 20, shared)
+	second := newFakeTarget(1001).
+		withFile(3, "/etc/shared.conf", 20, shared).
+		withFile(5, "/var/log/huge.log", 30, bytes.Repeat([]byte("password=huge "), 100))
+
+	scanner := New(
+		WithPatterns(secrets.Pattern{Name: "Password", Regex: regexp.MustCompile(`password=[a-z]+`)}),
+		WithProcessSelector(Targets(second, first)),
+		WithSelf(true),
+		WithSources(SourceFDs),
+		WithMaxFileSize(1024),
+	)
+
+	results := collect(t, scanner)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, proc.Process(1000), results[0].Process)
+	assert.Equal(t, SourceFDs, results[0].Source)
+	assert.Equal(t, "password=deleted", string(results[0].Match))
+	assert.Equal(t, "/tmp/creds (deleted)", results[0].Map.Path)
+	assert.Equal(t, uint64(10), results[0].Address)
+	assert.Equal(t, "user=root\npassword=deleted\n", string(results[0].Context))
+
+	assert.Equal(t, proc.Process(1000), results[1].Process)
+	assert.Equal(t, "password=shared", string(results[1].Match))
+}
+
+func Test_ScannerFileDescriptorsUnreadable(t *testing.T) {
+
+	// the file can't be read through the first process, so it should be scanned through the second
+	shared := []byte("password=shared")
+	first := newFakeTarget(1000).withUnreadableFile(3, "/etc/shared.conf", 20, uint64(len(shared)))
+	second := newFakeTarget(1001).withFile(4, "/etc/shared.conf", 20, shared)
+
+	scanner := New(
+		WithPatterns(secrets.Pattern{Name: "Password", Regex: regexp.MustCompile(`password=[a-z]+`)}),
+		WithProcessSelector(Targets(first, second)),
+		WithSelf(true),
+		WithSources(SourceFDs),
+	)
+
+	results := collect(t, scanner)
+	require.Len(t, results, 1)
+	assert.Equal(t, proc.Process(1001), results[0].Process)
+	assert.Equal(t, "password=shared", string(results[0].Match))
+}
+
+func Test_ParseArguments(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		cmdline string
+		want    []argument
+	}{
+		{
+			name:    "flag with equals",
+			cmdline: "app\x00--password=hunter2\x00",
+			want:    []argument{{flag: "--password", name: "password", value: []byte("hunter2"), offset: 15}},
+		},
+		{
+			name:    "flag with separate value",
+			cmdline: "app\x00-v\x00--api-key\x00abc123\x00",
+			want:    []argument{{flag: "--api-key", name: "api_key", value: []byte("abc123"), offset: 17}},
+		},
+		{
+			name:    "attached short password",
+			cmdline: "/usr/bin/mysql\x00-uroot\x00-pPASSWORD\x00",
+			want:    []argument{{flag: "-p", name: "password", value: []byte("PASSWORD"), offset: 24}},
+		},
+		{
+			name:    "attached short flag for other programs",
+			cmdline: "gcc\x00-pthread\x00main.c\x00",
+			want:    []argument{{flag: "-pthread", name: "pthread", value: []byte("main.c"), offset: 13}},
+		},
+		{
+			name:    "end of flags",
+			cmdline: "app\x00--\x00--token=abc\x00",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, parseArguments([]byte(test.cmdline)))
+		})
+	}
+}
+
+func Test_ScannerSensitiveArguments(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		cmdline string
+		want    map[string]string // flag to value
+	}{
+		{
+			name:    "mysql short password",
+			cmdline: "mysql\x00-uroot\x00-pPASSWORD\x00db\x00",
+			want:    map[string]string{"-p": "PASSWORD"},
+		},
+		{
+			name:    "long flags",
+			cmdline: "app\x00--password=x\x00--token\x00abc123\x00--verbose\x00file\x00",
+			want:    map[string]string{"--password": "x", "--token": "abc123"},
+		},
+		{
+			name:    "single dash flags",
+			cmdline: "app\x00-db-pass\x00hunter2\x00-client-secret=s3cret\x00",
+			want:    map[string]string{"-db-pass": "hunter2", "-client-secret": "s3cret"},
+		},
+		{
+			name:    "unrelated flags",
+			cmdline: "gcc\x00-pthread\x00-o\x00main\x00main.c\x00",
+			want:    map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scanner := New(
+				WithProcessSelector(Targets(newFakeTarget(1000).withEnviron("", test.cmdline))),
+				WithSelf(true),
+				WithSources(SourceCmdline),
+			)
+			found := make(map[string]string)
+			for _, result := range collect(t, scanner) {
+				assert.Equal(t, "Sensitive Command-Line Argument", result.Pattern.Name)
+				assert.Equal(t, string(result.Match), test.cmdline[result.Address:int(result.Address)+len(result.Match)])
+				found[result.Key] = string(result.Match)
+			}
+			assert.Equal(t, test.want, found)
+		})
+	}
+}
+
+func Test_ScannerSensitiveArgumentMatchedByPattern(t *testing.T) {
+
+	target := newFakeTarget(1000).withEnviron("MYSQL_PWD=hunter2\x00", "app\x00--password=tok_abc\x00")
+	scanner := New(
+		WithPatterns(secrets.Pattern{Name: "Token", Regex: regexp.MustCompile(`tok_[a-z]+`)}),
+		WithProcessSelector(Targets(target)),
+		WithSelf(true),
+		WithSources(SourceEnv, SourceCmdline),
+	)
+
+	results := collect(t, scanner)
+	require.Len(t, results, 2)
+	assert.Equal(t, "MYSQL_PWD", results[0].Key)
+	assert.Equal(t, "Sensitive Environment Variable", results[0].Pattern.Name)
+	assert.Equal(t, "Token", results[1].Pattern.Name)
+	assert.Equal(t, "tok_abc", string(results[1].Match))
+}
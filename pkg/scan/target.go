@@ -0,0 +1,166 @@
+package scan
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/liamg/dismember/pkg/proc"
+)
+
+// Target is a source of memory which can be scanned, such as a running process.
+type Target interface {
+	proc.Memory
+	// Process returns the process which owns the memory.
+	Process() proc.Process
+	// Name returns the name of the process.
+	Name() string
+	// Executable returns the path of the executable run by the process.
+	Executable() (string, error)
+	// Environ returns the initial environment of the target, as NUL-separated KEY=VALUE pairs.
+	Environ() ([]byte, error)
+	// Cmdline returns the command line of the target, as NUL-separated arguments.
+	Cmdline() ([]byte, error)
+	// FileDescriptors returns the files held open by the target.
+	FileDescriptors() ([]proc.FileDescriptor, error)
+	// ReadFileDescriptor reads up to limit bytes from the start of a file held open by the target.
+	ReadFileDescriptor(fd int, limit uint64) ([]byte, error)
+}
+
+// processTarget is a Target for a running process.
+type processTarget struct {
+	proc.Memory
+	process proc.Process
+}
+
+// ProcessTarget returns a Target for the given running process.
+func ProcessTarget(p proc.Process) Target {
+	return &processTarget{Memory: proc.Live(p), process: p}
+}
+
+func (t *processTarget) Process() proc.Process {
+	return t.process
+}
+
+func (t *processTarget) Name() string {
+	return t.process.Name()
+}
+
+func (t *processTarget) Executable() (string, error) {
+	return t.process.Executable()
+}
+
+func (t *processTarget) Environ() ([]byte, error) {
+	return t.process.Environ()
+}
+
+func (t *processTarget) Cmdline() ([]byte, error) {
+	return t.process.Cmdline()
+}
+
+func (t *processTarget) FileDescriptors() ([]proc.FileDescriptor, error) {
+	return t.process.FileDescriptors()
+}
+
+func (t *processTarget) ReadFileDescriptor(fd int, limit uint64) ([]byte, error) {
+	return t.process.ReadFileDescriptor(fd, limit)
+}
+
+// imageTarget is a Target for a saved memory image. Only its memory can be scanned.
+type imageTarget struct {
+	proc.Memory
+	process proc.Process
+	name    string
+}
+
+// ImageTarget returns a Target for a saved memory image, taken from the given process. The process is only used to
+// label results: it is never read, and need not exist on this machine.
+func ImageTarget(mem proc.Memory, process proc.Process, name string) Target {
+	return &imageTarget{Memory: mem, process: process, name: name}
+}
+
+func (t *imageTarget) Process() proc.Process {
+	return t.process
+}
+
+func (t *imageTarget) Name() string {
+	return t.name
+}
+
+func (t *imageTarget) Executable() (string, error) {
+	return "", errNotInImage
+}
+
+func (t *imageTarget) Environ() ([]byte, error) {
+	return nil, errNotInImage
+}
+
+func (t *imageTarget) Cmdline() ([]byte, error) {
+	return nil, errNotInImage
+}
+
+func (t *imageTarget) FileDescriptors() ([]proc.FileDescriptor, error) {
+	return nil, errNotInImage
+}
+
+func (t *imageTarget) ReadFileDescriptor(int, uint64) ([]byte, error) {
+	return nil, errNotInImage
+}
+
+var errNotInImage = errors.New("not available in a memory image")
+
+// ProcessSelector returns the targets which should be scanned.
+type ProcessSelector func() ([]Target, error)
+
+// AllProcesses selects every process available to the current user. The current process is only scanned if
+// WithSelf is set.
+func AllProcesses() ProcessSelector {
+	return func() ([]Target, error) {
+		processes, err := proc.List(true)
+		if err != nil {
+			return nil, err
+		}
+		return Processes(processes...)()
+	}
+}
+
+// Processes selects the given processes.
+func Processes(processes ...proc.Process) ProcessSelector {
+	return func() ([]Target, error) {
+		targets := make([]Target, 0, len(processes))
+		for _, process := range processes {
+			targets = append(targets, ProcessTarget(process))
+		}
+		return targets, nil
+	}
+}
+
+// Targets selects the given targets.
+func Targets(targets ...Target) ProcessSelector {
+	return func() ([]Target, error) {
+		return targets, nil
+	}
+}
+
+// ProcessFilter decides whether a selected process should be scanned.
+type ProcessFilter func(p proc.Process) bool
+
+// NameContains matches processes whose name contains the given string.
+func NameContains(name string) ProcessFilter {
+	return func(p proc.Process) bool {
+		status, err := p.Status()
+		if err != nil {
+			return false
+		}
+		return strings.Contains(status.Name, name)
+	}
+}
+
+// sortTargets orders targets by PID, so results are delivered deterministically.
+func sortTargets(targets []Target) []Target {
+	sorted := append([]Target(nil), targets...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Process() < sorted[j].Process()
+	})
+	return sorted
+}
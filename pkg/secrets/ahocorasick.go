@@ -0,0 +1,93 @@
+package secrets
+
+// lowerTable maps each byte to its ASCII lower-case equivalent.
+var lowerTable = func() (table [256]byte) {
+	for i := range table {
+		table[i] = byte(i)
+		if i >= 'A' && i <= 'Z' {
+			table[i] = byte(i) + ('a' - 'A')
+		}
+	}
+	return table
+}()
+
+// automaton is an Aho-Corasick automaton which finds any of a set of keywords in a single pass over the
+// input, ignoring ASCII case. The goto function is stored as a dense table, which trades memory for speed;
+// with the number of keywords used for secret patterns it remains small.
+type automaton struct {
+	transitions [][256]int32
+	outputs     [][]int // outputs lists the keyword indexes which end at each state
+}
+
+func newAutomaton(keywords []string) *automaton {
+
+	a := &automaton{
+		transitions: make([][256]int32, 1),
+		outputs:     make([][]int, 1),
+	}
+	for i := range a.transitions[0] {
+		a.transitions[0][i] = -1
+	}
+
+	// build the trie
+	for index, keyword := range keywords {
+		state := int32(0)
+		for i := 0; i < len(keyword); i++ {
+			c := lowerTable[keyword[i]]
+			if a.transitions[state][c] == -1 {
+				var row [256]int32
+				for j := range row {
+					row[j] = -1
+				}
+				a.transitions = append(a.transitions, row)
+				a.outputs = append(a.outputs, nil)
+				a.transitions[state][c] = int32(len(a.transitions) - 1)
+			}
+			state = a.transitions[state][c]
+		}
+		a.outputs[state] = append(a.outputs[state], index)
+	}
+
+	// compute failure links breadth-first, folding them into the transition table
+	failure := make([]int32, len(a.transitions))
+	var queue []int32
+	for c := 0; c < 256; c++ {
+		next := a.transitions[0][c]
+		if next == -1 {
+			a.transitions[0][c] = 0
+			continue
+		}
+		failure[next] = 0
+		queue = append(queue, next)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		a.outputs[state] = append(a.outputs[state], a.outputs[failure[state]]...)
+		for c := 0; c < 256; c++ {
+			next := a.transitions[state][c]
+			if next == -1 {
+				a.transitions[state][c] = a.transitions[failure[state]][c]
+				continue
+			}
+			failure[next] = a.transitions[failure[state]][c]
+			queue = append(queue, next)
+		}
+	}
+
+	return a
+}
+
+// find calls fn with the index of each keyword found in data. fn may be called more than once per keyword.
+// If fn returns false, the search stops.
+func (a *automaton) find(data []byte, fn func(keyword int) bool) {
+	state := int32(0)
+	for _, b := range data {
+		state = a.transitions[state][lowerTable[b]]
+		for _, keyword := range a.outputs[state] {
+			if !fn(keyword) {
+				return
+			}
+		}
+	}
+}
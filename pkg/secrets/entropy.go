@@ -0,0 +1,185 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+)
+
+// EntropyConfig configures the detection of generic high-entropy strings.
+//
+// A string of n characters has at most log2(n) bits of entropy per byte, so a fixed threshold would either reject
+// every short key or accept long runs of text. Instead, each threshold is the entropy required of a long string,
+// and the threshold for a string of n characters is scaled by ExpectedEntropy(n, alphabet) / log2(alphabet): the
+// entropy expected of a random string of that length, relative to the most its alphabet can reach.
+type EntropyConfig struct {
+	MinLength       int     // MinLength is the shortest run of characters considered
+	MaxLength       int     // MaxLength is the longest run of characters considered, as long runs are rarely keys
+	Base64Threshold float64 // Base64Threshold is the minimum entropy of long base64 and alphanumeric strings
+	HexThreshold    float64 // HexThreshold is the minimum entropy of long hex strings
+}
+
+const (
+	base64Alphabet = 64
+	hexAlphabet    = 16
+)
+
+// DefaultEntropyConfig returns thresholds tuned to ignore the identifiers, compiled code and compressed data
+// found in mapped libraries. Random 20+ character runs of a restricted alphabet are vanishingly rare in binary
+// data, and the character-mix requirements discard words, camel-case identifiers and alphabet tables. The
+// thresholds are 90% and 87.5% of the entropy of their alphabets, which about 99% of random base64 strings and
+// 98% of random hex strings reach at the minimum length of 20 characters.
+func DefaultEntropyConfig() EntropyConfig {
+	return EntropyConfig{
+		MinLength:       20,
+		MaxLength:       256,
+		Base64Threshold: 5.4,
+		HexThreshold:    3.5,
+	}
+}
+
+// EntropyPatterns returns patterns which detect high-entropy base64, alphanumeric and hex strings with no
+// recognisable prefix, such as generic API keys.
+func EntropyPatterns(config EntropyConfig) []Pattern {
+	minLength := config.MinLength
+	if minLength < 1 {
+		minLength = 1
+	}
+	bounds := func(charset string, suffix string) *regexp.Regexp {
+		return regexp.MustCompile(fmt.Sprintf("%s{%d,}%s", charset, minLength, suffix))
+	}
+	source := "entropy"
+	return []Pattern{
+		{
+			Regex:        bounds(`[A-Za-z0-9+/]`, `={0,2}`),
+			Name:         "High Entropy Base64 String",
+			Source:       source,
+			Description:  "A base64 or alphanumeric string with high Shannon entropy, which may be a secret key",
+			MinEntropy:   config.Base64Threshold,
+			EntropyScale: entropyScale(base64Alphabet, config.MaxLength),
+			Check: func(secret []byte) bool {
+				// hex strings are left to the hex pattern, so they aren't reported twice
+				return len(secret) <= config.MaxLength && hasCharacterMix(secret, true) && !isSequential(secret) && !isHex(secret)
+			},
+		},
+		{
+			Regex:        bounds(`[0-9a-fA-F]`, ``),
+			Name:         "High Entropy Hex String",
+			Source:       source,
+			Description:  "A hex string with high Shannon entropy, which may be a secret key",
+			MinEntropy:   config.HexThreshold,
+			EntropyScale: entropyScale(hexAlphabet, config.MaxLength),
+			Check: func(secret []byte) bool {
+				return len(secret) <= config.MaxLength && hasCharacterMix(secret, false) && !isSequential(secret)
+			},
+		},
+	}
+}
+
+// entropyScale returns the factor by which the threshold for a string of the given length is scaled: the entropy
+// expected of a random string of that length over the alphabet, relative to the entropy of the alphabet. Factors
+// are calculated up front for lengths up to maxLength, and longer strings, which are rejected anyway, use the
+// factor for maxLength.
+func entropyScale(alphabet int, maxLength int) func(length int) float64 {
+	max := math.Log2(float64(alphabet))
+	if maxLength < 1 {
+		maxLength = 1
+	}
+	scales := make([]float64, maxLength+1)
+	for length := range scales {
+		scales[length] = ExpectedEntropy(length, alphabet) / max
+	}
+	return func(length int) float64 {
+		if length >= len(scales) {
+			length = len(scales) - 1
+		}
+		return scales[length]
+	}
+}
+
+// ExpectedEntropy returns the expected Shannon entropy, in bits per byte, of a string of the given length whose
+// characters are chosen uniformly at random from an alphabet of the given size.
+func ExpectedEntropy(length int, alphabet int) float64 {
+	if length < 2 || alphabet < 2 {
+		return 0
+	}
+	// the count of each character is binomially distributed, so sum count*log2(count) over the distribution
+	n := float64(length)
+	p := 1 / float64(alphabet)
+	lgammaN, _ := math.Lgamma(n + 1)
+	var expected float64
+	for count := 1; count <= length; count++ {
+		c := float64(count)
+		lgammaC, _ := math.Lgamma(c + 1)
+		lgammaRest, _ := math.Lgamma(n - c + 1)
+		probability := math.Exp(lgammaN - lgammaC - lgammaRest + c*math.Log(p) + (n-c)*math.Log1p(-p))
+		expected += probability * c * math.Log2(c)
+		if c > n*p && probability < 1e-15 {
+			break
+		}
+	}
+	return math.Log2(n) - float64(alphabet)*expected/n
+}
+
+// hasCharacterMix requires digits and letters to be present, and if mixedCase is set, both upper and lower case
+// letters. Random keys almost always contain all of these, whereas words and identifiers rarely do.
+func hasCharacterMix(secret []byte, mixedCase bool) bool {
+	var digit, upper, lower bool
+	for _, c := range secret {
+		switch {
+		case c >= '0' && c <= '9':
+			digit = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		}
+	}
+	if mixedCase {
+		return digit && upper && lower
+	}
+	return digit && (upper || lower)
+}
+
+// isHex returns true if every character of the secret is a hex digit.
+func isHex(secret []byte) bool {
+	for _, c := range secret {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// isSequential returns true if most adjacent characters are consecutive, as in the alphabet tables used by
+// encoders, which have high entropy but are not secret.
+func isSequential(secret []byte) bool {
+	var sequential int
+	for i := 1; i < len(secret); i++ {
+		if secret[i] == secret[i-1]+1 {
+			sequential++
+		}
+	}
+	return sequential*2 > len(secret)
+}
+
+// Entropy returns the Shannon entropy of the data, in bits per byte.
+func Entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	var entropy float64
+	size := float64(len(data))
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / size
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
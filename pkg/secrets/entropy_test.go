@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Entropy(t *testing.T) {
+	assert.Equal(t, 0.0, Entropy(nil))
+	assert.Equal(t, 0.0, Entropy([]byte("aaaaaaaa")))
+	assert.Equal(t, 1.0, Entropy([]byte("abababab")))
+	assert.Equal(t, 4.0, Entropy([]byte("0123456789abcdef")))
+}
+
+func Test_EntropyPatterns(t *testing.T) {
+
+	matcher := NewMatcher(EntropyPatterns(DefaultEntropyConfig()))
+
+	tests := []struct {
+		name    string
+		input   string
+		pattern string
+	}{
+		{name: "random base64", input: `key="q8Zr2LmT9vXw4KpY7nBd1FsHj3Gc"`, pattern: "High Entropy Base64 String"},
+		{name: "random hex", input: `id=9f86d081884c7d659a2feaa0c55ad015a3bf4f1b`, pattern: "High Entropy Hex String"},
+		{name: "20 character key", input: `token=Xk4Tq8Lz2RwB7nHc9VjM;`, pattern: "High Entropy Base64 String"},
+		{name: "24 character key", input: `"9sKd2PqLx7WzN4bRt6YmC1Hv"`, pattern: "High Entropy Base64 String"},
+		{name: "40 character key", input: `secret=wJalrXUtnFEMI/K7MDENG/bPxRfiCYzEXAMPLE`, pattern: "High Entropy Base64 String"},
+		{name: "20 character hex", input: `id=3f9a0c7e21b8d45f6e0a;`, pattern: "High Entropy Hex String"},
+		{name: "32 character hex", input: `key=5d41402abc4b2a76b9719d911017c592`, pattern: "High Entropy Hex String"},
+		{name: "20 character word", input: `internationalisation`},
+		{name: "20 character repeated word", input: `Password1Password1Pa`},
+		{name: "21 character identifier", input: `getElementByIdAndName`},
+		{name: "identifier", input: `_ZN4core3fmt9Formatter9write_str17h`},
+		{name: "word", input: `internationalisationandlocalisation`},
+		{name: "alphabet table", input: `ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/`},
+		{name: "short", input: `q8Zr2LmT9vXw`},
+		{name: "low entropy", input: `aaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1A`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matches := matcher.FindAll([]byte(test.input))
+			if test.pattern == "" {
+				assert.Empty(t, matches)
+				return
+			}
+			require.NotEmpty(t, matches)
+			assert.Equal(t, test.pattern, matches[0].Pattern.Name)
+			assert.GreaterOrEqual(t, matches[0].Entropy, matches[0].Pattern.minEntropy(matches[0].End-matches[0].Start))
+		})
+	}
+}
+
+func Test_EntropyPatternsMixedCaseHex(t *testing.T) {
+
+	// mixed-case hex also matches the base64 pattern, but is only reported once, as hex
+	input := []byte(`id=9aF3c7E1b5D0e8A4f2C6;`)
+	matches := NewMatcher(EntropyPatterns(DefaultEntropyConfig())).FindAll(input)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "High Entropy Hex String", matches[0].Pattern.Name)
+	assert.Equal(t, "9aF3c7E1b5D0e8A4f2C6", string(input[matches[0].Start:matches[0].End]))
+}
+
+func Test_ExpectedEntropy(t *testing.T) {
+	assert.Equal(t, 0.0, ExpectedEntropy(1, 64))
+	assert.InDelta(t, 4.042, ExpectedEntropy(20, 64), 0.001)
+	assert.InDelta(t, 3.362, ExpectedEntropy(20, 16), 0.001)
+	assert.InDelta(t, 4.0, ExpectedEntropy(100000, 16), 0.001)
+	assert.Less(t, ExpectedEntropy(20, 64), math.Log2(20))
+}
+
+// Test_EntropyPatternsRandomKeys checks that random keys at and just above the minimum length are found, as a
+// 20 character string cannot reach the entropy of a long one.
+func Test_EntropyPatternsRandomKeys(t *testing.T) {
+
+	matcher := NewMatcher(EntropyPatterns(DefaultEntropyConfig()))
+	random := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name     string
+		alphabet string
+		length   int
+		pattern  string
+	}{
+		{name: "base64 20", alphabet: "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/", length: 20, pattern: "High Entropy Base64 String"},
+		{name: "base64 24", alphabet: "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/", length: 24, pattern: "High Entropy Base64 String"},
+		{name: "alphanumeric 32", alphabet: "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", length: 32, pattern: "High Entropy Base64 String"},
+		{name: "hex 20", alphabet: "0123456789abcdef", length: 20, pattern: "High Entropy Hex String"},
+		{name: "hex 40", alphabet: "0123456789abcdef", length: 40, pattern: "High Entropy Hex String"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var candidates, found int
+			for i := 0; i < 1000; i++ {
+				key := make([]byte, test.length)
+				for j := range key {
+					key[j] = test.alphabet[random.Intn(len(test.alphabet))]
+				}
+				// keys without the required mix of characters are rejected regardless of their entropy
+				if !hasCharacterMix(key, test.pattern == "High Entropy Base64 String") {
+					continue
+				}
+				candidates++
+				for _, match := range matcher.FindAll(append(append([]byte(" "), key...), ' ')) {
+					if match.Pattern.Name == test.pattern {
+						found++
+						break
+					}
+				}
+			}
+			assert.GreaterOrEqual(t, float64(found)/float64(candidates), 0.95, "%d of %d keys found", found, candidates)
+		})
+	}
+}
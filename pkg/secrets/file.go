@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Example:
+//
+// patterns:
+//   - name: Acme API Key
+//     regex: 'acme_[a-z0-9]{32}'
+//     description: API key for the internal Acme service
+//     severity: high
+//     keywords: [acme_]
+//   - name: Acme Session
+//     regex: 'eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*'
+//     validator: jwt
+
+type patternFile struct {
+	Patterns []patternDefinition `yaml:"patterns"`
+}
+
+type patternDefinition struct {
+	Name        string   `yaml:"name"`
+	Regex       string   `yaml:"regex"`
+	Description string   `yaml:"description"`
+	Severity    string   `yaml:"severity"`
+	Keywords    []string `yaml:"keywords"`
+	Validator   string   `yaml:"validator"`
+}
+
+// LoadPatterns reads a YAML file of custom patterns. Every invalid pattern in the file is reported in the
+// returned error, rather than stopping at the first.
+func LoadPatterns(path string) ([]Pattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsePatterns(data, path)
+}
+
+func parsePatterns(data []byte, source string) ([]Pattern, error) {
+
+	var file patternFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	var patterns []Pattern
+	var problems []string
+	for i, definition := range file.Patterns {
+		name := definition.Name
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("pattern #%d: name is required", i+1))
+			continue
+		}
+		if definition.Regex == "" {
+			problems = append(problems, fmt.Sprintf("pattern '%s': regex is required", name))
+			continue
+		}
+		regex, err := regexp.Compile(definition.Regex)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("pattern '%s': invalid regex: %s", name, err))
+			continue
+		}
+		severity, err := ParseSeverity(definition.Severity)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("pattern '%s': %s", name, err))
+			continue
+		}
+		if definition.Validator != "" && !IsValidator(definition.Validator) {
+			problems = append(problems, fmt.Sprintf("pattern '%s': unknown validator '%s': expected github, aws, jwt or pem", name, definition.Validator))
+			continue
+		}
+		patterns = append(patterns, Pattern{
+			Regex:       regex,
+			Name:        name,
+			Source:      source,
+			Description: definition.Description,
+			Severity:    severity,
+			Keywords:    definition.Keywords,
+			Validator:   definition.Validator,
+		})
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s: %d invalid pattern(s):\n  %s", source, len(problems), strings.Join(problems, "\n  "))
+	}
+	return patterns, nil
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParsePatterns(t *testing.T) {
+
+	patterns, err := parsePatterns([]byte(`
+patterns:
+  - name: Acme API Key
+    regex: 'acme_[a-z0-9]{32}'
+    description: API key for the internal Acme service
+    severity: High
+    keywords: [acme_]
+  - name: Session Cookie
+    regex: 'SESSIONID=[A-F0-9]{16}'
+    validator: jwt
+`), "custom.yaml")
+	require.NoError(t, err)
+	require.Len(t, patterns, 2)
+
+	assert.Equal(t, "Acme API Key", patterns[0].Name)
+	assert.Equal(t, "custom.yaml", patterns[0].Source)
+	assert.Equal(t, "API key for the internal Acme service", patterns[0].Description)
+	assert.Equal(t, SeverityHigh, patterns[0].Severity)
+	assert.Equal(t, []string{"acme_"}, patterns[0].Keywords)
+	assert.Equal(t, `acme_[a-z0-9]{32}`, patterns[0].Regex.String())
+
+	assert.Empty(t, patterns[0].Validator)
+	assert.Equal(t, SeverityNone, patterns[1].Severity)
+	assert.Equal(t, ValidatorJWT, patterns[1].Validator)
+}
+
+func Test_ParsePatternsReportsAllProblems(t *testing.T) {
+
+	_, err := parsePatterns([]byte(`
+patterns:
+  - name: Broken
+    regex: 'acme_[a-z'
+  - regex: 'nameless'
+  - name: Unknown Severity
+    regex: 'abc'
+    severity: apocalyptic
+  - name: Unknown Validator
+    regex: 'abc'
+    validator: stripe
+  - name: Fine
+    regex: 'fine'
+`), "custom.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "4 invalid pattern(s)")
+	assert.Contains(t, err.Error(), "pattern 'Broken': invalid regex")
+	assert.Contains(t, err.Error(), "pattern #2: name is required")
+	assert.Contains(t, err.Error(), "pattern 'Unknown Severity': invalid severity")
+	assert.Contains(t, err.Error(), "pattern 'Unknown Validator': unknown validator 'stripe'")
+}
+
+func Test_MatcherKeywords(t *testing.T) {
+
+	patterns, err := parsePatterns([]byte(`
+patterns:
+  - name: Internal Token
+    regex: '[a-z]{4}_[0-9]{8}'
+    keywords: [ITOK_]
+`), "custom.yaml")
+	require.NoError(t, err)
+
+	matcher := NewMatcher(patterns)
+	assert.Empty(t, matcher.FindAll([]byte("abcd_12345678")))
+	assert.Len(t, matcher.FindAll([]byte("itok_12345678")), 1)
+}
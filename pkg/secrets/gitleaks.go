@@ -0,0 +1,130 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// see https://github.com/gitleaks/gitleaks#configuration
+
+type gitleaksConfig struct {
+	Title     string            `toml:"title"`
+	Rules     []gitleaksRule    `toml:"rules"`
+	Allowlist gitleaksAllowlist `toml:"allowlist"`
+}
+
+type gitleaksRule struct {
+	ID          string              `toml:"id"`
+	Description string              `toml:"description"`
+	Regex       string              `toml:"regex"`
+	SecretGroup int                 `toml:"secretGroup"`
+	Entropy     float64             `toml:"entropy"`
+	Keywords    []string            `toml:"keywords"`
+	Path        string              `toml:"path"`
+	Allowlist   gitleaksAllowlist   `toml:"allowlist"`
+	Allowlists  []gitleaksAllowlist `toml:"allowlists"`
+}
+
+type gitleaksAllowlist struct {
+	Regexes     []string `toml:"regexes"`
+	RegexTarget string   `toml:"regexTarget"`
+	Stopwords   []string `toml:"stopwords"`
+}
+
+// gitleaksValidators maps the IDs of rules in the default gitleaks configuration to the offline check for their
+// matches.
+var gitleaksValidators = map[string]string{
+	"github-pat":       ValidatorGitHub,
+	"github-oauth":     ValidatorGitHub,
+	"aws-access-token": ValidatorAWS,
+	"jwt":              ValidatorJWT,
+	"private-key":      ValidatorPEM,
+}
+
+// LoadGitleaksConfig reads the rules from a gitleaks TOML configuration file. Rules which only match file paths
+// are skipped, as there are no files in memory. Every invalid rule in the file is reported in the returned error.
+func LoadGitleaksConfig(path string) ([]Pattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseGitleaksConfig(data, path)
+}
+
+func parseGitleaksConfig(data []byte, source string) ([]Pattern, error) {
+
+	var config gitleaksConfig
+	if _, err := toml.Decode(string(data), &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	var problems []string
+
+	globalAllowlist, globalStopwords, err := compileGitleaksAllowlists(config.Allowlist)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("global allowlist: %s", err))
+	}
+
+	var patterns []Pattern
+	for i, rule := range config.Rules {
+		name := rule.ID
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.Regex == "" {
+			continue
+		}
+		regex, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("rule '%s': invalid regex: %s", name, err))
+			continue
+		}
+		if rule.SecretGroup > regex.NumSubexp() {
+			problems = append(problems, fmt.Sprintf("rule '%s': secret group %d does not exist", name, rule.SecretGroup))
+			continue
+		}
+		allowlist, stopwords, err := compileGitleaksAllowlists(append([]gitleaksAllowlist{rule.Allowlist}, rule.Allowlists...)...)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("rule '%s': %s", name, err))
+			continue
+		}
+		patterns = append(patterns, Pattern{
+			Regex:       regex,
+			Name:        name,
+			Source:      source,
+			Description: rule.Description,
+			Keywords:    rule.Keywords,
+			SecretGroup: rule.SecretGroup,
+			MinEntropy:  rule.Entropy,
+			Allowlist:   append(allowlist, globalAllowlist...),
+			Stopwords:   append(stopwords, globalStopwords...),
+			Validator:   gitleaksValidators[rule.ID],
+		})
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s: %d invalid rule(s):\n  %s", source, len(problems), strings.Join(problems, "\n  "))
+	}
+	return patterns, nil
+}
+
+func compileGitleaksAllowlists(allowlists ...gitleaksAllowlist) ([]*regexp.Regexp, []string, error) {
+	var regexes []*regexp.Regexp
+	var stopwords []string
+	for _, allowlist := range allowlists {
+		// there are no lines in memory, so all regexes are applied to the secret regardless of their regexTarget
+		for _, expr := range allowlist.Regexes {
+			regex, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid allowlist regex: %w", err)
+			}
+			regexes = append(regexes, regex)
+		}
+		stopwords = append(stopwords, allowlist.Stopwords...)
+	}
+	return regexes, stopwords, nil
+}
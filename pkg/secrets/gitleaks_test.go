@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGitleaksConfig = `
+title = "acme gitleaks config"
+
+[[rules]]
+id = "acme-api-key"
+description = "Acme API Key"
+regex = '''acme_key\s*=\s*"([a-z0-9]{16})"'''
+secretGroup = 1
+entropy = 3.0
+keywords = ["acme_key"]
+
+[rules.allowlist]
+regexes = ['''^0123456789abcdef$''']
+
+[[rules]]
+id = "acme-session"
+regex = '''SESSION-[A-Z]{8}'''
+[[rules.allowlists]]
+stopwords = ["example"]
+
+[[rules]]
+id = "jwt"
+regex = '''\b(ey[a-zA-Z0-9]{17,}\.ey[a-zA-Z0-9\/\\_-]{17,}\.[a-zA-Z0-9\/\\_-]{10,}={0,2})'''
+
+[[rules]]
+id = "env-files"
+path = '''\.env$'''
+
+[allowlist]
+regexes = ['''EXAMPLEKEY''']
+`
+
+func Test_ParseGitleaksConfig(t *testing.T) {
+
+	patterns, err := parseGitleaksConfig([]byte(testGitleaksConfig), ".gitleaks.toml")
+	require.NoError(t, err)
+	require.Len(t, patterns, 3)
+
+	assert.Equal(t, "acme-api-key", patterns[0].Name)
+	assert.Equal(t, "Acme API Key", patterns[0].Description)
+	assert.Equal(t, ".gitleaks.toml", patterns[0].Source)
+	assert.Equal(t, []string{"acme_key"}, patterns[0].Keywords)
+	assert.Equal(t, 1, patterns[0].SecretGroup)
+	assert.Equal(t, 3.0, patterns[0].MinEntropy)
+	assert.Len(t, patterns[0].Allowlist, 2)
+
+	assert.Equal(t, "acme-session", patterns[1].Name)
+	assert.Equal(t, []string{"example"}, patterns[1].Stopwords)
+	assert.Empty(t, patterns[1].Validator)
+
+	// rules from the default gitleaks configuration are checked offline
+	assert.Equal(t, "jwt", patterns[2].Name)
+	assert.Equal(t, ValidatorJWT, patterns[2].Validator)
+}
+
+func Test_GitleaksFilters(t *testing.T) {
+
+	patterns, err := parseGitleaksConfig([]byte(testGitleaksConfig), ".gitleaks.toml")
+	require.NoError(t, err)
+	matcher := NewMatcher(patterns)
+
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{name: "high entropy secret", input: `acme_key = "x8k2m9q4w7e1r5t3"`, want: 1},
+		{name: "low entropy secret", input: `acme_key = "aaaaaaaaaaaaaaaa"`, want: 0},
+		{name: "allowlisted secret", input: `acme_key = "0123456789abcdef"`, want: 0},
+		{name: "session", input: `SESSION-ABCDEFGH`, want: 1},
+		{name: "session stopword", input: `SESSION-EXAMPLEX`, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Len(t, matcher.FindAll([]byte(test.input)), test.want)
+		})
+	}
+}
+
+func Test_ParseGitleaksConfigReportsProblems(t *testing.T) {
+	_, err := parseGitleaksConfig([]byte(`
+[[rules]]
+id = "broken"
+regex = '''[a-'''
+
+[[rules]]
+id = "bad-group"
+regex = '''abc'''
+secretGroup = 2
+`), ".gitleaks.toml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 invalid rule(s)")
+	assert.Contains(t, err.Error(), "rule 'broken': invalid regex")
+	assert.Contains(t, err.Error(), "rule 'bad-group': secret group 2 does not exist")
+}
+
+func Test_ParseTrufflehogDetectors(t *testing.T) {
+
+	patterns, err := parseTrufflehogDetectors([]byte(`
+detectors:
+  - name: HogTokenDetector
+    keywords:
+      - hog
+    regex:
+      hogToken: '[^A-Za-z0-9+\/]{0,1}([A-Za-z0-9+\/]{40})[^A-Za-z0-9+\/]{0,1}'
+      hogID: '\b(HOG[0-9A-Z]{17})\b'
+`), "trufflehog.yaml")
+	require.NoError(t, err)
+	require.Len(t, patterns, 2)
+
+	assert.Equal(t, "HogTokenDetector (hogID)", patterns[0].Name)
+	assert.Equal(t, "HogTokenDetector (hogToken)", patterns[1].Name)
+	assert.Equal(t, "trufflehog.yaml", patterns[0].Source)
+	assert.Equal(t, []string{"hog"}, patterns[0].Keywords)
+}
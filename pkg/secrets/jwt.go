@@ -0,0 +1,74 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var jwtRegex = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// jwtClaims are the registered claims which are reported when a JWT is decoded.
+var jwtClaims = []string{"iss", "sub", "aud", "jti"}
+
+// validateJWT decodes the header and claims of a JSON Web Token. The signature cannot be checked without the
+// key, so a well-formed token which has not expired is considered valid.
+func validateJWT(match []byte, _ []byte) (Validation, bool) {
+	token := jwtRegex.Find(match)
+	if token == nil {
+		return Validation{}, false
+	}
+	parts := bytes.Split(token, []byte("."))
+
+	var header map[string]interface{}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return invalid("jwt", fmt.Sprintf("failed to decode header: %s", err), nil)
+	}
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return invalid("jwt", fmt.Sprintf("failed to decode claims: %s", err), nil)
+	}
+
+	details := make(map[string]string)
+	for _, key := range []string{"alg", "typ", "kid"} {
+		if value, ok := header[key]; ok {
+			details[key] = fmt.Sprint(value)
+		}
+	}
+	if _, ok := header["alg"]; !ok {
+		return invalid("jwt", "header has no algorithm", details)
+	}
+	for _, key := range jwtClaims {
+		if value, ok := claims[key]; ok {
+			details[key] = fmt.Sprint(value)
+		}
+	}
+	var expiry time.Time
+	for _, key := range []string{"iat", "nbf", "exp"} {
+		if value, ok := claims[key].(float64); ok {
+			t := time.Unix(int64(value), 0).UTC()
+			details[key] = t.Format(time.RFC3339)
+			if key == "exp" {
+				expiry = t
+			}
+		}
+	}
+	if !expiry.IsZero() && expiry.Before(now()) {
+		return invalid("jwt", fmt.Sprintf("token expired at %s", expiry.Format(time.RFC3339)), details)
+	}
+	if len(parts[2]) == 0 {
+		return valid("jwt", "token is well-formed but unsigned", details)
+	}
+	return valid("jwt", "token is well-formed and has not expired", details)
+}
+
+func decodeJWTPart(part []byte, target interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(string(part))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, target)
+}
@@ -0,0 +1,31 @@
+package secrets
+
+import "regexp"
+
+// sensitiveKeys matches the names of variables and flags which usually hold secrets.
+var sensitiveKeys = regexp.MustCompile(`(?i)((^|_)TOKEN$|PASSW(OR)?D|_PWD$|(^|_)PASS(PHRASE)?$|SECRET|(^|_)(API|ACCESS|PRIVATE|CLIENT|SIGNING|ENCRYPTION|MASTER)_?KEY(_ID)?$|CREDENTIALS?$|(^|_)DSN$)`)
+
+// SensitiveKeyPattern returns a pattern which matches the names of variables that usually hold secrets, such as
+// *_TOKEN, *_PWD or *PASSWORD*. It is matched against the names of environment variables rather than their values.
+func SensitiveKeyPattern() Pattern {
+	return Pattern{
+		Regex:       sensitiveKeys,
+		Name:        "Sensitive Environment Variable",
+		Source:      "https://github.com/liamg/dismember",
+		Description: "An environment variable whose name suggests it holds a secret",
+		Severity:    SeverityMedium,
+	}
+}
+
+// SensitiveArgumentPattern returns a pattern which matches the names of command-line flags that usually hold
+// secrets, such as --password or --api-key. Flag names are matched without their leading dashes, and with any
+// other dashes replaced by underscores.
+func SensitiveArgumentPattern() Pattern {
+	return Pattern{
+		Regex:       sensitiveKeys,
+		Name:        "Sensitive Command-Line Argument",
+		Source:      "https://github.com/liamg/dismember",
+		Description: "A command-line flag whose name suggests it holds a secret",
+		Severity:    SeverityMedium,
+	}
+}
@@ -0,0 +1,17 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SensitiveKeyPattern(t *testing.T) {
+	regex := SensitiveKeyPattern().Regex
+	for _, key := range []string{"GITHUB_TOKEN", "DB_PASSWORD", "MYSQL_PWD", "MYSQL_PWD_PASSWD", "PGPASSWORD", "REDIS_PASS", "GPG_PASSPHRASE", "SENTRY_DSN", "OAUTH_CLIENT_KEY", "AWS_SECRET_ACCESS_KEY", "API_KEY", "STRIPE_APIKEY", "GOOGLE_APPLICATION_CREDENTIALS"} {
+		assert.True(t, regex.MatchString(key), key)
+	}
+	for _, key := range []string{"HOME", "PWD", "OLDPWD", "SSH_AUTH_SOCK", "TOKENIZERS_PARALLELISM", "KEYBOARD", "PASSENGER_ROOT", "COMPASS", "HOTKEY"} {
+		assert.False(t, regex.MatchString(key), key)
+	}
+}
@@ -0,0 +1,127 @@
+package secrets
+
+// Matcher tests many Patterns against a block of memory in a single pass. Literal prefixes are extracted from
+// each regex (or taken from the pattern's Keywords) and searched for simultaneously, so regexes which cannot
+// possibly match are never run.
+type Matcher struct {
+	patterns  []Pattern
+	always    []int   // always lists the patterns which have no usable prefilter
+	keywords  [][]int // keywords maps each keyword index to the patterns it is a prefilter for
+	automaton *automaton
+}
+
+// Match is a single match of a Pattern.
+type Match struct {
+	Pattern *Pattern
+	Start   int
+	End     int
+	Entropy float64 // Entropy is the Shannon entropy of the secret, if the pattern has an entropy threshold
+}
+
+// NewMatcher creates a Matcher for the given patterns.
+func NewMatcher(patterns []Pattern) *Matcher {
+	m := &Matcher{
+		patterns: patterns,
+	}
+	var keywords []string
+	index := make(map[string]int)
+	for i, pattern := range patterns {
+		if pattern.Finder != nil {
+			m.always = append(m.always, i)
+			continue
+		}
+		prefixes, ok := literalPrefixes(pattern.Regex.String())
+		if len(pattern.Keywords) > 0 {
+			prefixes, ok = normaliseKeywords(pattern.Keywords)
+		}
+		if !ok {
+			m.always = append(m.always, i)
+			continue
+		}
+		for _, prefix := range prefixes {
+			k, exists := index[prefix]
+			if !exists {
+				k = len(keywords)
+				index[prefix] = k
+				keywords = append(keywords, prefix)
+				m.keywords = append(m.keywords, nil)
+			}
+			m.keywords[k] = append(m.keywords[k], i)
+		}
+	}
+	if len(keywords) > 0 {
+		m.automaton = newAutomaton(keywords)
+	}
+	return m
+}
+
+// normaliseKeywords normalises user-supplied keywords for use as a prefilter.
+func normaliseKeywords(input []string) ([]string, bool) {
+	var output []string
+	for _, keyword := range input {
+		if keyword == "" {
+			return nil, false
+		}
+		output = append(output, asciiLower(keyword))
+	}
+	return output, true
+}
+
+// Patterns returns the patterns used by the Matcher.
+func (m *Matcher) Patterns() []Pattern {
+	return m.patterns
+}
+
+// FindAll returns all matches of all patterns in data, ordered by pattern.
+func (m *Matcher) FindAll(data []byte) []Match {
+
+	candidates := make([]bool, len(m.patterns))
+	for _, i := range m.always {
+		candidates[i] = true
+	}
+	if m.automaton != nil {
+		remaining := len(m.keywords)
+		seen := make([]bool, len(m.keywords))
+		m.automaton.find(data, func(keyword int) bool {
+			if seen[keyword] {
+				return true
+			}
+			seen[keyword] = true
+			for _, i := range m.keywords[keyword] {
+				candidates[i] = true
+			}
+			remaining--
+			return remaining > 0
+		})
+	}
+
+	var matches []Match
+	for i, candidate := range candidates {
+		if !candidate {
+			continue
+		}
+		pattern := &m.patterns[i]
+		if pattern.Finder != nil {
+			for _, indexes := range pattern.Finder.FindAllIndex(data, -1) {
+				if ok, entropy := pattern.accept(data, indexes); ok {
+					matches = append(matches, Match{Pattern: pattern, Start: indexes[0], End: indexes[1], Entropy: entropy})
+				}
+			}
+			continue
+		}
+		if !pattern.filtered() {
+			for _, indexes := range pattern.Regex.FindAllIndex(data, -1) {
+				matches = append(matches, Match{Pattern: pattern, Start: indexes[0], End: indexes[1]})
+			}
+			continue
+		}
+		for _, indexes := range pattern.Regex.FindAllSubmatchIndex(data, -1) {
+			ok, entropy := pattern.accept(data, indexes)
+			if !ok {
+				continue
+			}
+			matches = append(matches, Match{Pattern: pattern, Start: indexes[0], End: indexes[1], Entropy: entropy})
+		}
+	}
+	return matches
+}
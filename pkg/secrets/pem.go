@@ -0,0 +1,101 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+var pemHeader = []byte("-----BEGIN ")
+
+// validatePEM parses a PEM-encoded private key. Matches are usually just the header line, so the rest of the
+// block is read from the lookahead.
+func validatePEM(match []byte, lookahead []byte) (Validation, bool) {
+	start := bytes.Index(match, pemHeader)
+	if start < 0 {
+		return Validation{}, false
+	}
+	data := append(append([]byte{}, match[start:]...), lookahead...)
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		data = data[:end]
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return invalid("pem", "block is incomplete or malformed", nil)
+	}
+	details := map[string]string{"type": block.Type}
+	if block.Headers["Proc-Type"] == "4,ENCRYPTED" || block.Type == "ENCRYPTED PRIVATE KEY" {
+		return unverifiable("pem", "key is encrypted", details)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return invalid("pem", fmt.Sprintf("failed to parse key: %s", err), details)
+		}
+		details["bits"] = strconv.Itoa(key.N.BitLen())
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return invalid("pem", fmt.Sprintf("failed to parse key: %s", err), details)
+		}
+		details["curve"] = key.Curve.Params().Name
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return invalid("pem", fmt.Sprintf("failed to parse key: %s", err), details)
+		}
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			details["algorithm"] = "RSA"
+			details["bits"] = strconv.Itoa(key.N.BitLen())
+		case *ecdsa.PrivateKey:
+			details["algorithm"] = "ECDSA"
+			details["curve"] = key.Curve.Params().Name
+		case ed25519.PrivateKey:
+			details["algorithm"] = "Ed25519"
+		}
+	case "DSA PRIVATE KEY":
+		var key struct {
+			Version       int
+			P, Q, G, Y, X *big.Int
+		}
+		if _, err := asn1.Unmarshal(block.Bytes, &key); err != nil {
+			return invalid("pem", fmt.Sprintf("failed to parse key: %s", err), details)
+		}
+	case "OPENSSH PRIVATE KEY":
+		const magic = "openssh-key-v1\x00"
+		if !bytes.HasPrefix(block.Bytes, []byte(magic)) {
+			return invalid("pem", "key does not start with the OpenSSH magic bytes", details)
+		}
+		if cipher, ok := sshString(block.Bytes[len(magic):]); ok {
+			details["cipher"] = cipher
+			if cipher != "none" {
+				return unverifiable("pem", "key is encrypted", details)
+			}
+		}
+	default:
+		return unverifiable("pem", "no offline check is available for this block type", details)
+	}
+	return valid("pem", "key was parsed successfully", details)
+}
+
+// sshString reads a length-prefixed string from the OpenSSH wire format.
+func sshString(data []byte) (string, bool) {
+	if len(data) < 4 {
+		return "", false
+	}
+	length := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+	if length > len(data)-4 {
+		return "", false
+	}
+	return string(data[4 : 4+length]), true
+}
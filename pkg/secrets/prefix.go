@@ -0,0 +1,169 @@
+package secrets
+
+import (
+	"regexp/syntax"
+	"unicode"
+)
+
+const (
+	maxPrefixes      = 64 // maxPrefixes limits the number of literals extracted from a single regex
+	maxPrefixLength  = 16 // maxPrefixLength limits the length of each extracted literal
+	minPrefixLength  = 3  // minPrefixLength is the shortest literal worth using as a prefilter
+	maxClassExpanded = 10 // maxClassExpanded is the largest character class which will be expanded into literals
+)
+
+// literalPrefixes returns a set of lower-case literals, one of which must begin every match of the given regex
+// when compared case-insensitively. If no useful set of literals exists, ok is false and the regex must always
+// be run.
+func literalPrefixes(expr string) (prefixes []string, ok bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+	set, _, ok := prefixesOf(re.Simplify())
+	if !ok || len(set) == 0 {
+		return nil, false
+	}
+	for _, prefix := range set {
+		if len(prefix) < minPrefixLength {
+			return nil, false
+		}
+	}
+	return set, true
+}
+
+// prefixesOf returns the possible prefixes of matches of re. exact is true if the prefixes are the complete set
+// of strings matched by re, meaning further literals can be appended to them.
+func prefixesOf(re *syntax.Regexp) (set []string, exact bool, ok bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpBeginText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return []string{""}, true, true
+	case syntax.OpLiteral:
+		set = []string{""}
+		for _, r := range re.Rune {
+			variants := []string{asciiLower(string(r))}
+			if re.Flags&syntax.FoldCase != 0 {
+				variants = foldVariants(r)
+			}
+			if len(set)*len(variants) > maxPrefixes {
+				return set, false, true
+			}
+			var next []string
+			for _, prefix := range set {
+				for _, variant := range variants {
+					next = append(next, truncate(prefix+variant))
+				}
+			}
+			set = next
+		}
+		return set, true, true
+	case syntax.OpCharClass:
+		var count int
+		for i := 0; i < len(re.Rune); i += 2 {
+			count += int(re.Rune[i+1]-re.Rune[i]) + 1
+			if count > maxClassExpanded*2 {
+				return nil, false, false
+			}
+		}
+		unique := make(map[string]struct{})
+		for i := 0; i < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				unique[asciiLower(string(r))] = struct{}{}
+			}
+		}
+		if len(unique) > maxClassExpanded {
+			return nil, false, false
+		}
+		for literal := range unique {
+			set = append(set, literal)
+		}
+		return set, true, true
+	case syntax.OpCapture:
+		return prefixesOf(re.Sub[0])
+	case syntax.OpPlus:
+		set, _, ok := prefixesOf(re.Sub[0])
+		return set, false, ok
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return nil, false, false
+		}
+		set, exact, ok := prefixesOf(re.Sub[0])
+		return set, exact && re.Min == 1 && re.Max == 1, ok
+	case syntax.OpAlternate:
+		exact = true
+		for _, sub := range re.Sub {
+			subSet, subExact, subOK := prefixesOf(sub)
+			if !subOK {
+				return nil, false, false
+			}
+			exact = exact && subExact
+			set = append(set, subSet...)
+			if len(set) > maxPrefixes {
+				return nil, false, false
+			}
+		}
+		return set, exact, true
+	case syntax.OpConcat:
+		set = []string{""}
+		exact = true
+		for _, sub := range re.Sub {
+			subSet, subExact, subOK := prefixesOf(sub)
+			if !subOK {
+				exact = false
+				break
+			}
+			if len(set)*len(subSet) > maxPrefixes {
+				exact = false
+				break
+			}
+			var next []string
+			for _, prefix := range set {
+				for _, suffix := range subSet {
+					next = append(next, truncate(prefix+suffix))
+				}
+			}
+			set = next
+			if !subExact {
+				exact = false
+				break
+			}
+		}
+		return set, exact, true
+	default:
+		return nil, false, false
+	}
+}
+
+func truncate(s string) string {
+	if len(s) > maxPrefixLength {
+		return s[:maxPrefixLength]
+	}
+	return s
+}
+
+// foldVariants returns the literals which match r case-insensitively, once lower-cased by asciiLower. As well as the
+// ASCII upper and lower case, this includes any other characters in the same Unicode case-folding orbit, e.g. the
+// Kelvin sign (U+212A) for k, and the long s (U+017F) for s.
+func foldVariants(r rune) []string {
+	variants := []string{asciiLower(string(r))}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		variant := asciiLower(string(f))
+		var seen bool
+		for _, existing := range variants {
+			seen = seen || existing == variant
+		}
+		if !seen {
+			variants = append(variants, variant)
+		}
+	}
+	return variants
+}
+
+// asciiLower lower-cases ASCII letters only, matching the case-folding applied to memory by the automaton.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		b[i] = lowerTable[c]
+	}
+	return string(b)
+}
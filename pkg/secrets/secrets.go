@@ -8,19 +8,107 @@ import (
 	squealer "github.com/owenrumney/squealer/pkg/config"
 )
 
+// Finder finds the matches of a pattern which can't be expressed as a regex, such as a sequence of raw bytes.
+type Finder interface {
+	FindAllIndex(data []byte, n int) [][]int
+	String() string
+}
+
 type Pattern struct {
-	Regex  *regexp.Regexp
-	Name   string
-	Source string
+	Regex       *regexp.Regexp
+	Finder      Finder // Finder optionally replaces Regex, e.g. to match raw bytes which Go regexes can't express
+	Name        string
+	Source      string
+	Description string
+	Severity    Severity
+	Keywords    []string // Keywords optionally overrides the literals used to decide whether the regex should be run
+	SecretGroup int      // SecretGroup is the capture group containing the secret. If 0, the first non-empty group is used.
+	MinEntropy  float64  // MinEntropy optionally discards secrets with a lower Shannon entropy
+	// EntropyScale optionally scales MinEntropy by a factor which depends on the length of the secret, as short
+	// strings cannot reach the entropy of long ones.
+	EntropyScale func(length int) float64
+	Allowlist    []*regexp.Regexp  // Allowlist discards secrets matching any of these regexes
+	Stopwords    []string          // Stopwords discards secrets containing any of these words
+	Check        func([]byte) bool // Check optionally applies further validation to the secret
+	Validator    string            // Validator optionally names the offline check for matches: github, aws, jwt or pem
 }
 
 func (p *Pattern) String() string {
 	if p.Name == "" {
-		return p.Regex.String()
+		return p.Expression()
 	}
 	return fmt.Sprintf("%s (pattern from %s)", p.Name, p.Source)
 }
 
+// Expression returns the source of the regex, or the Finder if there is one.
+func (p *Pattern) Expression() string {
+	if p.Finder != nil {
+		return p.Finder.String()
+	}
+	if p.Regex == nil {
+		return ""
+	}
+	return p.Regex.String()
+}
+
+// filtered returns true if the pattern has any filters which must be applied to the secret within a match.
+func (p *Pattern) filtered() bool {
+	return p.MinEntropy > 0 || len(p.Allowlist) > 0 || len(p.Stopwords) > 0 || p.Check != nil
+}
+
+// accept applies the entropy, allowlist, stopword and custom filters to a match, given the submatch indexes for
+// it. If the pattern has an entropy threshold, the measured entropy of the secret is also returned.
+func (p *Pattern) accept(data []byte, indexes []int) (bool, float64) {
+	secret := p.secret(data, indexes)
+	var entropy float64
+	if p.MinEntropy > 0 {
+		entropy = Entropy(secret)
+		if entropy < p.minEntropy(len(secret)) {
+			return false, entropy
+		}
+	}
+	for _, allow := range p.Allowlist {
+		if allow.Match(secret) {
+			return false, entropy
+		}
+	}
+	if len(p.Stopwords) > 0 {
+		lower := strings.ToLower(string(secret))
+		for _, stopword := range p.Stopwords {
+			if strings.Contains(lower, strings.ToLower(stopword)) {
+				return false, entropy
+			}
+		}
+	}
+	if p.Check != nil && !p.Check(secret) {
+		return false, entropy
+	}
+	return true, entropy
+}
+
+// minEntropy returns the entropy required of a secret of the given length.
+func (p *Pattern) minEntropy(length int) float64 {
+	if p.EntropyScale == nil {
+		return p.MinEntropy
+	}
+	return p.MinEntropy * p.EntropyScale(length)
+}
+
+// secret extracts the secret from a match, given the submatch indexes for it.
+func (p *Pattern) secret(data []byte, indexes []int) []byte {
+	if p.SecretGroup > 0 && len(indexes) > p.SecretGroup*2+1 && indexes[p.SecretGroup*2] >= 0 {
+		return data[indexes[p.SecretGroup*2]:indexes[p.SecretGroup*2+1]]
+	}
+	if p.SecretGroup == 0 {
+		for group := 1; group*2+1 < len(indexes); group++ {
+			if start, end := indexes[group*2], indexes[group*2+1]; start >= 0 && end > start {
+				return data[start:end]
+			}
+		}
+	}
+	return data[indexes[0]:indexes[1]]
+}
+
 func Patterns() []Pattern {
 	var all []Pattern
 	for _, rule := range squealer.DefaultConfig().Rules {
@@ -31,11 +119,26 @@ func Patterns() []Pattern {
 		if err != nil {
 			continue
 		}
+		name := strings.ReplaceAll(rule.Description, "Check for ", "")
 		all = append(all, Pattern{
-			Regex:  r,
-			Name:   strings.ReplaceAll(rule.Description, "Check for ", ""),
-			Source: "https://github.com/owenrumney/squealer",
+			Regex:     r,
+			Name:      name,
+			Source:    "https://github.com/owenrumney/squealer",
+			Validator: builtinValidators[name],
 		})
 	}
 	return all
 }
+
+// builtinValidators maps the names of built-in patterns to the offline check for their matches.
+var builtinValidators = map[string]string{
+	"AWS Access Key Id":     ValidatorAWS,
+	"AWS Manager ID":        ValidatorAWS,
+	"new Github Token":      ValidatorGitHub,
+	"Private Asymetric Key": ValidatorPEM,
+	"EC":                    ValidatorPEM,
+	"DSA":                   ValidatorPEM,
+	"OPENSSH":               ValidatorPEM,
+	"RSA":                   ValidatorPEM,
+	"PGP":                   ValidatorPEM,
+}
@@ -0,0 +1,27 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity describes the impact of a secret being exposed.
+type Severity string
+
+const (
+	SeverityNone     Severity = ""
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// ParseSeverity parses a case-insensitive severity name. An empty string is SeverityNone.
+func ParseSeverity(s string) (Severity, error) {
+	switch severity := Severity(strings.ToLower(strings.TrimSpace(s))); severity {
+	case SeverityNone, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+		return severity, nil
+	default:
+		return SeverityNone, fmt.Errorf("invalid severity '%s': must be one of low, medium, high or critical", s)
+	}
+}
@@ -0,0 +1,80 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// see https://github.com/trufflesecurity/trufflehog#regex-detector-alpha
+
+type trufflehogConfig struct {
+	Detectors []trufflehogDetector `yaml:"detectors"`
+}
+
+type trufflehogDetector struct {
+	Name     string            `yaml:"name"`
+	Keywords []string          `yaml:"keywords"`
+	Regex    map[string]string `yaml:"regex"`
+	Entropy  float64           `yaml:"entropy"`
+}
+
+// LoadTrufflehogDetectors reads custom regex detectors from a trufflehog YAML configuration file. Each named
+// regex of a detector becomes a separate Pattern, as memory is not split into the chunks trufflehog requires
+// all regexes of a detector to match within. Verification webhooks are ignored.
+func LoadTrufflehogDetectors(path string) ([]Pattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTrufflehogDetectors(data, path)
+}
+
+func parseTrufflehogDetectors(data []byte, source string) ([]Pattern, error) {
+
+	var config trufflehogConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	var patterns []Pattern
+	var problems []string
+	for i, detector := range config.Detectors {
+		name := detector.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if len(detector.Regex) == 0 {
+			problems = append(problems, fmt.Sprintf("detector '%s': at least one regex is required", name))
+			continue
+		}
+		var keys []string
+		for key := range detector.Regex {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			regex, err := regexp.Compile(detector.Regex[key])
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("detector '%s': invalid regex '%s': %s", name, key, err))
+				continue
+			}
+			patterns = append(patterns, Pattern{
+				Regex:      regex,
+				Name:       fmt.Sprintf("%s (%s)", name, key),
+				Source:     source,
+				Keywords:   detector.Keywords,
+				MinEntropy: detector.Entropy,
+			})
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s: %d invalid detector(s):\n  %s", source, len(problems), strings.Join(problems, "\n  "))
+	}
+	return patterns, nil
+}
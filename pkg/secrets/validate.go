@@ -0,0 +1,193 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+)
+
+// Validity describes the outcome of checking a secret offline.
+type Validity string
+
+const (
+	ValidityUnverifiable Validity = "unverifiable"
+	ValidityValid        Validity = "valid"
+	ValidityInvalid      Validity = "invalid"
+)
+
+// Validation is the result of checking a secret offline. Valid means the secret is well-formed and internally
+// consistent, e.g. its checksum matches, not that it is accepted by the service it belongs to.
+type Validation struct {
+	Validity Validity
+	Kind     string            // Kind is the type of secret which was recognised, e.g. "jwt"
+	Reason   string            // Reason explains why the secret is valid or invalid
+	Details  map[string]string // Details holds any information decoded from the secret, such as JWT claims
+}
+
+// ValidationLookahead is the number of bytes following a match which should be passed to Validate. Secrets
+// such as PEM blocks are usually matched by their header alone, and the rest must be read from the bytes after it.
+const ValidationLookahead = 4096
+
+// now is replaced in tests.
+var now = time.Now
+
+// Names of the offline checks which can be applied to the matches of a Pattern.
+const (
+	ValidatorGitHub = "github"
+	ValidatorAWS    = "aws"
+	ValidatorJWT    = "jwt"
+	ValidatorPEM    = "pem"
+)
+
+type validator func(match []byte, lookahead []byte) (Validation, bool)
+
+var validators = map[string]validator{
+	ValidatorGitHub: validateGitHubToken,
+	ValidatorAWS:    validateAWSAccessKeyID,
+	ValidatorJWT:    validateJWT,
+	ValidatorPEM:    validatePEM,
+}
+
+// IsValidator returns true if the name is one of the offline checks: github, aws, jwt or pem.
+func IsValidator(name string) bool {
+	_, ok := validators[name]
+	return ok
+}
+
+// Validate checks a match of the pattern without any network access, using the check named by the Validator of
+// the pattern. The Validity is empty if the pattern has no Validator, and ValidityUnverifiable if the check does
+// not recognise the secret within the match.
+func (p *Pattern) Validate(match []byte, lookahead []byte) Validation {
+	validate, ok := validators[p.Validator]
+	if !ok {
+		return Validation{}
+	}
+	if validation, ok := validate(match, lookahead); ok {
+		return validation
+	}
+	return Validation{
+		Validity: ValidityUnverifiable,
+		Kind:     p.Validator,
+		Reason:   "the secret was not recognised",
+	}
+}
+
+func valid(kind string, reason string, details map[string]string) (Validation, bool) {
+	return Validation{Validity: ValidityValid, Kind: kind, Reason: reason, Details: details}, true
+}
+
+func invalid(kind string, reason string, details map[string]string) (Validation, bool) {
+	return Validation{Validity: ValidityInvalid, Kind: kind, Reason: reason, Details: details}, true
+}
+
+func unverifiable(kind string, reason string, details map[string]string) (Validation, bool) {
+	return Validation{Validity: ValidityUnverifiable, Kind: kind, Reason: reason, Details: details}, true
+}
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// validateGitHubToken checks the checksum of a GitHub personal access or OAuth token. These are made up of a
+// prefix, 30 random characters, and 6 characters of base62-encoded CRC32 checksum of the random characters.
+// See https://github.blog/2021-04-05-behind-githubs-new-authentication-token-formats/
+func validateGitHubToken(match []byte, _ []byte) (Validation, bool) {
+	var start int
+	var prefix string
+	for _, candidate := range []string{"ghp_", "gho_"} {
+		if index := bytes.Index(match, []byte(candidate)); index >= 0 {
+			start, prefix = index, candidate
+			break
+		}
+	}
+	if prefix == "" {
+		return Validation{}, false
+	}
+	token := match[start:]
+	end := len(prefix)
+	for end < len(token) && strings.IndexByte(base62Alphabet, token[end]) >= 0 {
+		end++
+	}
+	token = token[:end]
+	details := map[string]string{"type": strings.TrimSuffix(prefix, "_")}
+	if len(token) != len(prefix)+36 {
+		return invalid("github", fmt.Sprintf("token has %d characters, expected %d", len(token), len(prefix)+36), details)
+	}
+	random, checksum := token[len(prefix):len(prefix)+30], token[len(prefix)+30:]
+	if expected := base62(crc32.ChecksumIEEE(random), 6); expected != string(checksum) {
+		return invalid("github", "token checksum does not match", details)
+	}
+	return valid("github", "token checksum matches", details)
+}
+
+// base62 encodes a number using base62Alphabet, left-padded with zeros to the given width.
+func base62(n uint32, width int) string {
+	var output []byte
+	for n > 0 {
+		output = append([]byte{base62Alphabet[n%62]}, output...)
+		n /= 62
+	}
+	for len(output) < width {
+		output = append([]byte{'0'}, output...)
+	}
+	return string(output)
+}
+
+// awsKeyTypes maps the 4 character prefix of an AWS key ID to the type of resource it identifies.
+// See https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_identifiers.html#identifiers-unique-ids
+var awsKeyTypes = map[string]string{
+	"ABIA": "STS service bearer token",
+	"ACCA": "context-specific credential",
+	"AGPA": "group",
+	"AIDA": "IAM user",
+	"AIPA": "EC2 instance profile",
+	"AKIA": "access key",
+	"ANPA": "managed policy",
+	"ANVA": "version in a managed policy",
+	"APKA": "public key",
+	"AROA": "role",
+	"ASCA": "certificate",
+	"ASIA": "temporary access key",
+}
+
+var awsBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// validateAWSAccessKeyID checks the structure of an AWS key ID: a known 4 character prefix followed by 16
+// characters of base32. The AWS account ID is encoded in the key ID, and is decoded for access keys.
+func validateAWSAccessKeyID(match []byte, _ []byte) (Validation, bool) {
+	var start = -1
+	for index := 0; index+4 <= len(match); index++ {
+		if _, ok := awsKeyTypes[string(match[index:index+4])]; ok {
+			start = index
+			break
+		}
+	}
+	if start < 0 {
+		return Validation{}, false
+	}
+	id := match[start:]
+	end := 0
+	for end < len(id) && (id[end] >= 'A' && id[end] <= 'Z' || id[end] >= '0' && id[end] <= '9') {
+		end++
+	}
+	id = id[:end]
+	prefix := string(id[:4])
+	details := map[string]string{"type": awsKeyTypes[prefix]}
+	if len(id) != 20 {
+		return invalid("aws", fmt.Sprintf("key ID has %d characters, expected 20", len(id)), details)
+	}
+	if strings.HasSuffix(string(id), "EXAMPLE") {
+		return invalid("aws", "key ID is an example from the AWS documentation", details)
+	}
+	decoded, err := awsBase32.DecodeString(string(id[4:]))
+	if err != nil {
+		return invalid("aws", "key ID contains characters which are not base32", details)
+	}
+	if prefix == "AKIA" || prefix == "ASIA" {
+		account := binary.BigEndian.Uint64(append([]byte{0, 0}, decoded[:6]...))
+		details["account"] = fmt.Sprintf("%012d", (account&0x7fffffffff80)>>7)
+	}
+	return valid("aws", "key ID is well-formed", details)
+}
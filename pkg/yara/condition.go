@@ -0,0 +1,228 @@
+package yara
+
+// value is the result of evaluating an expression. Booleans are represented as 0 or 1. A value is undefined when
+// it refers to a match which does not exist, such as @a[2] when $a matched once, and an undefined value is false.
+type value struct {
+	n       int64
+	defined bool
+}
+
+func integer(n int64) value {
+	return value{n: n, defined: true}
+}
+
+func boolean(b bool) value {
+	if b {
+		return integer(1)
+	}
+	return integer(0)
+}
+
+func (v value) isTrue() bool {
+	return v.defined && v.n != 0
+}
+
+var undefined = value{}
+
+// scope holds the matches of each string in a rule, and the results of the rules evaluated so far.
+type scope struct {
+	matches map[*String][]StringMatch
+	rules   map[string]bool
+}
+
+type expression interface {
+	eval(s *scope) value
+}
+
+type literalExpression struct {
+	value value
+}
+
+func (e literalExpression) eval(*scope) value {
+	return e.value
+}
+
+type notExpression struct {
+	operand expression
+}
+
+func (e notExpression) eval(s *scope) value {
+	return boolean(!e.operand.eval(s).isTrue())
+}
+
+type logicalExpression struct {
+	and         bool
+	left, right expression
+}
+
+func (e logicalExpression) eval(s *scope) value {
+	if e.and {
+		return boolean(e.left.eval(s).isTrue() && e.right.eval(s).isTrue())
+	}
+	return boolean(e.left.eval(s).isTrue() || e.right.eval(s).isTrue())
+}
+
+type binaryExpression struct {
+	operator    string
+	left, right expression
+}
+
+func (e binaryExpression) eval(s *scope) value {
+	left, right := e.left.eval(s), e.right.eval(s)
+	if !left.defined || !right.defined {
+		if e.operator == "==" || e.operator == "!=" || e.operator == "<" || e.operator == "<=" || e.operator == ">" || e.operator == ">=" {
+			return boolean(false)
+		}
+		return undefined
+	}
+	switch e.operator {
+	case "==":
+		return boolean(left.n == right.n)
+	case "!=":
+		return boolean(left.n != right.n)
+	case "<":
+		return boolean(left.n < right.n)
+	case "<=":
+		return boolean(left.n <= right.n)
+	case ">":
+		return boolean(left.n > right.n)
+	case ">=":
+		return boolean(left.n >= right.n)
+	case "+":
+		return integer(left.n + right.n)
+	case "-":
+		return integer(left.n - right.n)
+	case "*":
+		return integer(left.n * right.n)
+	case "\\":
+		if right.n == 0 {
+			return undefined
+		}
+		return integer(left.n / right.n)
+	case "%":
+		if right.n == 0 {
+			return undefined
+		}
+		return integer(left.n % right.n)
+	}
+	return undefined
+}
+
+// stringExpression is true if a string matched, optionally at an address or within a range of addresses.
+type stringExpression struct {
+	str      *String
+	at       expression
+	from, to expression
+}
+
+func (e stringExpression) eval(s *scope) value {
+	matches := s.matches[e.str]
+	switch {
+	case e.at != nil:
+		at := e.at.eval(s)
+		if !at.defined {
+			return boolean(false)
+		}
+		for _, match := range matches {
+			if match.Address == uint64(at.n) {
+				return boolean(true)
+			}
+		}
+		return boolean(false)
+	case e.from != nil:
+		from, to := e.from.eval(s), e.to.eval(s)
+		if !from.defined || !to.defined {
+			return boolean(false)
+		}
+		for _, match := range matches {
+			if match.Address >= uint64(from.n) && match.Address <= uint64(to.n) {
+				return boolean(true)
+			}
+		}
+		return boolean(false)
+	}
+	return boolean(len(matches) > 0)
+}
+
+type countExpression struct {
+	str *String
+}
+
+func (e countExpression) eval(s *scope) value {
+	return integer(int64(len(s.matches[e.str])))
+}
+
+// offsetExpression is the address (@a) or length (!a) of the nth match of a string, counting from 1.
+type offsetExpression struct {
+	str    *String
+	length bool
+	index  expression
+}
+
+func (e offsetExpression) eval(s *scope) value {
+	index := integer(1)
+	if e.index != nil {
+		index = e.index.eval(s)
+	}
+	matches := s.matches[e.str]
+	if !index.defined || index.n < 1 || index.n > int64(len(matches)) {
+		return undefined
+	}
+	match := matches[index.n-1]
+	if e.length {
+		return integer(int64(match.Length))
+	}
+	return integer(int64(match.Address))
+}
+
+type quantifier int
+
+const (
+	quantifierAll quantifier = iota
+	quantifierAny
+	quantifierNone
+	quantifierCount
+	quantifierPercent
+)
+
+// ofExpression is true if enough of a set of strings matched, e.g. "2 of ($a, $b*)" or "all of them".
+type ofExpression struct {
+	quantifier quantifier
+	count      expression
+	strings    []*String
+}
+
+func (e ofExpression) eval(s *scope) value {
+	var matched int64
+	for _, str := range e.strings {
+		if len(s.matches[str]) > 0 {
+			matched++
+		}
+	}
+	total := int64(len(e.strings))
+	switch e.quantifier {
+	case quantifierAll:
+		return boolean(matched == total)
+	case quantifierAny:
+		return boolean(matched > 0)
+	case quantifierNone:
+		return boolean(matched == 0)
+	}
+	count := e.count.eval(s)
+	if !count.defined {
+		return boolean(false)
+	}
+	if e.quantifier == quantifierPercent {
+		return boolean(matched*100 >= count.n*total)
+	}
+	return boolean(matched >= count.n)
+}
+
+// ruleExpression refers to the result of another rule.
+type ruleExpression struct {
+	name string
+}
+
+func (e ruleExpression) eval(s *scope) value {
+	return boolean(s.rules[e.name])
+}
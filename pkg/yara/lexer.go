@@ -0,0 +1,270 @@
+package yara
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenText
+	tokenHex
+	tokenRegex
+	tokenNumber
+	tokenString // $a, or $a* in a set
+	tokenCount  // #a
+	tokenOffset // @a
+	tokenLength // !a
+	tokenPunctuation
+)
+
+type token struct {
+	kind  tokenKind
+	text  string // text is the identifier, the punctuation, or the decoded value of a literal
+	flags string // flags are the modifiers following a regex, e.g. "is"
+	value int64
+	line  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenText:
+		return strconv.Quote(t.text)
+	case tokenHex:
+		return "{" + t.text + "}"
+	case tokenRegex:
+		return "/" + t.text + "/" + t.flags
+	case tokenNumber:
+		return strconv.FormatInt(t.value, 10)
+	case tokenString:
+		return "$" + t.text
+	case tokenCount:
+		return "#" + t.text
+	case tokenOffset:
+		return "@" + t.text
+	case tokenLength:
+		return "!" + t.text
+	default:
+		return t.text
+	}
+}
+
+// punctuation is ordered so that longer operators are matched first.
+var punctuation = []string{"..", ".", "==", "!=", "<=", ">=", "{", "}", "(", ")", "[", "]", ":", "=", ",", "<", ">", "+", "-", "*", "\\", "%"}
+
+type lexer struct {
+	input  string
+	pos    int
+	line   int
+	tokens []token
+}
+
+// lex splits the source of a set of rules into tokens. Hex strings and regexes are recognised when they follow
+// an '=', as they do in the strings section of a rule.
+func lex(input string) ([]token, error) {
+	l := &lexer{input: input, line: 1}
+	for {
+		if err := l.skip(); err != nil {
+			return nil, err
+		}
+		if l.pos >= len(l.input) {
+			l.tokens = append(l.tokens, token{kind: tokenEOF, line: l.line})
+			return l.tokens, nil
+		}
+		t, err := l.next()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", l.line, err)
+		}
+		l.tokens = append(l.tokens, t)
+	}
+}
+
+// skip skips whitespace and comments.
+func (l *lexer) skip() error {
+	for l.pos < len(l.input) {
+		switch {
+		case l.input[l.pos] == '\n':
+			l.line++
+			l.pos++
+		case l.input[l.pos] == ' ' || l.input[l.pos] == '\t' || l.input[l.pos] == '\r':
+			l.pos++
+		case strings.HasPrefix(l.input[l.pos:], "//"):
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.input[l.pos:], "/*"):
+			end := strings.Index(l.input[l.pos+2:], "*/")
+			if end < 0 {
+				return fmt.Errorf("line %d: unterminated comment", l.line)
+			}
+			l.line += strings.Count(l.input[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) afterEquals() bool {
+	return len(l.tokens) > 0 && l.tokens[len(l.tokens)-1].kind == tokenPunctuation && l.tokens[len(l.tokens)-1].text == "="
+}
+
+func (l *lexer) next() (token, error) {
+	c := l.input[l.pos]
+	switch {
+	case c == '"':
+		return l.text()
+	case c == '{' && l.afterEquals():
+		return l.hex()
+	case c == '/' && l.afterEquals():
+		return l.regex()
+	case c >= '0' && c <= '9':
+		return l.number()
+	case isIdentifierStart(c):
+		return token{kind: tokenIdentifier, text: l.identifier(), line: l.line}, nil
+	case c == '$' || c == '#' || c == '@' || (c == '!' && l.pos+1 < len(l.input) && isIdentifierStart(l.input[l.pos+1])):
+		l.pos++
+		name := l.identifier()
+		kind := map[byte]tokenKind{'$': tokenString, '#': tokenCount, '@': tokenOffset, '!': tokenLength}[c]
+		if kind == tokenString && l.pos < len(l.input) && l.input[l.pos] == '*' {
+			name += "*"
+			l.pos++
+		}
+		return token{kind: kind, text: name, line: l.line}, nil
+	}
+	for _, p := range punctuation {
+		if strings.HasPrefix(l.input[l.pos:], p) {
+			l.pos += len(p)
+			return token{kind: tokenPunctuation, text: p, line: l.line}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected character '%c'", c)
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}
+
+func (l *lexer) identifier() string {
+	start := l.pos
+	for l.pos < len(l.input) && isIdentifierChar(l.input[l.pos]) {
+		l.pos++
+	}
+	return l.input[start:l.pos]
+}
+
+// number reads a decimal or hex integer, optionally followed by a KB or MB multiplier.
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	for l.pos < len(l.input) && isIdentifierChar(l.input[l.pos]) {
+		l.pos++
+	}
+	literal := l.input[start:l.pos]
+	multiplier := int64(1)
+	if strings.HasSuffix(literal, "KB") {
+		multiplier, literal = 1024, strings.TrimSuffix(literal, "KB")
+	} else if strings.HasSuffix(literal, "MB") {
+		multiplier, literal = 1024*1024, strings.TrimSuffix(literal, "MB")
+	}
+	value, err := strconv.ParseInt(literal, 0, 64)
+	if err != nil {
+		return token{}, fmt.Errorf("invalid number '%s'", l.input[start:l.pos])
+	}
+	return token{kind: tokenNumber, value: value * multiplier, text: l.input[start:l.pos], line: l.line}, nil
+}
+
+// text reads a double-quoted string, decoding the escapes \" \\ \t \n \r and \xNN.
+func (l *lexer) text() (token, error) {
+	var value []byte
+	for l.pos++; l.pos < len(l.input); l.pos++ {
+		c := l.input[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return token{kind: tokenText, text: string(value), line: l.line}, nil
+		case '\n':
+			return token{}, fmt.Errorf("unterminated string")
+		case '\\':
+			if l.pos+1 >= len(l.input) {
+				return token{}, fmt.Errorf("unterminated string")
+			}
+			l.pos++
+			switch l.input[l.pos] {
+			case '"', '\\':
+				value = append(value, l.input[l.pos])
+			case 't':
+				value = append(value, '\t')
+			case 'n':
+				value = append(value, '\n')
+			case 'r':
+				value = append(value, '\r')
+			case 'x':
+				if l.pos+2 >= len(l.input) {
+					return token{}, fmt.Errorf("invalid escape sequence in string")
+				}
+				b, err := strconv.ParseUint(l.input[l.pos+1:l.pos+3], 16, 8)
+				if err != nil {
+					return token{}, fmt.Errorf("invalid escape sequence '\\x%s' in string", l.input[l.pos+1:l.pos+3])
+				}
+				value = append(value, byte(b))
+				l.pos += 2
+			default:
+				return token{}, fmt.Errorf("invalid escape sequence '\\%c' in string", l.input[l.pos])
+			}
+		default:
+			value = append(value, c)
+		}
+	}
+	return token{}, fmt.Errorf("unterminated string")
+}
+
+// hex reads a hex string, up to the closing brace.
+func (l *lexer) hex() (token, error) {
+	end := strings.IndexByte(l.input[l.pos:], '}')
+	if end < 0 {
+		return token{}, fmt.Errorf("unterminated hex string")
+	}
+	value := l.input[l.pos+1 : l.pos+end]
+	l.line += strings.Count(value, "\n")
+	l.pos += end + 1
+	return token{kind: tokenHex, text: value, line: l.line}, nil
+}
+
+// regex reads a regular expression and its flags. An escaped slash is unescaped.
+func (l *lexer) regex() (token, error) {
+	var value strings.Builder
+	for l.pos++; l.pos < len(l.input); l.pos++ {
+		c := l.input[l.pos]
+		switch {
+		case c == '\n':
+			return token{}, fmt.Errorf("unterminated regular expression")
+		case c == '\\' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '/':
+			value.WriteByte('/')
+			l.pos++
+		case c == '\\' && l.pos+1 < len(l.input):
+			value.WriteString(l.input[l.pos : l.pos+2])
+			l.pos++
+		case c == '/':
+			l.pos++
+			start := l.pos
+			for l.pos < len(l.input) && (l.input[l.pos] == 'i' || l.input[l.pos] == 's') {
+				l.pos++
+			}
+			return token{kind: tokenRegex, text: value.String(), flags: l.input[start:l.pos], line: l.line}, nil
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return token{}, fmt.Errorf("unterminated regular expression")
+}
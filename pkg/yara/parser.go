@@ -0,0 +1,493 @@
+package yara
+
+import (
+	"fmt"
+	"strings"
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+	rules  map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is returns true if the next token is the given identifier or punctuation.
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokenIdentifier || t.kind == tokenPunctuation) && t.text == text
+}
+
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf(p.peek(), "expected '%s' but found %s", text, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) identifier() (string, error) {
+	t := p.next()
+	if t.kind != tokenIdentifier {
+		return "", p.errorf(t, "expected an identifier but found %s", t)
+	}
+	return t.text, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseRules() ([]*Rule, error) {
+	var rules []*Rule
+	for p.peek().kind != tokenEOF {
+		if p.is("import") || p.is("include") {
+			return nil, p.errorf(p.peek(), "'%s' is not supported", p.peek().text)
+		}
+		rule, err := p.parseRule()
+		if err != nil {
+			return nil, err
+		}
+		if p.rules[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule '%s'", rule.Name)
+		}
+		p.rules[rule.Name] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (p *parser) parseRule() (*Rule, error) {
+	rule := &Rule{Meta: make(map[string]string)}
+	for {
+		if p.accept("private") {
+			rule.Private = true
+		} else if p.accept("global") {
+			rule.Global = true
+		} else {
+			break
+		}
+	}
+	if err := p.expect("rule"); err != nil {
+		return nil, err
+	}
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	rule.Name = name
+	if p.accept(":") {
+		for p.peek().kind == tokenIdentifier {
+			rule.Tags = append(rule.Tags, p.next().text)
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	if p.is("meta") && p.peekAt(1).text == ":" {
+		p.pos += 2
+		if err := p.parseMeta(rule); err != nil {
+			return nil, err
+		}
+	}
+	if p.is("strings") && p.peekAt(1).text == ":" {
+		p.pos += 2
+		if err := p.parseStrings(rule); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("condition"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	condition, err := p.parseOr(rule)
+	if err != nil {
+		return nil, err
+	}
+	rule.condition = condition
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// atSection returns true if the next tokens begin a section of a rule, e.g. "strings:".
+func (p *parser) atSection() bool {
+	return (p.is("strings") || p.is("condition")) && p.peekAt(1).text == ":"
+}
+
+func (p *parser) parseMeta(rule *Rule) error {
+	for !p.atSection() {
+		key, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		negative := p.accept("-")
+		t := p.next()
+		switch {
+		case t.kind == tokenText && !negative:
+			rule.Meta[key] = t.text
+		case t.kind == tokenNumber && negative:
+			rule.Meta[key] = "-" + t.String()
+		case t.kind == tokenNumber:
+			rule.Meta[key] = t.String()
+		case t.kind == tokenIdentifier && (t.text == "true" || t.text == "false") && !negative:
+			rule.Meta[key] = t.text
+		default:
+			return p.errorf(t, "invalid value %s for meta '%s'", t, key)
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseStrings(rule *Rule) error {
+	seen := make(map[string]bool)
+	for p.peek().kind == tokenString {
+		t := p.next()
+		if strings.HasSuffix(t.text, "*") {
+			return p.errorf(t, "expected a string identifier but found %s", t)
+		}
+		identifier := "$" + t.text
+		if t.text != "" && seen[identifier] {
+			return p.errorf(t, "duplicate string identifier %s", identifier)
+		}
+		seen[identifier] = true
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		value := p.next()
+		var m modifiers
+		for p.peek().kind == tokenIdentifier && !p.is("condition") {
+			modifier := p.next()
+			switch modifier.text {
+			case "nocase":
+				m.nocase = true
+			case "ascii":
+				m.ascii = true
+			case "wide":
+				m.wide = true
+			case "fullword":
+				m.fullword = true
+			case "private":
+				m.private = true
+			case "xor", "base64", "base64wide":
+				return p.errorf(modifier, "the %s modifier is not supported", modifier.text)
+			default:
+				return p.errorf(modifier, "unknown string modifier '%s'", modifier.text)
+			}
+		}
+		var s *String
+		var err error
+		switch value.kind {
+		case tokenText:
+			if value.text == "" {
+				return p.errorf(value, "string %s is empty", identifier)
+			}
+			s = newTextString(identifier, value.text, m)
+		case tokenHex:
+			s, err = newHexString(identifier, value.text, m)
+		case tokenRegex:
+			s, err = newRegexString(identifier, value.text, value.flags, m)
+		default:
+			return p.errorf(value, "expected a text, hex or regex string but found %s", value)
+		}
+		if err != nil {
+			return p.errorf(value, "%s", err)
+		}
+		rule.Strings = append(rule.Strings, s)
+	}
+	return nil
+}
+
+func (p *parser) parseOr(rule *Rule) (expression, error) {
+	left, err := p.parseAnd(rule)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd(rule)
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpression{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd(rule *Rule) (expression, error) {
+	left, err := p.parseNot(rule)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseNot(rule)
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpression{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot(rule *Rule) (expression, error) {
+	if p.accept("not") {
+		operand, err := p.parseNot(rule)
+		if err != nil {
+			return nil, err
+		}
+		return notExpression{operand: operand}, nil
+	}
+	return p.parseComparison(rule)
+}
+
+func (p *parser) parseComparison(rule *Rule) (expression, error) {
+	left, err := p.parseAdditive(rule)
+	if err != nil {
+		return nil, err
+	}
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(operator) {
+			right, err := p.parseAdditive(rule)
+			if err != nil {
+				return nil, err
+			}
+			return binaryExpression{operator: operator, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive(rule *Rule) (expression, error) {
+	left, err := p.parseMultiplicative(rule)
+	if err != nil {
+		return nil, err
+	}
+	for p.is("+") || p.is("-") {
+		operator := p.next().text
+		right, err := p.parseMultiplicative(rule)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpression{operator: operator, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative(rule *Rule) (expression, error) {
+	left, err := p.parsePrimary(rule)
+	if err != nil {
+		return nil, err
+	}
+	// a percentage, e.g. "50% of them", is parsed as a quantifier rather than a remainder
+	for p.is("*") || p.is("\\") || (p.is("%") && p.peekAt(1).text != "of") {
+		operator := p.next().text
+		right, err := p.parsePrimary(rule)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpression{operator: operator, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parsePrimary(rule *Rule) (expression, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber:
+		p.next()
+		number := literalExpression{value: integer(t.value)}
+		if p.is("%") && p.peekAt(1).text == "of" {
+			p.pos += 2
+			return p.parseSet(rule, quantifierPercent, number)
+		}
+		if p.accept("of") {
+			return p.parseSet(rule, quantifierCount, number)
+		}
+		return number, nil
+	case tokenString:
+		p.next()
+		s, err := p.lookup(rule, t)
+		if err != nil {
+			return nil, err
+		}
+		e := stringExpression{str: s}
+		if p.accept("at") {
+			if e.at, err = p.parseAdditive(rule); err != nil {
+				return nil, err
+			}
+		} else if p.accept("in") {
+			if e.from, e.to, err = p.parseRange(rule); err != nil {
+				return nil, err
+			}
+		}
+		return e, nil
+	case tokenCount:
+		p.next()
+		s, err := p.lookup(rule, t)
+		if err != nil {
+			return nil, err
+		}
+		return countExpression{str: s}, nil
+	case tokenOffset, tokenLength:
+		p.next()
+		s, err := p.lookup(rule, t)
+		if err != nil {
+			return nil, err
+		}
+		e := offsetExpression{str: s, length: t.kind == tokenLength}
+		if p.accept("[") {
+			if e.index, err = p.parseAdditive(rule); err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+		return e, nil
+	case tokenPunctuation:
+		if p.accept("(") {
+			e, err := p.parseOr(rule)
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+		if p.accept("-") {
+			operand, err := p.parsePrimary(rule)
+			if err != nil {
+				return nil, err
+			}
+			return binaryExpression{operator: "-", left: literalExpression{value: integer(0)}, right: operand}, nil
+		}
+	case tokenIdentifier:
+		p.next()
+		switch t.text {
+		case "true", "false":
+			return literalExpression{value: boolean(t.text == "true")}, nil
+		case "any", "all", "none":
+			if err := p.expect("of"); err != nil {
+				return nil, err
+			}
+			q := map[string]quantifier{"any": quantifierAny, "all": quantifierAll, "none": quantifierNone}[t.text]
+			return p.parseSet(rule, q, nil)
+		case "filesize", "entrypoint", "for":
+			return nil, p.errorf(t, "'%s' is not supported", t.text)
+		}
+		if p.is("(") || p.is(".") {
+			return nil, p.errorf(t, "functions and modules such as '%s' are not supported", t.text)
+		}
+		if !p.rules[t.text] {
+			return nil, p.errorf(t, "undefined identifier '%s'", t.text)
+		}
+		return ruleExpression{name: t.text}, nil
+	}
+	return nil, p.errorf(t, "unexpected %s in condition", t)
+}
+
+// parseRange parses a range of addresses such as (0x1000..0x2000).
+func (p *parser) parseRange(rule *Rule) (expression, expression, error) {
+	if err := p.expect("("); err != nil {
+		return nil, nil, err
+	}
+	from, err := p.parseAdditive(rule)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.expect(".."); err != nil {
+		return nil, nil, err
+	}
+	to, err := p.parseAdditive(rule)
+	if err != nil {
+		return nil, nil, err
+	}
+	return from, to, p.expect(")")
+}
+
+// parseSet parses the set of strings following "of", either "them" or a list such as ($a, $b*).
+func (p *parser) parseSet(rule *Rule, q quantifier, count expression) (expression, error) {
+	e := ofExpression{quantifier: q, count: count}
+	if p.accept("them") {
+		e.strings = rule.Strings
+	} else {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		for {
+			t := p.next()
+			if t.kind != tokenString {
+				return nil, p.errorf(t, "expected a string identifier but found %s", t)
+			}
+			matched := false
+			for _, s := range rule.Strings {
+				if wildcardMatch(t.text, s.Identifier[1:]) {
+					e.strings = append(e.strings, s)
+					matched = true
+				}
+			}
+			if !matched {
+				return nil, p.errorf(t, "undefined string identifier $%s", t.text)
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(e.strings) == 0 {
+		return nil, fmt.Errorf("rule '%s' has no strings", rule.Name)
+	}
+	return e, nil
+}
+
+// lookup returns the string referred to by a token such as $a, #a, @a or !a.
+func (p *parser) lookup(rule *Rule, t token) (*String, error) {
+	if t.text == "" || strings.HasSuffix(t.text, "*") {
+		return nil, p.errorf(t, "invalid string reference %s", t)
+	}
+	for _, s := range rule.Strings {
+		if s.Identifier == "$"+t.text {
+			return s, nil
+		}
+	}
+	return nil, p.errorf(t, "undefined string identifier $%s", t.text)
+}
+
+// wildcardMatch matches a string identifier against a reference with an optional trailing wildcard, e.g. "a*".
+func wildcardMatch(reference string, identifier string) bool {
+	if prefix := strings.TrimSuffix(reference, "*"); prefix != reference {
+		return strings.HasPrefix(identifier, prefix)
+	}
+	return reference == identifier
+}
@@ -0,0 +1,195 @@
+package yara
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/liamg/dismember/pkg/hexpattern"
+)
+
+// String is a string defined in a rule, such as $a = "text" nocase.
+type String struct {
+	Identifier string // Identifier includes the leading '$'
+	Source     string // Source is the value of the string as written in the rule
+	Private    bool
+	variants   []variant
+}
+
+// variant is one encoding of a string, e.g. the wide form of a text string with both the ascii and wide modifiers.
+type variant struct {
+	finder   finder
+	folded   bool // folded variants are searched for in a lower-cased copy of the data
+	wide     bool
+	fullword bool
+}
+
+// finder calls fn with the start and end of each match of a string in data.
+type finder interface {
+	find(data []byte, fn func(start, end int) bool)
+}
+
+// modifiers are the modifiers of a string.
+type modifiers struct {
+	nocase   bool
+	ascii    bool
+	wide     bool
+	fullword bool
+	private  bool
+}
+
+func newTextString(identifier string, text string, m modifiers) *String {
+	s := &String{Identifier: identifier, Source: fmt.Sprintf("%q", text), Private: m.private}
+	needle := []byte(text)
+	if m.nocase {
+		needle = fold(needle)
+	}
+	if m.ascii || !m.wide {
+		s.variants = append(s.variants, variant{finder: literal(needle), folded: m.nocase, fullword: m.fullword})
+	}
+	if m.wide {
+		s.variants = append(s.variants, variant{finder: literal(widen(needle)), folded: m.nocase, wide: true, fullword: m.fullword})
+	}
+	return s
+}
+
+func newHexString(identifier string, hex string, m modifiers) (*String, error) {
+	if m.nocase || m.ascii || m.wide || m.fullword {
+		return nil, fmt.Errorf("hex string %s only supports the private modifier", identifier)
+	}
+	pattern, err := hexpattern.Compile(hex)
+	if err != nil {
+		return nil, fmt.Errorf("string %s: %w", identifier, err)
+	}
+	return &String{
+		Identifier: identifier,
+		Source:     "{" + hex + "}",
+		Private:    m.private,
+		variants:   []variant{{finder: hexFinder{pattern}}},
+	}, nil
+}
+
+func newRegexString(identifier string, expr string, flags string, m modifiers) (*String, error) {
+	prefix := ""
+	for _, flag := range flags {
+		prefix += "(?" + string(flag) + ")"
+	}
+	if m.nocase {
+		prefix += "(?i)"
+	}
+	regex, err := regexp.Compile(prefix + expr)
+	if err != nil {
+		return nil, fmt.Errorf("string %s: invalid regular expression: %w", identifier, err)
+	}
+	s := &String{Identifier: identifier, Source: "/" + expr + "/" + flags, Private: m.private}
+	if m.ascii || !m.wide {
+		s.variants = append(s.variants, variant{finder: regexFinder{regex}, fullword: m.fullword})
+	}
+	if m.wide {
+		s.variants = append(s.variants, variant{finder: wideRegexFinder{regex}, wide: true, fullword: m.fullword})
+	}
+	return s, nil
+}
+
+// literal finds every occurrence of a sequence of bytes, including overlapping occurrences.
+type literal []byte
+
+func (l literal) find(data []byte, fn func(start, end int) bool) {
+	for offset := 0; offset < len(data); {
+		index := bytes.Index(data[offset:], l)
+		if index < 0 {
+			return
+		}
+		start := offset + index
+		if !fn(start, start+len(l)) {
+			return
+		}
+		offset = start + 1
+	}
+}
+
+type hexFinder struct {
+	pattern *hexpattern.Pattern
+}
+
+func (h hexFinder) find(data []byte, fn func(start, end int) bool) {
+	for _, match := range h.pattern.FindAllIndex(data, -1) {
+		if !fn(match[0], match[1]) {
+			return
+		}
+	}
+}
+
+type regexFinder struct {
+	regex *regexp.Regexp
+}
+
+func (r regexFinder) find(data []byte, fn func(start, end int) bool) {
+	for _, match := range r.regex.FindAllIndex(data, -1) {
+		if !fn(match[0], match[1]) {
+			return
+		}
+	}
+}
+
+// wideRegexFinder matches a regex against the ASCII characters of UTF-16LE text. The data is viewed at both even
+// and odd offsets, with one byte per character, and any character which is not ASCII replaced by 0xFF.
+type wideRegexFinder struct {
+	regex *regexp.Regexp
+}
+
+func (w wideRegexFinder) find(data []byte, fn func(start, end int) bool) {
+	view := make([]byte, len(data)/2)
+	for parity := 0; parity < 2; parity++ {
+		view = view[:(len(data)-parity)/2]
+		for i := range view {
+			if c := data[parity+2*i]; c < 0x80 && data[parity+2*i+1] == 0 {
+				view[i] = c
+			} else {
+				view[i] = 0xff
+			}
+		}
+		for _, match := range w.regex.FindAllIndex(view, -1) {
+			if !fn(parity+2*match[0], parity+2*match[1]) {
+				return
+			}
+		}
+	}
+}
+
+// fold returns a copy of data with ASCII letters converted to lower case.
+func fold(data []byte) []byte {
+	folded := make([]byte, len(data))
+	for i, b := range data {
+		if b >= 'A' && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		folded[i] = b
+	}
+	return folded
+}
+
+// widen converts ASCII text to UTF-16LE by following each byte with a NUL.
+func widen(data []byte) []byte {
+	wide := make([]byte, 0, len(data)*2)
+	for _, b := range data {
+		wide = append(wide, b, 0x00)
+	}
+	return wide
+}
+
+// isFullword returns true if the match is not immediately preceded or followed by an alphanumeric character.
+func (v variant) isFullword(data []byte, start, end int) bool {
+	size := 1
+	if v.wide {
+		size = 2
+	}
+	if start >= size && isAlphanumeric(data[start-size]) {
+		return false
+	}
+	return end >= len(data) || !isAlphanumeric(data[end])
+}
+
+func isAlphanumeric(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
@@ -0,0 +1,292 @@
+// Package yara compiles and evaluates a practical subset of YARA rules against the memory of a process.
+//
+// Rules may define text strings (with the nocase, ascii, wide, fullword and private modifiers), hex strings and
+// regular expressions. Conditions may use and, or, not, comparisons and integer arithmetic, string references
+// such as $a, $a at 0x1000 and $a in (0x1000..0x2000), counts (#a), offsets (@a[1]) and lengths (!a[1]), the
+// quantifiers any/all/none/N/N% of a set of strings, and references to earlier rules. Offsets are virtual
+// addresses in the memory of the process. Modules, includes, for loops and filesize are not supported.
+package yara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/liamg/dismember/pkg/proc"
+)
+
+const (
+	// DefaultWindow is the default amount of memory read at once.
+	DefaultWindow = 64 * 1024 * 1024
+	// DefaultOverlap is the default number of bytes shared by consecutive windows of a map.
+	DefaultOverlap = 4 * 1024
+	// DefaultMaxMatches is the default number of matches recorded for each string in a process.
+	DefaultMaxMatches = 10000
+	// maxMatchData is the number of bytes of each match which are kept for reporting.
+	maxMatchData = 256
+)
+
+// Rule is a compiled rule.
+type Rule struct {
+	Name      string
+	Tags      []string
+	Meta      map[string]string
+	Strings   []*String
+	Private   bool // Private rules are evaluated, but never reported
+	Global    bool // Global rules must be satisfied for any other rule to match
+	condition expression
+}
+
+// Rules is a compiled set of rules.
+type Rules struct {
+	Rules []*Rule
+}
+
+// Compile compiles the source of a set of rules.
+func Compile(source string) (*Rules, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, rules: make(map[string]bool)}
+	rules, err := p.parseRules()
+	if err != nil {
+		return nil, err
+	}
+	return &Rules{Rules: rules}, nil
+}
+
+// Load compiles the rules in a file.
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := Compile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Match is a rule which matched the memory of a process.
+type Match struct {
+	Rule    *Rule
+	Strings []StringMatch // Strings holds the matches of each string in the rule, except private strings
+}
+
+// StringMatch is a single match of a string.
+type StringMatch struct {
+	String  *String
+	Map     proc.Map
+	Address uint64
+	Length  int
+	Data    []byte // Data holds up to the first 256 bytes of the match
+}
+
+// Memory is the memory of a process. It is satisfied by scan.Target and proc.Image.
+type Memory = proc.Memory
+
+// Logger receives debug messages from the Scanner.
+type Logger interface {
+	Log(format string, args ...interface{})
+}
+
+// Scanner evaluates a set of rules against the memory of processes.
+type Scanner struct {
+	rules         *Rules
+	regionFilters []func(proc.Map) (bool, string)
+	window        uint64
+	overlap       uint64
+	maxMatches    int
+	logger        Logger
+}
+
+// Option configures a Scanner.
+type Option func(s *Scanner)
+
+// NewScanner creates a Scanner for a set of rules.
+func NewScanner(rules *Rules, options ...Option) *Scanner {
+	s := &Scanner{
+		rules:      rules,
+		window:     DefaultWindow,
+		overlap:    DefaultOverlap,
+		maxMatches: DefaultMaxMatches,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// WithRegionFilter adds a filter which each map must pass to be scanned. The filter returns the reason a map is
+// skipped, and may be a scan.RegionFilter.
+func WithRegionFilter(filter func(proc.Map) (bool, string)) Option {
+	return func(s *Scanner) {
+		s.regionFilters = append(s.regionFilters, filter)
+	}
+}
+
+// WithWindow sets the amount of memory read at once, and the number of bytes shared by consecutive windows.
+// Matches longer than the overlap may be missed if they cross a window boundary.
+func WithWindow(window uint64, overlap uint64) Option {
+	return func(s *Scanner) {
+		s.window = window
+		s.overlap = overlap
+	}
+}
+
+// WithMaxMatches sets the number of matches recorded for each string in a process. Further matches are ignored.
+func WithMaxMatches(max int) Option {
+	return func(s *Scanner) {
+		s.maxMatches = max
+	}
+}
+
+// WithLogger sets a Logger for debug messages.
+func WithLogger(logger Logger) Option {
+	return func(s *Scanner) {
+		s.logger = logger
+	}
+}
+
+// Scan searches the readable maps of a process for the strings of every rule, then evaluates the conditions of
+// the rules. It returns the rules which matched, in the order they were defined.
+func (s *Scanner) Scan(ctx context.Context, mem Memory) ([]Match, error) {
+	if s.window <= s.overlap {
+		return nil, fmt.Errorf("window (%d bytes) must be larger than the overlap (%d bytes)", s.window, s.overlap)
+	}
+	maps, err := mem.Maps()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maps: %w", err)
+	}
+	reader, err := mem.OpenMemory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open memory: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	matches := make(map[*String][]StringMatch)
+	for _, region := range maps {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !s.include(region) {
+			continue
+		}
+		if err := s.scanRegion(ctx, reader, region, matches); err != nil {
+			if errors.Is(err, syscall.ESRCH) {
+				return nil, fmt.Errorf("process exited during scan")
+			}
+			s.log("failed to read memory at %X: %s", region.Address, err)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	for _, found := range matches {
+		sort.SliceStable(found, func(i, j int) bool {
+			return found[i].Address < found[j].Address
+		})
+	}
+	return s.evaluate(matches), nil
+}
+
+func (s *Scanner) include(m proc.Map) bool {
+	if !m.Permissions.Readable {
+		return false
+	}
+	for _, filter := range s.regionFilters {
+		if ok, reason := filter(m); !ok {
+			s.log("skipping memory at %X: %s", m.Address, reason)
+			return false
+		}
+	}
+	return true
+}
+
+// scanRegion records the matches of every string in a map.
+func (s *Scanner) scanRegion(ctx context.Context, reader proc.MemoryReader, region proc.Map, matches map[*String][]StringMatch) error {
+	regionReader, err := proc.NewRegionReader(reader, region, s.window, s.overlap)
+	if err != nil {
+		return err
+	}
+	for ctx.Err() == nil && regionReader.Next() {
+		chunk := regionReader.Chunk()
+		var folded []byte
+		for _, rule := range s.rules.Rules {
+			for _, str := range rule.Strings {
+				for _, v := range str.variants {
+					if len(matches[str]) >= s.maxMatches {
+						break
+					}
+					data := chunk.Data
+					if v.folded {
+						if folded == nil {
+							folded = fold(chunk.Data)
+						}
+						data = folded
+					}
+					v.finder.find(data, func(start, end int) bool {
+						if !chunk.Owns(start) || (v.fullword && !v.isFullword(chunk.Data, start, end)) {
+							return true
+						}
+						if len(matches[str]) >= s.maxMatches {
+							s.log("too many matches for string %s of rule %s, ignoring the rest", str.Identifier, rule.Name)
+							return false
+						}
+						kept := end
+						if kept-start > maxMatchData {
+							kept = start + maxMatchData
+						}
+						matches[str] = append(matches[str], StringMatch{
+							String:  str,
+							Map:     region,
+							Address: chunk.Address(region, start),
+							Length:  end - start,
+							Data:    append([]byte(nil), chunk.Data[start:kept]...),
+						})
+						return true
+					})
+				}
+			}
+		}
+	}
+	return regionReader.Err()
+}
+
+// evaluate evaluates the condition of each rule. If any global rule is not satisfied, no rules match.
+func (s *Scanner) evaluate(matches map[*String][]StringMatch) []Match {
+	sc := &scope{matches: matches, rules: make(map[string]bool)}
+	var matched []Match
+	for _, rule := range s.rules.Rules {
+		ok := rule.condition.eval(sc).isTrue()
+		sc.rules[rule.Name] = ok
+		if !ok {
+			if rule.Global {
+				return nil
+			}
+			continue
+		}
+		if rule.Private {
+			continue
+		}
+		match := Match{Rule: rule}
+		for _, str := range rule.Strings {
+			if !str.Private {
+				match.Strings = append(match.Strings, matches[str]...)
+			}
+		}
+		matched = append(matched, match)
+	}
+	return matched
+}
+
+func (s *Scanner) log(format string, args ...interface{}) {
+	if s.logger != nil {
+		s.logger.Log(format, args...)
+	}
+}
@@ -0,0 +1,199 @@
+package yara
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liamg/dismember/pkg/proc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMemory is a single readable map holding data.
+type fakeMemory struct {
+	address uint64
+	data    []byte
+}
+
+func (f *fakeMemory) Maps() (proc.Maps, error) {
+	return proc.Maps{{
+		Address:     f.address,
+		Size:        uint64(len(f.data)),
+		Permissions: proc.MemPerms{Readable: true, Writable: true},
+		Path:        "[heap]",
+	}}, nil
+}
+
+func (f *fakeMemory) OpenMemory() (proc.MemoryReader, error) {
+	return f, nil
+}
+
+func (f *fakeMemory) ReadAt(buf []byte, address uint64) (proc.Faults, error) {
+	copy(buf, f.data[address-f.address:])
+	return nil, nil
+}
+
+func (f *fakeMemory) Close() error {
+	return nil
+}
+
+var memory = &fakeMemory{
+	address: 0x1000,
+	data: append(
+		[]byte("Hello World password=hunter2 hello world \x00\x00\x00\x00"),
+		's', 0, 'e', 0, 'c', 0, 'r', 0, 'e', 0, 't', 0, 0x4d, 0x5a, 0x90, 0x00, 'x',
+	),
+}
+
+func scan(t *testing.T, source string) []Match {
+	rules, err := Compile(source)
+	require.NoError(t, err)
+	matches, err := NewScanner(rules).Scan(context.Background(), memory)
+	require.NoError(t, err)
+	return matches
+}
+
+func Test_ScanConditions(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		want      bool
+	}{
+		{name: "text", condition: "$text", want: true},
+		{name: "missing", condition: "$missing", want: false},
+		{name: "not", condition: "not $missing", want: true},
+		{name: "and", condition: "$text and $missing", want: false},
+		{name: "or", condition: "$text or $missing", want: true},
+		{name: "any of them", condition: "any of them", want: true},
+		{name: "all of them", condition: "all of them", want: false},
+		{name: "none of set", condition: "none of ($missing)", want: true},
+		{name: "count of set", condition: "2 of ($text, $hex, $missing)", want: true},
+		{name: "percent of set", condition: "50% of ($t*, $missing)", want: true},
+		{name: "count", condition: "#nocase == 2", want: true},
+		{name: "offset", condition: "@text == 0x100c", want: true},
+		{name: "offset of second match", condition: "@nocase[2] == 0x101d", want: true},
+		{name: "undefined offset", condition: "@nocase[3] == 0", want: false},
+		{name: "length", condition: "!regex[1] == 16", want: true},
+		{name: "at", condition: "$text at 0x100c", want: true},
+		{name: "at wrong address", condition: "$text at 0x100d", want: false},
+		{name: "in", condition: "$hex in (0x1000..0x1040)", want: true},
+		{name: "arithmetic", condition: "@hex[1] - @wide[1] == 12", want: true},
+		{name: "division", condition: "#nocase \\ 2 == 1 and #nocase % 2 == 0", want: true},
+		{name: "wide", condition: "$wide", want: true},
+		{name: "fullword", condition: "#fullword == 2 and not $partial", want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matches := scan(t, `
+rule test {
+	strings:
+		$text = "password="
+		$missing = "not in memory"
+		$nocase = "hello world" nocase
+		$regex = /password=[a-z0-9]+/
+		$hex = { 4D 5A ?? 00 }
+		$wide = "secret" wide
+		$fullword = "hello" fullword nocase
+		$partial = "hunter" fullword
+	condition:
+		`+test.condition+`
+}`)
+			assert.Equal(t, test.want, len(matches) == 1)
+		})
+	}
+}
+
+func Test_ScanReportsStrings(t *testing.T) {
+	matches := scan(t, `
+rule credentials : memory {
+	meta:
+		author = "dismember"
+	strings:
+		$a = "password=" ascii wide
+		$b = "World"
+		$c = "Hello" private
+	condition:
+		$a and ($b or $c)
+}`)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "credentials", matches[0].Rule.Name)
+	assert.Equal(t, []string{"memory"}, matches[0].Rule.Tags)
+	assert.Equal(t, "dismember", matches[0].Rule.Meta["author"])
+	require.Len(t, matches[0].Strings, 2)
+	assert.Equal(t, "$a", matches[0].Strings[0].String.Identifier)
+	assert.Equal(t, uint64(0x100c), matches[0].Strings[0].Address)
+	assert.Equal(t, []byte("password="), matches[0].Strings[0].Data)
+	assert.Equal(t, "$b", matches[0].Strings[1].String.Identifier)
+	assert.Equal(t, uint64(0x1006), matches[0].Strings[1].Address)
+}
+
+func Test_ScanRuleReferences(t *testing.T) {
+	matches := scan(t, `
+private rule has_password {
+	strings:
+		$a = "password="
+	condition:
+		$a
+}
+
+rule uses_reference {
+	condition:
+		has_password
+}`)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "uses_reference", matches[0].Rule.Name)
+}
+
+func Test_ScanGlobalRules(t *testing.T) {
+	matches := scan(t, `
+rule always {
+	condition:
+		true
+}
+
+global rule requires_missing {
+	strings:
+		$a = "not in memory"
+	condition:
+		$a
+}`)
+	assert.Empty(t, matches)
+}
+
+func Test_ScanMaxMatches(t *testing.T) {
+	rules, err := Compile(`rule l { strings: $a = "l" condition: #a == 2 }`)
+	require.NoError(t, err)
+	matches, err := NewScanner(rules, WithMaxMatches(2)).Scan(context.Background(), memory)
+	require.NoError(t, err)
+	assert.Len(t, matches, 1)
+}
+
+func Test_CompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		error  string
+	}{
+		{name: "imports", source: `import "pe"`, error: "'import' is not supported"},
+		{name: "undefined string", source: `rule a { strings: $a = "x" condition: $b }`, error: "undefined string identifier $b"},
+		{name: "undefined rule", source: `rule a { condition: b }`, error: "undefined identifier 'b'"},
+		{name: "duplicate rule", source: `rule a { condition: true } rule a { condition: true }`, error: "duplicate rule 'a'"},
+		{name: "duplicate string", source: `rule a { strings: $a = "x" $a = "y" condition: $a }`, error: "duplicate string identifier $a"},
+		{name: "unsupported modifier", source: `rule a { strings: $a = "x" xor condition: $a }`, error: "the xor modifier is not supported"},
+		{name: "modifier on hex string", source: `rule a { strings: $a = { 00 } nocase condition: $a }`, error: "only supports the private modifier"},
+		{name: "invalid hex string", source: `rule a { strings: $a = { 0G } condition: $a }`, error: "invalid hex pattern"},
+		{name: "invalid regex", source: `rule a { strings: $a = /(/ condition: $a }`, error: "invalid regular expression"},
+		{name: "functions", source: `rule a { condition: uint16(0) == 0x5A4D }`, error: "functions and modules such as 'uint16' are not supported"},
+		{name: "filesize", source: `rule a { condition: filesize < 10 }`, error: "'filesize' is not supported"},
+		{name: "unterminated string", source: "rule a { strings: $a = \"x\n condition: $a }", error: "line 1: unterminated string"},
+		{name: "missing condition", source: `rule a { strings: $a = "x" }`, error: "line 1: expected 'condition'"},
+		{name: "line numbers", source: "rule a {\n\tcondition:\n\t\t$a\n}", error: "line 3: undefined string identifier $a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Compile(test.source)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.error)
+		})
+	}
+}